@@ -2,6 +2,7 @@ package main
 
 import (
 	"log"
+	"time"
 
 	"github.com/eng-by-sjb/yellow-pines-e-commerce-backend/cmd/server"
 	"github.com/eng-by-sjb/yellow-pines-e-commerce-backend/internal/auth"
@@ -10,12 +11,14 @@ import (
 )
 
 var (
-	srvAddr                  = config.Env.ServerAddr
-	PostgresConnStr          = config.Env.PostgresConnStr
-	accessTokenSecret        = config.Env.AccessTokenSecret
-	refreshTokenSecret       = config.Env.RefreshTokenSecret
-	accessTokenExpiryInSecs  = config.Env.AccessTokenExpiryInSecs
-	refreshTokenExpiryInSecs = config.Env.RefreshTokenExpiryInSecs
+	srvAddr                     = config.Env.ServerAddr
+	PostgresConnStr             = config.Env.PostgresConnStr
+	eventTransport              = config.Env.EventTransport
+	accessTokenSecret           = config.Env.AccessTokenSecret
+	refreshTokenSecret          = config.Env.RefreshTokenSecret
+	accessTokenExpiryInSecs     = config.Env.AccessTokenExpiryInSecs
+	refreshTokenExpiryInSecs    = config.Env.RefreshTokenExpiryInSecs
+	productTrashRetentionInDays = config.Env.ProductTrashRetentionInDays
 )
 
 func main() {
@@ -27,8 +30,11 @@ func main() {
 	}
 
 	srv := server.NewServer(&server.ServerConfig{
-		Addr: srvAddr,
-		DB:   db,
+		Addr:                  srvAddr,
+		DB:                    db,
+		PostgresConnStr:       PostgresConnStr,
+		EventTransport:        eventTransport,
+		ProductTrashRetention: time.Duration(productTrashRetentionInDays) * 24 * time.Hour,
 		TokenManager: auth.NewTokenService(
 			accessTokenSecret,
 			refreshTokenSecret,
@@ -37,5 +43,7 @@ func main() {
 		),
 	},
 	)
-	srv.Run()
+	if err := srv.Run(); err != nil {
+		log.Fatal(err)
+	}
 }
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_outbox_undelivered;
+
+DROP TABLE IF EXISTS outbox;
@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS outbox (
+    id BIGSERIAL PRIMARY KEY,
+    event_name VARCHAR(255) NOT NULL,
+    payload JSONB NOT NULL,
+    attempts INT NOT NULL DEFAULT 0,
+    last_error TEXT,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    delivered_at TIMESTAMP,
+    -- set once the relay gave up on a row after too many failed attempts, so
+    -- the rows after it are published and the failed one is kept for
+    -- inspection.
+    dead_lettered_at TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_outbox_undelivered ON outbox (id) WHERE delivered_at IS NULL;
@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS dead_letters (
+    dead_letter_id UUID PRIMARY KEY,
+    event_name VARCHAR(255) NOT NULL,
+    subscriber_name VARCHAR(255) NOT NULL,
+    payload JSONB NOT NULL,
+    error TEXT NOT NULL,
+    attempts INT NOT NULL,
+    failed_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
@@ -0,0 +1,15 @@
+ALTER TABLE dead_letters
+    DROP COLUMN IF EXISTS producer,
+    DROP COLUMN IF EXISTS causation_id,
+    DROP COLUMN IF EXISTS correlation_id,
+    DROP COLUMN IF EXISTS occurred_at,
+    DROP COLUMN IF EXISTS event_version,
+    DROP COLUMN IF EXISTS event_id;
+
+ALTER TABLE outbox
+    DROP COLUMN IF EXISTS producer,
+    DROP COLUMN IF EXISTS causation_id,
+    DROP COLUMN IF EXISTS correlation_id,
+    DROP COLUMN IF EXISTS occurred_at,
+    DROP COLUMN IF EXISTS event_version,
+    DROP COLUMN IF EXISTS event_id;
@@ -0,0 +1,18 @@
+-- gen_random_uuid is only built into postgres from version 13 on.
+CREATE EXTENSION IF NOT EXISTS pgcrypto;
+
+ALTER TABLE outbox
+    ADD COLUMN IF NOT EXISTS event_id UUID NOT NULL DEFAULT gen_random_uuid(),
+    ADD COLUMN IF NOT EXISTS event_version INT NOT NULL DEFAULT 1,
+    ADD COLUMN IF NOT EXISTS occurred_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    ADD COLUMN IF NOT EXISTS correlation_id VARCHAR(255) NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS causation_id UUID NOT NULL DEFAULT '00000000-0000-0000-0000-000000000000',
+    ADD COLUMN IF NOT EXISTS producer VARCHAR(255) NOT NULL DEFAULT '';
+
+ALTER TABLE dead_letters
+    ADD COLUMN IF NOT EXISTS event_id UUID NOT NULL DEFAULT gen_random_uuid(),
+    ADD COLUMN IF NOT EXISTS event_version INT NOT NULL DEFAULT 1,
+    ADD COLUMN IF NOT EXISTS occurred_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    ADD COLUMN IF NOT EXISTS correlation_id VARCHAR(255) NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS causation_id UUID NOT NULL DEFAULT '00000000-0000-0000-0000-000000000000',
+    ADD COLUMN IF NOT EXISTS producer VARCHAR(255) NOT NULL DEFAULT '';
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS event_checkpoints;
+DROP TABLE IF EXISTS event_log;
@@ -0,0 +1,22 @@
+CREATE TABLE IF NOT EXISTS event_log (
+    sequence BIGSERIAL PRIMARY KEY,
+    event_id UUID NOT NULL UNIQUE,
+    event_name VARCHAR(255) NOT NULL,
+    event_version INT NOT NULL DEFAULT 1,
+    occurred_at TIMESTAMP NOT NULL,
+    correlation_id VARCHAR(255) NOT NULL DEFAULT '',
+    causation_id UUID NOT NULL DEFAULT '00000000-0000-0000-0000-000000000000',
+    producer VARCHAR(255) NOT NULL DEFAULT '',
+    payload JSONB NOT NULL,
+    recorded_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS event_log_occurred_at_idx ON event_log (occurred_at);
+
+CREATE TABLE IF NOT EXISTS event_checkpoints (
+    subscriber_name VARCHAR(255) NOT NULL,
+    event_name VARCHAR(255) NOT NULL,
+    sequence BIGINT NOT NULL,
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (subscriber_name, event_name)
+);
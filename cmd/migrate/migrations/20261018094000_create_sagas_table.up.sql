@@ -0,0 +1,17 @@
+CREATE TABLE IF NOT EXISTS sagas (
+    saga_id UUID PRIMARY KEY,
+    saga_name VARCHAR(255) NOT NULL,
+    saga_key VARCHAR(255) NOT NULL,
+    status VARCHAR(50) NOT NULL,
+    step INT NOT NULL DEFAULT 0,
+    step_name VARCHAR(255) NOT NULL DEFAULT '',
+    data JSONB NOT NULL DEFAULT 'null',
+    error TEXT NOT NULL DEFAULT '',
+    deadline TIMESTAMP,
+    version INT NOT NULL DEFAULT 1,
+    started_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    UNIQUE (saga_name, saga_key)
+);
+
+CREATE INDEX IF NOT EXISTS sagas_deadline_idx ON sagas (deadline) WHERE status IN ('running', 'compensating');
@@ -0,0 +1,6 @@
+CREATE TABLE IF NOT EXISTS processed_events (
+    event_id UUID NOT NULL,
+    subscriber_name VARCHAR(255) NOT NULL,
+    processed_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (event_id, subscriber_name)
+);
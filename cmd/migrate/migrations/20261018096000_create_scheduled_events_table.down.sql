@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS scheduled_events_publish_at_idx;
+
+DROP TABLE IF EXISTS scheduled_events;
@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS scheduled_events (
+    event_id UUID PRIMARY KEY,
+    event_name VARCHAR(255) NOT NULL,
+    event_version INT NOT NULL DEFAULT 1,
+    occurred_at TIMESTAMP NOT NULL,
+    correlation_id VARCHAR(255) NOT NULL DEFAULT '',
+    causation_id UUID NOT NULL DEFAULT '00000000-0000-0000-0000-000000000000',
+    producer VARCHAR(255) NOT NULL DEFAULT '',
+    payload JSONB NOT NULL,
+    publish_at TIMESTAMP NOT NULL,
+    claimed_until TIMESTAMP,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS scheduled_events_publish_at_idx ON scheduled_events (publish_at);
@@ -0,0 +1,10 @@
+-- the name of a product is weighted above its description, so products whose
+-- name matches a search rank first. Being generated, the column is kept in
+-- sync with name and description by postgres.
+ALTER TABLE products
+    ADD COLUMN IF NOT EXISTS search_vector tsvector GENERATED ALWAYS AS (
+        setweight(to_tsvector('english', coalesce(name, '')), 'A') ||
+        setweight(to_tsvector('english', coalesce(description, '')), 'B')
+    ) STORED;
+
+CREATE INDEX IF NOT EXISTS products_search_vector_idx ON products USING GIN (search_vector);
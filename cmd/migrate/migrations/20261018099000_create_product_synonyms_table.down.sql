@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS product_synonyms;
+
+DROP INDEX IF EXISTS products_category_trgm_idx;
+
+DROP INDEX IF EXISTS products_name_trgm_idx;
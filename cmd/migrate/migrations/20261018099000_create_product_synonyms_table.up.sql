@@ -0,0 +1,19 @@
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+-- trigram indexes for suggesting product names and categories while a
+-- shopper types, even if misspelled.
+CREATE INDEX IF NOT EXISTS products_name_trgm_idx ON products USING GIN (name gin_trgm_ops);
+CREATE INDEX IF NOT EXISTS products_category_trgm_idx ON products USING GIN (category gin_trgm_ops);
+
+-- a synonym applies both ways, so the product service stores every pair once,
+-- with the lexically smaller word as term.
+CREATE TABLE IF NOT EXISTS product_synonyms (
+    synonym_id UUID PRIMARY KEY,
+    term TEXT NOT NULL,
+    synonym TEXT NOT NULL,
+    admin_id UUID NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    UNIQUE (term, synonym)
+);
+
+CREATE INDEX IF NOT EXISTS product_synonyms_synonym_idx ON product_synonyms (synonym);
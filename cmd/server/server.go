@@ -15,8 +15,10 @@ import (
 
 	"github.com/eng-by-sjb/yellow-pines-e-commerce-backend/internal/auth"
 	"github.com/eng-by-sjb/yellow-pines-e-commerce-backend/internal/eventengine"
+	"github.com/eng-by-sjb/yellow-pines-e-commerce-backend/internal/eventengine/event"
+	"github.com/eng-by-sjb/yellow-pines-e-commerce-backend/internal/eventengine/outbox"
+	"github.com/eng-by-sjb/yellow-pines-e-commerce-backend/internal/eventengine/saga"
 	"github.com/eng-by-sjb/yellow-pines-e-commerce-backend/internal/features/admin"
-	"github.com/eng-by-sjb/yellow-pines-e-commerce-backend/internal/features/cart"
 	"github.com/eng-by-sjb/yellow-pines-e-commerce-backend/internal/features/inventory"
 	"github.com/eng-by-sjb/yellow-pines-e-commerce-backend/internal/features/product"
 	"github.com/eng-by-sjb/yellow-pines-e-commerce-backend/internal/features/session"
@@ -28,9 +30,12 @@ import (
 )
 
 type ServerConfig struct {
-	Addr         string
-	DB           *sql.DB
-	TokenManager *auth.TokenService
+	Addr                  string
+	DB                    *sql.DB
+	PostgresConnStr       string        // Used by the postgres event transport to listen for events of other instances.
+	EventTransport        string        // Either "channel" (default) or "postgres", to share events between instances.
+	ProductTrashRetention time.Duration // How long a deleted product can be restored before it is purged.
+	TokenManager          *auth.TokenService
 }
 
 type server struct {
@@ -39,8 +44,9 @@ type server struct {
 	doneCh        chan struct{}   // used to signal internal go routines to shutdown
 	internalSrvWG *sync.WaitGroup // used to wait for all internal go routines within individual routes to finish before shutting down the server.
 
-	eventEngine eventengine.SubscribeRegisterPublisher
-	srv         *http.Server
+	eventEngine      eventengine.Engine
+	sagaOrchestrator *saga.Orchestrator
+	srv              *http.Server
 }
 
 func NewServer(serverConfig *ServerConfig) *server {
@@ -53,7 +59,10 @@ func NewServer(serverConfig *ServerConfig) *server {
 	return srv
 }
 
-func (s *server) Run() {
+// Run starts the server and blocks until it shut down. It returns an error if
+// the server could not be started, e.g. because a feature failed to subscribe
+// to the events it handles.
+func (s *server) Run() error {
 	router := chi.NewRouter()
 
 	// strip trailing slashes at the end of the url
@@ -62,9 +71,20 @@ func (s *server) Run() {
 	// to ensure that the url is correctly formatted
 	router.Use(chimiddleware.StripSlashes)
 
-	s.prep()
+	// give every request an id and correlate the events it causes with it.
+	router.Use(chimiddleware.RequestID)
+	router.Use(middlewares.CorrelationID)
 
-	router.Mount("/api/v1", s.v1Router()) // api version 1 subrouter
+	if err := s.prep(); err != nil {
+		return err
+	}
+
+	v1Router, err := s.v1Router()
+	if err != nil {
+		s.stopInternal()
+		return fmt.Errorf("failed to set up routes: %w", err)
+	}
+	router.Mount("/api/v1", v1Router) // api version 1 subrouter
 
 	s.srv = &http.Server{
 		Addr:    fmt.Sprintf(":%s", s.Addr),
@@ -73,6 +93,15 @@ func (s *server) Run() {
 
 	// start server and listen for [os.Signal] signals to graceful shutdown server.
 	s.listenAndServe()
+
+	return nil
+}
+
+// stopInternal stops the internal go routines that prep started, when the
+// server fails to start.
+func (s *server) stopInternal() {
+	close(s.doneCh)
+	s.internalSrvWG.Wait()
 }
 
 func (s *server) listenAndServe() {
@@ -139,21 +168,84 @@ func (s *server) listenAndServe() {
 }
 
 // prep prepares server dependencies needed for server to function
-func (s *server) prep() {
+func (s *server) prep() error {
+	// with the postgres transport, events published on any instance of the
+	// server reach the subscribers of every instance.
+	var eventTransport eventengine.Transport
+	if s.EventTransport == "postgres" {
+		var err error
+		eventTransport, err = eventengine.NewPostgresTransport(
+			&eventengine.PostgresTransportConfig{
+				ConnStr: s.PostgresConnStr,
+				DB:      s.DB,
+			},
+		)
+		if err != nil {
+			return fmt.Errorf("failed to create the event transport: %w", err)
+		}
+	}
+
+	// the schemas decode the events stored or published by any version of
+	// the server into the current shape of their payloads.
+	eventSchemas, err := event.NewSchemas()
+	if err != nil {
+		return err
+	}
+
 	s.eventEngine = eventengine.NewEventEngine(
 		&eventengine.EventEngineConfig{
+			DoneCh:          s.doneCh,
+			InternalSrvWG:   s.internalSrvWG,
+			DeadLetterStore: eventengine.NewPostgresDeadLetterStore(s.DB),
+			EventStore:      eventengine.NewPostgresEventStore(s.DB),
+			ScheduleStore:   eventengine.NewPostgresScheduleStore(s.DB),
+			Transport:       eventTransport,
+			Schemas:         eventSchemas,
+		},
+	)
+
+	// the saga orchestrator runs workflows that span several features, e.g.
+	// provisioning a product and its inventory.
+	s.sagaOrchestrator = saga.NewOrchestrator(
+		&saga.OrchestratorConfig{
 			DoneCh:        s.doneCh,
 			InternalSrvWG: s.internalSrvWG,
+			EventEngine:   s.eventEngine,
+			Store:         saga.NewPostgresStore(s.DB),
 		},
 	)
+
+	// the outbox relay publishes events that features wrote to the outbox
+	// table in the same transaction as their own writes.
+	outbox.NewRelay(
+		&outbox.RelayConfig{
+			DoneCh:        s.doneCh,
+			InternalSrvWG: s.internalSrvWG,
+			DB:            s.DB,
+			Publisher:     s.eventEngine,
+			Schemas:       eventSchemas,
+		},
+	)
+
+	return nil
 }
 
-func (s *server) v1Router() *chi.Mux {
+func (s *server) v1Router() (*chi.Mux, error) {
 	r := chi.NewRouter()
 
-	// health check
+	// health check. The server is unhealthy once an event handler failed, as
+	// the events it handles are only dead lettered from then on.
 	r.Get("/health", func(w http.ResponseWriter, r *http.Request) {
 		log.Println("health check")
+
+		for _, health := range s.eventEngine.SubscriberHealth() {
+			if health.Status == eventengine.HealthFailed {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				fmt.Fprintf(w, "subscriber '%s' of '%s' failed", health.SubscriberName, health.EventName)
+				return
+			}
+		}
+
 		w.WriteHeader(http.StatusOK)
 		w.Write([]byte("OK"))
 	})
@@ -175,54 +267,81 @@ func (s *server) v1Router() *chi.Mux {
 	userHandler := user.NewHandler(userService)
 	userHandler.RegisterRoutes(r)
 
+	//middleware
+	middleware := middlewares.NewMiddleware(
+		s.TokenManager,
+	)
+
 	//admin feature
 	adminStore := admin.NewStore(s.DB)
 	adminService := admin.NewService(
 		adminStore,
 		sessionService,
+		s.eventEngine,
 	)
-	adminHandler := admin.NewHandler(adminService)
-	adminHandler.RegisterRoutes(r)
-
-	//middleware
-	middleware := middlewares.NewMiddleware(
-		s.TokenManager,
+	adminHandler := admin.NewHandler(
+		adminService,
+		middleware,
 	)
+	adminHandler.RegisterRoutes(r)
 
 	// inventory feature
 	inventoryStore := inventory.NewStore(s.DB)
 	inventoryService := inventory.NewService(
 		inventoryStore,
-	inventory.NewEventHandler(
-		&inventory.HandlerEventsConfig{
-			DoneCh:        s.doneCh,
-			InternalSrvWG: s.internalSrvWG,
-			EventEngine:   s.eventEngine,
-			Service:       inventoryService,
-			AddressChSize: 10,
-		},
 	)
+	_, err := eventengine.RunModule(
+		s.eventEngine,
+		inventory.NewEventsModule(
+			&inventory.HandlerEventsConfig{
+				EventEngine:   s.eventEngine,
+				Service:       inventoryService,
+				AddressChSize: 10,
+				Concurrency:   4,
+			},
+		),
+	)
+	if err != nil {
+		return nil, err
+	}
 
 	// products feature
 	productStore := product.NewStore(s.DB)
-	productService := product.NewService(
+	productService, err := product.NewService(
 		productStore,
-		s.eventEngine,
+		s.sagaOrchestrator,
+		s.ProductTrashRetention,
 	)
-	product.NewHandlerEvents(
-		&product.HandlerEventsConfig{
+	if err != nil {
+		return nil, err
+	}
+
+	// purges the products that have been in the trash for longer than the
+	// trash retention.
+	product.NewTrashPurger(
+		&product.TrashPurgerConfig{
 			DoneCh:        s.doneCh,
 			InternalSrvWG: s.internalSrvWG,
-			EventEngine:   s.eventEngine,
 			Service:       productService,
-			AddressChSize: 10,
 		},
 	)
+	_, err = eventengine.RunModule(
+		s.eventEngine,
+		product.NewEventsModule(
+			&product.HandlerEventsConfig{
+				Service:       productService,
+				AddressChSize: 10,
+			},
+		),
+	)
+	if err != nil {
+		return nil, err
+	}
 	productHandler := product.NewHandler(
 		productService,
 		middleware,
 	)
 	productHandler.RegisterRoutes(r)
 
-	return r
+	return r, nil
 }
@@ -11,12 +11,14 @@ import (
 var Env = initConfig()
 
 type Config struct {
-	PostgresConnStr          string
-	ServerAddr               string
-	AccessTokenSecret        string
-	RefreshTokenSecret       string
-	AccessTokenExpiryInSecs  int64
-	RefreshTokenExpiryInSecs int64
+	PostgresConnStr             string
+	ServerAddr                  string
+	EventTransport              string
+	AccessTokenSecret           string
+	RefreshTokenSecret          string
+	AccessTokenExpiryInSecs     int64
+	RefreshTokenExpiryInSecs    int64
+	ProductTrashRetentionInDays int64
 }
 
 func initConfig() *Config {
@@ -33,6 +35,10 @@ func initConfig() *Config {
 		),
 		ServerAddr: getEnvAsStr("SERVER_ADDR",
 			"localhost:8080"),
+		EventTransport: getEnvAsStr(
+			"EVENT_TRANSPORT",
+			"channel",
+		),
 		AccessTokenSecret: getEnvAsStr(
 			"ACCESS_TOKEN_SECRET",
 			"secret",
@@ -49,6 +55,10 @@ func initConfig() *Config {
 			"REFRESH_TOKEN_EXPIRY_IN_SECS",
 			720*24*7,
 		),
+		ProductTrashRetentionInDays: getEnvAsInt(
+			"PRODUCT_TRASH_RETENTION_IN_DAYS",
+			30,
+		),
 	}
 }
 
@@ -0,0 +1,254 @@
+package eventengine
+
+import (
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/eng-by-sjb/yellow-pines-e-commerce-backend/internal/eventengine/event"
+)
+
+// defaultBlockTimeout is used by subscribers with BlockWithTimeoutPolicy that
+// do not set a BlockTimeout.
+const defaultBlockTimeout = time.Second
+
+// subscriber is a subscriber of an event together with how the event engine
+// delivers to its addressCh when it is full.
+type subscriber struct {
+	name         event.SubscriberName
+	addressCh    chan *event.Event
+	backpressure event.BackpressurePolicy
+	blockTimeout time.Duration
+	spillQueue   *spillQueue // only set for SpillPolicy.
+	byHandler    bool        // set for the subscriber of a Handler, which acknowledges the events it handled itself, see PublishAndWait.
+	dropped      atomic.Uint64
+	inFlight     sync.WaitGroup // deliveries to addressCh that have not finished yet.
+}
+
+func newEngineSubscriber(
+	name event.SubscriberName,
+	addressCh chan *event.Event,
+	backpressure event.BackpressurePolicy,
+	blockTimeout time.Duration,
+) *subscriber {
+	if backpressure == event.BlockWithTimeoutPolicy && blockTimeout <= 0 {
+		blockTimeout = defaultBlockTimeout
+	}
+
+	s := &subscriber{
+		name:         name,
+		addressCh:    addressCh,
+		backpressure: backpressure,
+		blockTimeout: blockTimeout,
+	}
+
+	if backpressure == event.SpillPolicy {
+		s.spillQueue = newSpillQueue(addressCh)
+	}
+
+	return s
+}
+
+// deliver sends newEvent to the addressCh of s, applying the backpressure
+// policy of s when the addressCh is full. The events s drops are acknowledged
+// with ErrEventDropped.
+func (s *subscriber) deliver(newEvent *event.Event, acks *acknowledgements) {
+	switch s.backpressure {
+	case event.BlockWithTimeoutPolicy:
+		timer := time.NewTimer(s.blockTimeout)
+		defer timer.Stop()
+
+		select {
+		case s.addressCh <- newEvent:
+		case <-timer.C:
+			s.drop(newEvent, acks)
+		}
+
+	case event.DropNewestPolicy:
+		select {
+		case s.addressCh <- newEvent:
+		default:
+			s.drop(newEvent, acks)
+		}
+
+	case event.DropOldestPolicy:
+		for {
+			select {
+			case s.addressCh <- newEvent:
+				return
+			default:
+			}
+
+			// make room by discarding the oldest event. the subscriber may
+			// have read it in the meantime, in which case nothing is dropped.
+			select {
+			case oldest := <-s.addressCh:
+				s.drop(oldest, acks)
+			default:
+			}
+		}
+
+	case event.SpillPolicy:
+		s.spillQueue.push(newEvent)
+
+	default:
+		s.addressCh <- newEvent
+	}
+}
+
+func (s *subscriber) drop(droppedEvent *event.Event, acks *acknowledgements) {
+	dropped := s.dropped.Add(1)
+	acks.done(droppedEvent.ID, s, ErrEventDropped)
+
+	// log the first drop and then every 100th so a flood of drops does not
+	// flood the logs too.
+	if dropped == 1 || dropped%100 == 0 {
+		log.Printf(
+			"\033[33m subscriber '%s' is full (%s), dropped event %s. %d event(s) dropped so far\033[0m\n",
+			s.name,
+			s.backpressure,
+			droppedEvent,
+			dropped,
+		)
+	}
+}
+
+// close stops delivering to the addressCh of s, after everything in its spill
+// queue was delivered.
+func (s *subscriber) close() {
+	if s.spillQueue != nil {
+		s.spillQueue.close()
+	}
+}
+
+// spillQueue is an unbounded FIFO queue in front of an addressCh. Events are
+// sent to the addressCh directly while nothing is queued, and queued in order
+// otherwise, so events are never reordered or dropped.
+type spillQueue struct {
+	mu        sync.Mutex
+	addressCh chan<- *event.Event
+	items     []*event.Event
+	signalCh  chan struct{}
+	closed    bool
+	doneCh    chan struct{}
+}
+
+func newSpillQueue(addressCh chan<- *event.Event) *spillQueue {
+	q := &spillQueue{
+		addressCh: addressCh,
+		signalCh:  make(chan struct{}, 1),
+		doneCh:    make(chan struct{}),
+	}
+
+	go q.forward()
+
+	return q
+}
+
+func (q *spillQueue) push(newEvent *event.Event) {
+	q.mu.Lock()
+
+	if len(q.items) == 0 {
+		select {
+		case q.addressCh <- newEvent:
+			q.mu.Unlock()
+			return
+		default:
+		}
+	}
+
+	q.items = append(q.items, newEvent)
+	q.mu.Unlock()
+
+	select {
+	case q.signalCh <- struct{}{}:
+	default:
+	}
+}
+
+func (q *spillQueue) len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	return len(q.items)
+}
+
+// forward sends queued events to the addressCh. An event is only removed from
+// the queue after it was sent, so push never overtakes it.
+func (q *spillQueue) forward() {
+	defer close(q.doneCh)
+
+	for {
+		q.mu.Lock()
+		if len(q.items) == 0 {
+			closed := q.closed
+			q.mu.Unlock()
+
+			if closed {
+				return
+			}
+
+			<-q.signalCh
+			continue
+		}
+		queuedEvent := q.items[0]
+		q.mu.Unlock()
+
+		q.addressCh <- queuedEvent
+
+		q.mu.Lock()
+		q.items[0] = nil
+		q.items = q.items[1:]
+		q.mu.Unlock()
+	}
+}
+
+// close waits until every queued event was sent to the addressCh.
+func (q *spillQueue) close() {
+	q.mu.Lock()
+	q.closed = true
+	q.mu.Unlock()
+
+	select {
+	case q.signalCh <- struct{}{}:
+	default:
+	}
+
+	<-q.doneCh
+}
+
+// SubscriberStats is a snapshot of the backlog of a subscriber of an event
+// and how many events were dropped because its addressCh was full.
+type SubscriberStats struct {
+	EventName      event.EventName      `json:"eventName"`
+	SubscriberName event.SubscriberName `json:"subscriberName"`
+	Backpressure   string               `json:"backpressure"`
+	QueueDepth     int                  `json:"queueDepth"` // events waiting in the addressCh.
+	Spilled        int                  `json:"spilled"`    // events waiting in the spill queue.
+	Dropped        uint64               `json:"dropped"`
+}
+
+// SubscriberStats returns a snapshot of every subscriber of every event and
+// pattern.
+func (e *eventEngine) SubscriberStats() []SubscriberStats {
+	var stats []SubscriberStats
+
+	e.forEachSubscriber(func(toEventName event.EventName, subscriber *subscriber) {
+		subscriberStats := SubscriberStats{
+			EventName:      toEventName,
+			SubscriberName: subscriber.name,
+			Backpressure:   subscriber.backpressure.String(),
+			QueueDepth:     len(subscriber.addressCh),
+			Dropped:        subscriber.dropped.Load(),
+		}
+
+		if subscriber.spillQueue != nil {
+			subscriberStats.Spilled = subscriber.spillQueue.len()
+		}
+
+		stats = append(stats, subscriberStats)
+	})
+
+	return stats
+}
@@ -0,0 +1,262 @@
+package eventengine
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/eng-by-sjb/yellow-pines-e-commerce-backend/internal/eventengine/event"
+	"github.com/google/uuid"
+)
+
+var ErrDeadLetterNotFound = errors.New("dead letter not found")
+
+// DeadLetter is an event a subscriber failed to handle after all attempts of
+// its RetryPolicy.
+type DeadLetter struct {
+	DeadLetterID   uuid.UUID            `json:"deadLetterID"`
+	EventID        uuid.UUID            `json:"eventID"`
+	EventName      event.EventName      `json:"eventName"`
+	EventVersion   uint16               `json:"eventVersion"`
+	OccurredAt     time.Time            `json:"occurredAt"`
+	CorrelationID  string               `json:"correlationID"`
+	CausationID    uuid.UUID            `json:"causationID"`
+	Producer       string               `json:"producer"`
+	SubscriberName event.SubscriberName `json:"subscriberName"`
+	Payload        json.RawMessage      `json:"payload"`
+	Error          string               `json:"error"`
+	Attempts       uint16               `json:"attempts"`
+	FailedAt       time.Time            `json:"failedAt"`
+}
+
+// event rebuilds the envelope of the dead lettered event around payload.
+func (d *DeadLetter) event(payload any) *event.Event {
+	return &event.Event{
+		ID:            d.EventID,
+		Name:          d.EventName,
+		Version:       d.EventVersion,
+		OccurredAt:    d.OccurredAt,
+		CorrelationID: d.CorrelationID,
+		CausationID:   d.CausationID,
+		Producer:      d.Producer,
+		Payload:       payload,
+	}
+}
+
+// DeadLetterStore is where the event engine parks events whose handlers
+// exhausted their retries.
+type DeadLetterStore interface {
+	Add(ctx context.Context, deadLetter *DeadLetter) error
+	List(ctx context.Context) ([]*DeadLetter, error)
+	Get(ctx context.Context, deadLetterID uuid.UUID) (*DeadLetter, error)
+	Delete(ctx context.Context, deadLetterID uuid.UUID) error
+}
+
+// DeadLetterManager lets admins inspect dead letters and hand them back to
+// the subscriber that failed to handle them.
+type DeadLetterManager interface {
+	ListDeadLetters(ctx context.Context) ([]*DeadLetter, error)
+	GetDeadLetter(ctx context.Context, deadLetterID uuid.UUID) (*DeadLetter, error)
+	RedriveDeadLetter(ctx context.Context, deadLetterID uuid.UUID) error
+}
+
+type memoryDeadLetterStore struct {
+	mu          sync.RWMutex
+	deadLetters []*DeadLetter
+}
+
+// NewMemoryDeadLetterStore returns a [DeadLetterStore] that keeps dead letters
+// in memory. They are lost when the server restarts.
+func NewMemoryDeadLetterStore() DeadLetterStore {
+	return &memoryDeadLetterStore{}
+}
+
+func (s *memoryDeadLetterStore) Add(ctx context.Context, deadLetter *DeadLetter) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.deadLetters = append(s.deadLetters, deadLetter)
+	return nil
+}
+
+func (s *memoryDeadLetterStore) List(ctx context.Context) ([]*DeadLetter, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return slices.Clone(s.deadLetters), nil
+}
+
+func (s *memoryDeadLetterStore) Get(ctx context.Context, deadLetterID uuid.UUID) (*DeadLetter, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, deadLetter := range s.deadLetters {
+		if deadLetter.DeadLetterID == deadLetterID {
+			return deadLetter, nil
+		}
+	}
+
+	return nil, ErrDeadLetterNotFound
+}
+
+func (s *memoryDeadLetterStore) Delete(ctx context.Context, deadLetterID uuid.UUID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.deadLetters = slices.DeleteFunc(
+		s.deadLetters,
+		func(deadLetter *DeadLetter) bool {
+			return deadLetter.DeadLetterID == deadLetterID
+		},
+	)
+	return nil
+}
+
+// deadLetter parks failedEvent in the dead letter store after handler failed
+// to handle it attempts times, then calls the handler's OnDeadLetter hook.
+func (e *eventEngine) deadLetter(
+	handler *Handler,
+	failedEvent *event.Event,
+	attempts uint16,
+	failure error,
+) {
+	if handler.metrics != nil {
+		handler.metrics.failed.Add(1)
+	}
+
+	ctx, cancel := context.WithTimeout(
+		event.ContextWithEvent(failedEvent.Context(), failedEvent),
+		(10 * time.Second),
+	)
+	defer cancel()
+
+	rawPayload, err := json.Marshal(failedEvent.Payload)
+	if err != nil {
+		log.Printf(
+			"failed to marshal payload of event %s for the dead letter store, dropping it: %v\n",
+			failedEvent,
+			err,
+		)
+		return
+	}
+
+	deadLetter := &DeadLetter{
+		DeadLetterID:   uuid.New(),
+		EventID:        failedEvent.ID,
+		EventName:      failedEvent.Name,
+		EventVersion:   failedEvent.Version,
+		OccurredAt:     failedEvent.OccurredAt,
+		CorrelationID:  failedEvent.CorrelationID,
+		CausationID:    failedEvent.CausationID,
+		Producer:       failedEvent.Producer,
+		SubscriberName: handler.SubscriberName,
+		Payload:        rawPayload,
+		Error:          failure.Error(),
+		Attempts:       attempts,
+		FailedAt:       time.Now().UTC(),
+	}
+
+	if err := e.DeadLetterStore.Add(ctx, deadLetter); err != nil {
+		log.Printf(
+			"failed to add event %s of subscriber '%s' to the dead letter store: %v\n",
+			failedEvent,
+			handler.SubscriberName,
+			err,
+		)
+	}
+
+	log.Printf(
+		"\033[31m subscriber '%s' failed to handle event %s after %d attempt(s), dead letter '%s': %v\033[0m\n",
+		handler.SubscriberName,
+		failedEvent,
+		attempts,
+		deadLetter.DeadLetterID,
+		failure,
+	)
+
+	if handler.OnDeadLetter != nil {
+		if err := handler.OnDeadLetter(ctx, failedEvent.Payload, failure); err != nil {
+			log.Printf(
+				"OnDeadLetter of subscriber '%s' failed for event %s: %v\n",
+				handler.SubscriberName,
+				failedEvent,
+				err,
+			)
+		}
+	}
+}
+
+func (e *eventEngine) ListDeadLetters(ctx context.Context) ([]*DeadLetter, error) {
+	return e.DeadLetterStore.List(ctx)
+}
+
+func (e *eventEngine) GetDeadLetter(ctx context.Context, deadLetterID uuid.UUID) (*DeadLetter, error) {
+	return e.DeadLetterStore.Get(ctx, deadLetterID)
+}
+
+// RedriveDeadLetter hands a dead letter back to the subscriber that failed to
+// handle it, using the subscriber's RetryPolicy. The dead letter is removed
+// once the subscriber handled it, otherwise it is kept and the error of the
+// last attempt is returned.
+func (e *eventEngine) RedriveDeadLetter(ctx context.Context, deadLetterID uuid.UUID) error {
+	deadLetter, err := e.DeadLetterStore.Get(ctx, deadLetterID)
+	if err != nil {
+		return err
+	}
+
+	handler, ok := e.findHandler(deadLetter.EventName, deadLetter.SubscriberName)
+	if !ok {
+		return fmt.Errorf(
+			"subscriber '%s' is not subscribed to event '%s' anymore",
+			deadLetter.SubscriberName,
+			deadLetter.EventName,
+		)
+	}
+
+	payload, version, err := e.decodePayload(deadLetter.EventName, deadLetter.EventVersion, deadLetter.Payload, handler.PayloadType)
+	if err != nil {
+		return err
+	}
+
+	redrivenEvent := deadLetter.event(payload)
+	redrivenEvent.Version = version
+
+	if _, err := e.handleWithRetry(ctx, handler, redrivenEvent); err != nil {
+		return fmt.Errorf(
+			"subscriber '%s' failed to handle redriven dead letter '%s': %w",
+			deadLetter.SubscriberName,
+			deadLetterID,
+			err,
+		)
+	}
+
+	return e.DeadLetterStore.Delete(ctx, deadLetterID)
+}
+
+// findHandler returns the handler subscriberName handles eventName with,
+// either subscribed to eventName itself or to a pattern matching it.
+func (e *eventEngine) findHandler(eventName event.EventName, subscriberName event.SubscriberName) (*Handler, bool) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	handler, ok := e.handlers[handlerKey{
+		eventName:      eventName,
+		subscriberName: subscriberName,
+	}]
+	if ok {
+		return handler, true
+	}
+
+	for key, handler := range e.handlers {
+		if key.subscriberName == subscriberName && IsPattern(key.eventName) && matchEventName(key.eventName, eventName) {
+			return handler, true
+		}
+	}
+
+	return nil, false
+}
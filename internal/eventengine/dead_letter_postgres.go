@@ -0,0 +1,146 @@
+package eventengine
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+)
+
+const (
+	deadLetterFields = "dead_letter_id, event_id, event_name, event_version, occurred_at, correlation_id, causation_id, producer, subscriber_name, payload, error, attempts, failed_at"
+)
+
+type postgresDeadLetterStore struct {
+	db *sql.DB
+}
+
+// NewPostgresDeadLetterStore returns a [DeadLetterStore] backed by the
+// dead_letters table, so dead letters survive restarts.
+func NewPostgresDeadLetterStore(db *sql.DB) DeadLetterStore {
+	return &postgresDeadLetterStore{
+		db: db,
+	}
+}
+
+func (s *postgresDeadLetterStore) Add(ctx context.Context, deadLetter *DeadLetter) error {
+	_, err := s.db.ExecContext(
+		ctx,
+		fmt.Sprintf(
+			"INSERT INTO dead_letters(%s) VALUES($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)",
+			deadLetterFields,
+		),
+		deadLetter.DeadLetterID,
+		deadLetter.EventID,
+		deadLetter.EventName,
+		deadLetter.EventVersion,
+		deadLetter.OccurredAt,
+		deadLetter.CorrelationID,
+		deadLetter.CausationID,
+		deadLetter.Producer,
+		deadLetter.SubscriberName,
+		[]byte(deadLetter.Payload),
+		deadLetter.Error,
+		deadLetter.Attempts,
+		deadLetter.FailedAt,
+	)
+	if err != nil {
+		return fmt.Errorf(
+			"failed to insert dead letter in dead letter store: %w",
+			err,
+		)
+	}
+
+	return nil
+}
+
+func (s *postgresDeadLetterStore) List(ctx context.Context) ([]*DeadLetter, error) {
+	query := fmt.Sprintf("SELECT %s FROM dead_letters ORDER BY failed_at DESC", deadLetterFields)
+	rows, err := s.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf(
+			"failed to query dead letters in dead letter store: %w",
+			err,
+		)
+	}
+	defer rows.Close()
+
+	deadLetters := []*DeadLetter{}
+	for rows.Next() {
+		deadLetter, err := scanRowIntoDeadLetter(rows)
+		if err != nil {
+			return nil, err
+		}
+		deadLetters = append(deadLetters, deadLetter)
+	}
+
+	return deadLetters, rows.Err()
+}
+
+func (s *postgresDeadLetterStore) Get(ctx context.Context, deadLetterID uuid.UUID) (*DeadLetter, error) {
+	query := fmt.Sprintf("SELECT %s FROM dead_letters WHERE dead_letter_id = $1", deadLetterFields)
+	deadLetter, err := scanRowIntoDeadLetter(
+		s.db.QueryRowContext(ctx, query, deadLetterID),
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrDeadLetterNotFound
+		}
+		return nil, err
+	}
+
+	return deadLetter, nil
+}
+
+func (s *postgresDeadLetterStore) Delete(ctx context.Context, deadLetterID uuid.UUID) error {
+	_, err := s.db.ExecContext(
+		ctx,
+		"DELETE FROM dead_letters WHERE dead_letter_id = $1",
+		deadLetterID,
+	)
+	if err != nil {
+		return fmt.Errorf(
+			"failed to delete dead letter from dead letter store: %w",
+			err,
+		)
+	}
+
+	return nil
+}
+
+// rowScanner is implemented by both [sql.Row] and [sql.Rows].
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanRowIntoDeadLetter(row rowScanner) (*DeadLetter, error) {
+	deadLetter := new(DeadLetter)
+	var payload []byte
+
+	err := row.Scan(
+		&deadLetter.DeadLetterID,
+		&deadLetter.EventID,
+		&deadLetter.EventName,
+		&deadLetter.EventVersion,
+		&deadLetter.OccurredAt,
+		&deadLetter.CorrelationID,
+		&deadLetter.CausationID,
+		&deadLetter.Producer,
+		&deadLetter.SubscriberName,
+		&payload,
+		&deadLetter.Error,
+		&deadLetter.Attempts,
+		&deadLetter.FailedAt,
+	)
+	if err != nil {
+		return nil, fmt.Errorf(
+			"failed to scan row into dead letter in dead letter store: %w",
+			err,
+		)
+	}
+	deadLetter.Payload = payload
+
+	return deadLetter, nil
+}
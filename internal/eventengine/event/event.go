@@ -1,14 +1,158 @@
 package event
 
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
 type SubscriberName string
 type EventName string
 
+// CurrentVersion is the schema version events are published with unless
+// they set their own.
+const CurrentVersion uint16 = 1
+
+// Event is the envelope every payload is published and delivered in.
 type Event struct {
-	Name    EventName
-	Payload any
+	ID            uuid.UUID // Unique id of this event. Redeliveries of the event keep it.
+	Name          EventName
+	Version       uint16    // Schema version of Payload.
+	OccurredAt    time.Time // When the event happened, not when it was delivered.
+	CorrelationID string    // Id of the request or workflow the event is part of, e.g. the id of the originating http request.
+	CausationID   uuid.UUID // Id of the event that caused this event to be published, if any.
+	Producer      string    // Name of the feature or subscriber that published the event.
+	Sequence      uint64    // Position of the event in the event log, if the event engine records events. Set by the event engine.
+	Payload       any
+
+	// ctx is the context the event was created from, detached from its
+	// cancellation, see Context.
+	ctx context.Context
+}
+
+// New returns an event for payload as part of whatever ctx is part of.
+//
+// If ctx is the context of a subscriber handling an event, the new event is
+// caused by that event and shares its correlation id. Otherwise the
+// correlation id is the one set with [ContextWithCorrelationID], e.g. the id
+// of the http request.
+//
+// The values of ctx, e.g. the request id or the id of the admin who made the
+// request, are handed on to the subscribers of the event, see Context.
+func New(ctx context.Context, producer string, name EventName, payload any) *Event {
+	newEvent := &Event{
+		ID:            uuid.New(),
+		Name:          name,
+		Version:       CurrentVersion,
+		OccurredAt:    time.Now().UTC(),
+		CorrelationID: CorrelationIDFromContext(ctx),
+		Producer:      producer,
+		Payload:       payload,
+		ctx:           context.WithoutCancel(ctx),
+	}
+
+	if parentEvent, ok := FromContext(ctx); ok {
+		newEvent.CausationID = parentEvent.ID
+		newEvent.CorrelationID = parentEvent.CorrelationID
+	}
+
+	return newEvent
+}
+
+// Context returns the context e was created from with [New]. It carries the
+// values of that context but is never canceled, as the request that
+// published e usually ends before e is handled. It returns
+// [context.Background] if e was not created with New, e.g. when it was
+// received from another instance of the server or read from a store.
+func (e *Event) Context() context.Context {
+	if e.ctx == nil {
+		return context.Background()
+	}
+
+	return e.ctx
+}
+
+// String formats the envelope of e, without its payload, for logs.
+func (e *Event) String() string {
+	causationID := "none"
+	if e.CausationID != uuid.Nil {
+		causationID = e.CausationID.String()
+	}
+
+	return fmt.Sprintf(
+		"%s(id=%s v%d producer=%s correlation=%s causation=%s occurredAt=%s)",
+		e.Name,
+		e.ID,
+		e.Version,
+		e.Producer,
+		e.CorrelationID,
+		causationID,
+		e.OccurredAt.Format(time.RFC3339Nano),
+	)
+}
+
+type eventContextKey struct{}
+type correlationIDContextKey struct{}
+
+// ContextWithEvent returns a copy of ctx carrying e. The event engine passes
+// such a context to the handler of e.
+func ContextWithEvent(ctx context.Context, e *Event) context.Context {
+	return context.WithValue(ctx, eventContextKey{}, e)
+}
+
+// FromContext returns the event ctx carries, if any.
+func FromContext(ctx context.Context) (*Event, bool) {
+	e, ok := ctx.Value(eventContextKey{}).(*Event)
+	return e, ok
+}
+
+// ContextWithCorrelationID returns a copy of ctx carrying correlationID, which
+// events created with [New] from it are correlated by.
+func ContextWithCorrelationID(ctx context.Context, correlationID string) context.Context {
+	return context.WithValue(ctx, correlationIDContextKey{}, correlationID)
+}
+
+// CorrelationIDFromContext returns the correlation id ctx carries or an empty
+// string.
+func CorrelationIDFromContext(ctx context.Context) string {
+	correlationID, _ := ctx.Value(correlationIDContextKey{}).(string)
+	return correlationID
+}
+
+// BackpressurePolicy is what the event engine does when the addressCh of a
+// subscriber is full.
+type BackpressurePolicy uint8
+
+const (
+	BlockPolicy            BackpressurePolicy = iota // wait until the subscriber reads from its addressCh. This is the default.
+	BlockWithTimeoutPolicy                           // wait up to the subscriber's BlockTimeout, then drop the event.
+	DropNewestPolicy                                 // drop the event being published.
+	DropOldestPolicy                                 // drop the oldest event in the addressCh to make room for the event being published.
+	SpillPolicy                                      // keep the event in an unbounded overflow queue until the addressCh has room.
+)
+
+func (p BackpressurePolicy) String() string {
+	switch p {
+	case BlockPolicy:
+		return "block"
+	case BlockWithTimeoutPolicy:
+		return "block-with-timeout"
+	case DropNewestPolicy:
+		return "drop-newest"
+	case DropOldestPolicy:
+		return "drop-oldest"
+	case SpillPolicy:
+		return "spill"
+	default:
+		return fmt.Sprintf("BackpressurePolicy(%d)", uint8(p))
+	}
 }
 
 type Subscriber struct {
-	Name      SubscriberName // Name of subscriber
-	AddressCh chan<- any     // Where a subscriber is listening for events at.
+	Name         SubscriberName     // Name of subscriber
+	AddressCh    chan *Event        // Where a subscriber is listening for events at.
+	Backpressure BackpressurePolicy // What to do when AddressCh is full.
+	BlockTimeout time.Duration      // How long to wait when Backpressure is BlockWithTimeoutPolicy.
 }
@@ -3,9 +3,18 @@ package event
 import "github.com/google/uuid"
 
 const (
+	InventoryCreatedEventName        EventName = "inventory.created"
 	InventoryCreationFailedEventName EventName = "inventory.creation.failed"
 )
 
+type InventoryCreatedEvent struct {
+	ProductID uuid.UUID
+}
+
+func (e *InventoryCreatedEvent) GetEventName() EventName {
+	return InventoryCreatedEventName
+}
+
 type InventoryCreationFailedEvent struct {
 	ProductID uuid.UUID
 }
@@ -40,9 +40,12 @@ func (e ProductQuantityUpdatedEvent) EventName() EventName {
 	return ProductUpdatedQuantityEventName
 }
 
+// ProductDeletedEvent is published once a product is moved to the trash, and
+// again once it is purged from the trash for good.
 type ProductDeletedEvent struct {
-	Name      EventName
-	ProductID uuid.UUID
+	Name        EventName
+	ProductID   uuid.UUID
+	Permanently bool // set once the product was purged, after which it can not be restored anymore.
 }
 
 func (e ProductDeletedEvent) EventName() EventName {
@@ -0,0 +1,301 @@
+package event
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"sync"
+)
+
+var (
+	// ErrSchemaNotFound is returned for an event name no schema was
+	// registered for.
+	ErrSchemaNotFound = errors.New("no schema registered for the event")
+	// ErrSchemaVersion is returned for a payload of a version that can not be
+	// upcast to the current version of its schema, e.g. because an upcaster
+	// is missing or the payload was published by a newer instance of the
+	// server.
+	ErrSchemaVersion = errors.New("payload version can not be upcast to the current schema version")
+)
+
+// Upcaster transforms the JSON of a payload of one version into the JSON of
+// the next version of its schema, e.g. by filling in a field the next version
+// added.
+type Upcaster func(raw json.RawMessage) (json.RawMessage, error)
+
+// SchemaRegistry knows the payload type of every version of the events
+// registered with it, and how to upcast a payload of an old version to the
+// current version. It encodes payloads as JSON, so events can be stored and
+// sent to other instances of the server, and decodes them back into the
+// payload type of the current version, however old they are.
+//
+//	registry := event.NewSchemaRegistry()
+//	event.RegisterSchema[*event.ProductCreatedEvent](registry, event.ProductCreatedEventName, 1)
+//
+// When a payload gains a field, the new shape is registered as the next
+// version, together with an upcaster from the previous one:
+//
+//	event.RegisterSchema[*ProductCreatedEventV2](registry, event.ProductCreatedEventName, 2)
+//	registry.RegisterUpcaster(event.ProductCreatedEventName, 1, addCurrency) // v1 -> v2
+//
+// It is safe to use from several go routines.
+type SchemaRegistry struct {
+	mu      sync.RWMutex
+	schemas map[EventName]*schema
+}
+
+// schema is every version of the payload of an event.
+type schema struct {
+	current   uint16                  // the highest version registered, which payloads are decoded into.
+	types     map[uint16]reflect.Type // the payload type of every version.
+	upcasters map[uint16]Upcaster     // the upcaster from every version to the next one.
+}
+
+// SchemaVersion is a version of the payload of an event, see
+// SchemaRegistry.Schemas.
+type SchemaVersion struct {
+	EventName   EventName `json:"eventName"`
+	Version     uint16    `json:"version"`
+	PayloadType string    `json:"payloadType"`
+	Current     bool      `json:"current"`
+	Upcastable  bool      `json:"upcastable"` // whether a payload of this version can be upcast to the current version.
+}
+
+func NewSchemaRegistry() *SchemaRegistry {
+	return &SchemaRegistry{
+		schemas: make(map[EventName]*schema, 20),
+	}
+}
+
+// RegisterSchema registers T as the payload type of version of eventName. T
+// is the type the payload is published with, usually a pointer, e.g.
+// *ProductCreatedEvent. The highest version registered for eventName is its
+// current version.
+func RegisterSchema[T any](r *SchemaRegistry, eventName EventName, version uint16) error {
+	return r.register(eventName, version, reflect.TypeFor[T]())
+}
+
+func (r *SchemaRegistry) register(eventName EventName, version uint16, payloadType reflect.Type) error {
+	if version == 0 {
+		return fmt.Errorf("version of the schema of event '%s' must be at least 1", eventName)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	s, ok := r.schemas[eventName]
+	if !ok {
+		s = &schema{
+			types:     make(map[uint16]reflect.Type),
+			upcasters: make(map[uint16]Upcaster),
+		}
+		r.schemas[eventName] = s
+	}
+
+	if registered, ok := s.types[version]; ok {
+		return fmt.Errorf(
+			"version %d of event '%s' is already registered with '%v'",
+			version,
+			eventName,
+			registered,
+		)
+	}
+
+	s.types[version] = payloadType
+	s.current = max(s.current, version)
+
+	return nil
+}
+
+// RegisterUpcaster registers upcast as the upcaster of the payloads of
+// eventName from fromVersion to fromVersion+1.
+func (r *SchemaRegistry) RegisterUpcaster(eventName EventName, fromVersion uint16, upcast Upcaster) error {
+	if upcast == nil {
+		return fmt.Errorf("upcaster of event '%s' from version %d is nil", eventName, fromVersion)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	s, ok := r.schemas[eventName]
+	if !ok {
+		return fmt.Errorf("%w: '%s'", ErrSchemaNotFound, eventName)
+	}
+
+	if _, ok := s.upcasters[fromVersion]; ok {
+		return fmt.Errorf(
+			"upcaster of event '%s' from version %d is already registered",
+			eventName,
+			fromVersion,
+		)
+	}
+
+	s.upcasters[fromVersion] = upcast
+
+	return nil
+}
+
+// Current returns the current version of eventName and its payload type. It
+// reports false if no schema was registered for eventName.
+func (r *SchemaRegistry) Current(eventName EventName) (uint16, reflect.Type, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	s, ok := r.schemas[eventName]
+	if !ok {
+		return 0, nil, false
+	}
+
+	return s.current, s.types[s.current], true
+}
+
+// Encode returns the JSON of payload, together with the version of eventName
+// its type is registered for. It fails if payload is not of the current
+// payload type of eventName, so only current payloads are stored or sent.
+func (r *SchemaRegistry) Encode(eventName EventName, payload any) (json.RawMessage, uint16, error) {
+	version, payloadType, ok := r.Current(eventName)
+	if !ok {
+		return nil, 0, fmt.Errorf("%w: '%s'", ErrSchemaNotFound, eventName)
+	}
+
+	if reflect.TypeOf(payload) != payloadType {
+		return nil, 0, fmt.Errorf(
+			"payload of event '%s' is '%T' but version %d of its schema is '%v'",
+			eventName,
+			payload,
+			version,
+			payloadType,
+		)
+	}
+
+	raw, err := json.Marshal(payload)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to marshal payload of event '%s': %w", eventName, err)
+	}
+
+	return raw, version, nil
+}
+
+// Decode upcasts raw, the JSON of a payload of version of eventName, to the
+// current version of eventName, and unmarshals it into the current payload
+// type. It returns the payload together with the current version.
+//
+// A version of 0 is taken as version 1, the version of events published
+// before they were versioned.
+func (r *SchemaRegistry) Decode(eventName EventName, version uint16, raw json.RawMessage) (any, uint16, error) {
+	r.mu.RLock()
+	s, ok := r.schemas[eventName]
+	if !ok {
+		r.mu.RUnlock()
+		return nil, 0, fmt.Errorf("%w: '%s'", ErrSchemaNotFound, eventName)
+	}
+
+	current, payloadType := s.current, s.types[s.current]
+
+	version = max(version, 1)
+	if version > current {
+		r.mu.RUnlock()
+		return nil, 0, fmt.Errorf(
+			"%w: event '%s' has version %d, but the current version is %d",
+			ErrSchemaVersion,
+			eventName,
+			version,
+			current,
+		)
+	}
+
+	upcasters := make([]Upcaster, 0, current-version)
+	for v := version; v < current; v++ {
+		upcast, ok := s.upcasters[v]
+		if !ok {
+			r.mu.RUnlock()
+			return nil, 0, fmt.Errorf(
+				"%w: no upcaster of event '%s' from version %d",
+				ErrSchemaVersion,
+				eventName,
+				v,
+			)
+		}
+		upcasters = append(upcasters, upcast)
+	}
+	r.mu.RUnlock()
+
+	for i, upcast := range upcasters {
+		var err error
+		if raw, err = upcast(raw); err != nil {
+			return nil, 0, fmt.Errorf(
+				"failed to upcast event '%s' from version %d: %w",
+				eventName,
+				version+uint16(i),
+				err,
+			)
+		}
+	}
+
+	payload, err := UnmarshalPayload(raw, payloadType)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to decode payload of event '%s': %w", eventName, err)
+	}
+
+	return payload, current, nil
+}
+
+// Schemas returns every version of every event registered, ordered by event
+// name and version.
+func (r *SchemaRegistry) Schemas() []SchemaVersion {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var versions []SchemaVersion
+	for eventName, s := range r.schemas {
+		for version, payloadType := range s.types {
+			upcastable := true
+			for v := version; v < s.current; v++ {
+				if _, ok := s.upcasters[v]; !ok {
+					upcastable = false
+					break
+				}
+			}
+
+			versions = append(versions, SchemaVersion{
+				EventName:   eventName,
+				Version:     version,
+				PayloadType: payloadType.String(),
+				Current:     version == s.current,
+				Upcastable:  upcastable,
+			})
+		}
+	}
+
+	sort.Slice(versions, func(i, j int) bool {
+		if versions[i].EventName != versions[j].EventName {
+			return versions[i].EventName < versions[j].EventName
+		}
+		return versions[i].Version < versions[j].Version
+	})
+
+	return versions
+}
+
+// UnmarshalPayload unmarshals raw into a new value of payloadType. If
+// payloadType is a pointer, a pointer to a new value of the type it points to
+// is returned.
+func UnmarshalPayload(raw json.RawMessage, payloadType reflect.Type) (any, error) {
+	if payloadType.Kind() == reflect.Pointer {
+		payload := reflect.New(payloadType.Elem())
+		if err := json.Unmarshal(raw, payload.Interface()); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal payload into '%v': %w", payloadType, err)
+		}
+
+		return payload.Interface(), nil
+	}
+
+	payload := reflect.New(payloadType)
+	if err := json.Unmarshal(raw, payload.Interface()); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal payload into '%v': %w", payloadType, err)
+	}
+
+	return payload.Elem().Interface(), nil
+}
@@ -0,0 +1,93 @@
+package event
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func Test_SchemaRegistry(t *testing.T) {
+	type testPayloadV1 struct {
+		Name string `json:"name"`
+	}
+	type testPayloadV2 struct {
+		Name     string `json:"name"`
+		Currency string `json:"currency"`
+	}
+
+	var eventName EventName = "test.event.schema"
+
+	r := NewSchemaRegistry()
+	if err := RegisterSchema[*testPayloadV1](r, eventName, 1); err != nil {
+		t.Fatalf("expected to register version 1, got: %v", err)
+	}
+	if err := RegisterSchema[*testPayloadV2](r, eventName, 2); err != nil {
+		t.Fatalf("expected to register version 2, got: %v", err)
+	}
+	if err := RegisterSchema[*testPayloadV2](r, eventName, 2); err == nil {
+		t.Fatal("expected registering version 2 again to fail")
+	}
+
+	// version 1 can not be decoded until there is an upcaster to version 2.
+	if _, _, err := r.Decode(eventName, 1, json.RawMessage(`{"name":"pine"}`)); !errors.Is(err, ErrSchemaVersion) {
+		t.Fatalf("expected '%v', got: %v", ErrSchemaVersion, err)
+	}
+
+	err := r.RegisterUpcaster(eventName, 1, func(raw json.RawMessage) (json.RawMessage, error) {
+		var fields map[string]any
+		if err := json.Unmarshal(raw, &fields); err != nil {
+			return nil, err
+		}
+		fields["currency"] = "USD"
+		return json.Marshal(fields)
+	})
+	if err != nil {
+		t.Fatalf("expected to register the upcaster, got: %v", err)
+	}
+
+	payload, version, err := r.Decode(eventName, 1, json.RawMessage(`{"name":"pine"}`))
+	if err != nil {
+		t.Fatalf("expected to decode version 1, got: %v", err)
+	}
+	if upcast, ok := payload.(*testPayloadV2); !ok || version != 2 || upcast.Name != "pine" || upcast.Currency != "USD" {
+		t.Fatalf("expected version 1 to be upcast to version 2, got: v%d %#v", version, payload)
+	}
+
+	raw, version, err := r.Encode(eventName, &testPayloadV2{Name: "pine", Currency: "EUR"})
+	if err != nil || version != 2 {
+		t.Fatalf("expected to encode version 2, got: v%d %v", version, err)
+	}
+
+	payload, _, err = r.Decode(eventName, version, raw)
+	if err != nil {
+		t.Fatalf("expected to decode version 2, got: %v", err)
+	}
+	if decoded := payload.(*testPayloadV2); decoded.Currency != "EUR" {
+		t.Fatalf("expected the encoded payload back, got: %#v", decoded)
+	}
+
+	if _, _, err := r.Encode(eventName, &testPayloadV1{}); err == nil {
+		t.Fatal("expected encoding an old payload type to fail")
+	}
+
+	if _, _, err := r.Decode(eventName, 3, raw); !errors.Is(err, ErrSchemaVersion) {
+		t.Fatalf("expected a version newer than the current one to fail with '%v', got: %v", ErrSchemaVersion, err)
+	}
+
+	if _, _, err := r.Decode("test.event.schema.unknown", 1, raw); !errors.Is(err, ErrSchemaNotFound) {
+		t.Fatalf("expected '%v', got: %v", ErrSchemaNotFound, err)
+	}
+}
+
+func Test_NewSchemas(t *testing.T) {
+	r, err := NewSchemas()
+	if err != nil {
+		t.Fatalf("expected to register the schemas, got: %v", err)
+	}
+
+	for _, schemaVersion := range r.Schemas() {
+		if !schemaVersion.Upcastable {
+			t.Fatalf("expected every version to be upcastable to the current one, got: %+v", schemaVersion)
+		}
+	}
+}
@@ -0,0 +1,32 @@
+package event
+
+import (
+	"errors"
+	"fmt"
+)
+
+// NewSchemas returns a [SchemaRegistry] with the schema of every event the
+// features publish.
+//
+// A payload that changes shape gets a new version here, together with an
+// upcaster from the previous version, so the events stored or published by
+// older instances of the server are still decoded into the current shape.
+// A field can only be added without a new version if its zero value is what
+// old payloads mean.
+func NewSchemas() (*SchemaRegistry, error) {
+	r := NewSchemaRegistry()
+
+	err := errors.Join(
+		RegisterSchema[*ProductCreatedEvent](r, ProductCreatedEventName, 1),
+		RegisterSchema[*ProductUpdatedEvent](r, ProductUpdatedEventName, 1),
+		RegisterSchema[*ProductQuantityUpdatedEvent](r, ProductUpdatedQuantityEventName, 1),
+		RegisterSchema[*ProductDeletedEvent](r, ProductDeletedEventName, 1),
+		RegisterSchema[*InventoryCreatedEvent](r, InventoryCreatedEventName, 1),
+		RegisterSchema[*InventoryCreationFailedEvent](r, InventoryCreationFailedEventName, 1),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to register the event schemas: %w", err)
+	}
+
+	return r, nil
+}
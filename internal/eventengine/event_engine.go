@@ -1,20 +1,29 @@
 package eventengine
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log"
-	"runtime"
+	"reflect"
 	"sync"
+	"time"
 
 	"github.com/eng-by-sjb/yellow-pines-e-commerce-backend/internal/eventengine/event"
+	"github.com/google/uuid"
 )
 
 type Publisher interface {
 	Publish(event *event.Event) error // should take in an event, and add to the events map
+	PublishContext(ctx context.Context, event *event.Event) error
+	PublishBatch(ctx context.Context, events ...*event.Event) error
+	PublishAndWait(ctx context.Context, event *event.Event) error
 }
 
 type Subscriber interface {
-	Subscribe(toEventName event.EventName, subscriber *event.Subscriber) error // should add an event if does not exist and add a yourEventListenerAddressCh to that event
+	Subscribe(toEventName event.EventName, subscriber *event.Subscriber) (CancelFunc, error) // should add an event if does not exist and add a yourEventListenerAddressCh to that event
+	SubscribeHandler(toEventName event.EventName, handler *Handler) (CancelFunc, error)      // like Subscribe, but the event engine reads the addressCh and calls the handler.
+	Unsubscribe(toEventName event.EventName, subscriberName event.SubscriberName) error      // removes the subscriptions of a subscriber to an event, see CancelFunc.
 }
 
 type RegisterPublisher interface {
@@ -27,26 +36,74 @@ type SubscribeRegisterPublisher interface {
 	RegisterPublisher
 }
 
+// Engine is everything the event engine offers to the server.
+type Engine interface {
+	SubscribeRegisterPublisher
+	DeadLetterManager
+	Replayer
+	Scheduler
+	Inspector
+	SubscriberStats() []SubscriberStats
+	SubscriberHealth() []SubscriberHealth
+}
+
 type subscribers struct {
-	names      []*event.SubscriberName
-	addressChs []chan<- any
+	list []*subscriber
+}
+
+// ErrPayloadTypeMismatch is returned when an event is published or subscribed
+// to with a payload type other than the one its event name is bound to.
+var ErrPayloadTypeMismatch = errors.New("payload type does not match the type bound to the event")
+
+// handlerKey identifies the [Handler] of a subscriber for an event.
+type handlerKey struct {
+	eventName      event.EventName
+	subscriberName event.SubscriberName
 }
 
 type EventEngineConfig struct {
-	DoneCh        <-chan struct{}
-	InternalSrvWG *sync.WaitGroup
+	DoneCh          <-chan struct{}
+	InternalSrvWG   *sync.WaitGroup
+	DeadLetterStore DeadLetterStore       // Where events are parked once a handler exhausted its retries. Defaults to an in-memory store.
+	EventStore      EventStore            // Where every published event is recorded, so it can be replayed. Optional, events are not recorded without it.
+	Transport       Transport             // What carries published events to the event engines broadcasting them. Defaults to an in-process channel.
+	ScheduleStore   ScheduleStore         // Where events published with PublishAt or PublishAfter wait until they are due. Defaults to an in-memory store.
+	Schemas         *event.SchemaRegistry // The payload type of every version of the events, which recorded and received payloads are upcast and decoded with. Optional, payloads are decoded into the type their event is bound to without it.
+
+	// ShutdownGracePeriod is how long handlers may keep handling events once
+	// DoneCh is closed, before their contexts are canceled. Defaults to
+	// DefaultShutdownGracePeriod.
+	ShutdownGracePeriod time.Duration
 }
 
+// DefaultShutdownGracePeriod is the ShutdownGracePeriod of event engines that
+// do not set one.
+const DefaultShutdownGracePeriod = 10 * time.Second
+
+// eventEngine is safe to register events, subscribe and unsubscribe at any
+// time while it is running. mu guards the maps of subscriptions, which the
+// broadcaster only reads.
 type eventEngine struct {
 	*EventEngineConfig
-	wg            sync.WaitGroup
-	eventEngineCh chan *event.Event                // This is what the event engine listens to for events being published.
-	events        map[event.EventName]*subscribers // This is where all events are kept, and subscribers whom have subscribed to that event. //todo: maybe add a Queue System in each event data
+	ctx              context.Context // Parent of the contexts of handlers. It is canceled once the ShutdownGracePeriod passed after DoneCh closed.
+	wheel            *timerWheel     // Wakes the dispatcher of scheduled events up once one is due.
+	dispatcherDoneCh chan struct{}   // Closed once the dispatcher of scheduled events stopped.
+	published        publishedCounter
+	gate             publishGate      // Lets publishes in until the event engine shuts down.
+	acks             acknowledgements // The events PublishAndWait waits for.
+	handlersWG       sync.WaitGroup   // Waits for the handlers of typed subscribers to return.
+	closedCh         chan struct{}    // Closed once closed is set.
+	mu               sync.RWMutex
+	closed           bool                                // set once the subscribers are shut down, after which nothing can subscribe.
+	events           map[event.EventName]*subscribers    // This is where all events are kept, and subscribers whom have subscribed to that event. //todo: maybe add a Queue System in each event data
+	patterns         map[event.EventName]*subscribers    // This is where subscribers to a family of events are kept, by the pattern they subscribed with e.g. "product.*".
+	payloadTypes     map[event.EventName]reflect.Type    // This is the payload type each event name is bound to by its typed subscribers.
+	handlers         map[handlerKey]*Handler             // This is where the handlers of typed subscribers are kept, e.g. to redrive dead letters.
+	addressChs       map[chan *event.Event]*addressChRef // This is how many subscriptions use each addressCh, so it is closed after the last one.
 	// events        map[string][]*event.Subscriber // This is where all events are kept, and a slice of Subscribers whom have subscribed to that event. //todo: maybe add a Queue System in each event data
-	// ctx               *events.Context // todo: i don't remember why i had ctx here. i know it is important
 }
 
-func NewEventEngine(cfg *EventEngineConfig) SubscribeRegisterPublisher {
+func NewEventEngine(cfg *EventEngineConfig) Engine {
 	if cfg == nil {
 		log.Fatalln("'eventEngineConfig' can not be nil")
 	}
@@ -55,39 +112,111 @@ func NewEventEngine(cfg *EventEngineConfig) SubscribeRegisterPublisher {
 		log.Fatalln("either DoneCh or InternalSrvWG is nil")
 	}
 
+	if cfg.DeadLetterStore == nil {
+		cfg.DeadLetterStore = NewMemoryDeadLetterStore()
+	}
+
+	if cfg.Transport == nil {
+		cfg.Transport = NewChannelTransport(20)
+	}
+
+	if cfg.ScheduleStore == nil {
+		cfg.ScheduleStore = NewMemoryScheduleStore()
+	}
+
+	if cfg.ShutdownGracePeriod <= 0 {
+		cfg.ShutdownGracePeriod = DefaultShutdownGracePeriod
+	}
+
+	ctx, cancelHandlers := context.WithCancel(context.Background())
+
 	e := &eventEngine{
 		EventEngineConfig: cfg,
+		ctx:               ctx,
+		wheel:             newTimerWheel(scheduleTick, scheduleSlots),
+		dispatcherDoneCh:  make(chan struct{}),
+		closedCh:          make(chan struct{}),
 		events:            make(map[event.EventName]*subscribers, 20),
-		eventEngineCh:     make(chan *event.Event, 20),
+		patterns:          make(map[event.EventName]*subscribers, 20),
+		payloadTypes:      make(map[event.EventName]reflect.Type, 20),
+		handlers:          make(map[handlerKey]*Handler, 20),
+		addressChs:        make(map[chan *event.Event]*addressChRef, 20),
 	}
 
 	e.InternalSrvWG.Add(1)
 	go e.listen()
 
+	go e.dispatchScheduled()
+
+	e.InternalSrvWG.Add(1)
+	go e.cancelHandlersAfterShutdown(cancelHandlers)
+
 	return e
 }
 
+// cancelHandlersAfterShutdown cancels the contexts of the handlers still
+// handling an event once the ShutdownGracePeriod passed after DoneCh closed,
+// so a slow handler can not hold up the shutdown of the server. It returns
+// early once every handler returned.
+func (e *eventEngine) cancelHandlersAfterShutdown(cancelHandlers context.CancelFunc) {
+	defer e.InternalSrvWG.Done()
+	defer cancelHandlers()
+
+	<-e.DoneCh
+
+	timer := time.NewTimer(e.ShutdownGracePeriod)
+	defer timer.Stop()
+
+	// no handler is started once the event engine is closed, so the handlers
+	// are only waited for from then on.
+	handlersDoneCh := make(chan struct{})
+	go func() {
+		<-e.closedCh
+		e.handlersWG.Wait()
+		close(handlersDoneCh)
+	}()
+
+	select {
+	case <-handlersDoneCh:
+	case <-timer.C:
+	}
+}
+
 func (e *eventEngine) listen() {
 	defer e.InternalSrvWG.Done()
 
-	if e.eventEngineCh == nil {
-		log.Fatalln("eventEngineCh is nil")
+	if e.Transport == nil {
+		log.Fatalln("Transport is nil")
 	}
 
 	log.Println("event engine is listening...")
 
-	// e.publishWorkers() // This should shutdown first before the event engine
-	//todo: check to see. i think the pub should close the eventEngineCh so we can drain it
-
 	for { // read until the e.DoneCh is signalled.
 		select {
 		case <-e.DoneCh:
-			e.wg.Wait()
+			// nothing can publish from now on, but the dispatcher of scheduled
+			// events and the publishes in flight can be sending still, so
+			// events are broadcast until they finished, before the transport
+			// is closed.
+			publishingDoneCh := e.gate.close()
+			for dispatcherDoneCh := e.dispatcherDoneCh; dispatcherDoneCh != nil || publishingDoneCh != nil; {
+				select {
+				case <-dispatcherDoneCh:
+					dispatcherDoneCh = nil
+				case <-publishingDoneCh:
+					publishingDoneCh = nil
+				case ee := <-e.Transport.Events():
+					e.decodeReceivedPayload(ee)
+					e.broadcaster(ee)
+				}
+			}
+
 			e.shutdownEventEngineCh()
 			log.Println("event engine is shutting down")
 
 			log.Println("draining engineCh")
-			for ee := range e.eventEngineCh { //block
+			for ee := range e.Transport.Events() { //block
+				e.decodeReceivedPayload(ee)
 				e.broadcaster(ee)
 			}
 
@@ -95,72 +224,97 @@ func (e *eventEngine) listen() {
 			e.shutdownSubscribersAddressCh()
 			return
 
-		case event, isOpened := <-e.eventEngineCh:
+		case event, isOpened := <-e.Transport.Events():
 			if !isOpened {
 				log.Println("eventEngineCh is closed")
 				return
 			}
 
+			e.decodeReceivedPayload(event)
 			e.broadcaster(event)
 		}
 	}
 }
 
 func (e *eventEngine) broadcaster(event *event.Event) {
-	subscribers, exists := e.events[event.Name]
+	subscribers, exists := e.subscribersOf(event.Name)
 	if !exists {
 		log.Printf("\033[35m event %v not found. check your event handler\033[0m",
 			event.Name,
 		)
+		e.acks.expect(event.ID, nil)
 		return
 	}
 
-	const maxPartitionSize = 4
-	partitionSize := (len(subscribers.addressChs) / 2) + 1
+	// every subscriber receives the events in the order they were
+	// published, and is released once the delivery finished, so it can be
+	// unsubscribed.
+	e.acks.expect(event.ID, subscribers.list)
 
-	if partitionSize < maxPartitionSize {
-		// if an event already exists, find the subscribers to that event and broadcast to each of their addressCh.
-		for i, addressCh := range subscribers.addressChs {
-			if addressCh == nil {
-				log.Printf(
-					"subscriber ''%v's'' addressCh is nil. check this event handler to make sure it has been initialized",
-					subscribers.names[i],
-				)
-				continue
-			}
+	for _, subscriber := range subscribers.list {
+		subscriber.deliver(event, &e.acks)
+		if !subscriber.byHandler {
+			e.acks.done(event.ID, subscriber, nil)
+		}
+		subscriber.inFlight.Done()
+	}
+}
 
-			addressCh <- event.Payload
+// subscribersOf returns the subscribers of eventName together with the
+// subscribers of every pattern eventName matches. It reports false if
+// eventName was never registered nor matches any pattern.
+//
+// Every subscriber returned is marked as in flight, and must be released with
+// subscriber.inFlight.Done once the event was delivered to it.
+func (e *eventEngine) subscribersOf(eventName event.EventName) (*subscribers, bool) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	// the list is copied, as it can change once e.mu is released.
+	inFlight := func(list []*subscriber) *subscribers {
+		for _, subscriber := range list {
+			subscriber.inFlight.Add(1)
 		}
-		return
+		return &subscribers{list: list}
 	}
 
-	// if partitionSize > maxPartitionSize, then the code below will run else it
-	// return early.
-	e.wg.Add(1)
-	go func() {
-		defer e.wg.Done()
-		for i, addressCh := range subscribers.addressChs[:partitionSize] {
-			if addressCh == nil {
-				log.Printf(
-					"subscriber ''%v's'' addressCh is nil. check this event handler to make sure it has been initialized",
-					subscribers.names[i],
-				)
-				continue
-			}
-			addressCh <- event.Payload
+	exactSubscribers, exists := e.events[eventName]
+
+	var patternSubscribers []*subscriber
+	for pattern, subscribers := range e.patterns {
+		if matchEventName(pattern, eventName) {
+			patternSubscribers = append(patternSubscribers, subscribers.list...)
 		}
-	}()
+	}
 
-	for i, addressCh := range subscribers.addressChs[partitionSize:] {
-		// if an event already exists, find the subscribers to that event and broadcast to each of their addressCh.
-		if addressCh == nil {
-			log.Printf(
-				"subscriber ''%v's'' addressCh is nil. check this event handler to make sure it has been initialized",
-				subscribers.names[i],
-			)
-			continue
+	if len(patternSubscribers) == 0 {
+		if !exists {
+			return nil, false
+		}
+		return inFlight(exactSubscribers.list), true
+	}
+
+	var all []*subscriber
+	if exists {
+		all = append(all, exactSubscribers.list...)
+	}
+	all = append(all, patternSubscribers...)
+
+	return inFlight(all), true
+}
+
+// forEachSubscriber calls fn with every subscriber of every event and
+// pattern, together with the event name or pattern it subscribed to.
+func (e *eventEngine) forEachSubscriber(fn func(toEventName event.EventName, subscriber *subscriber)) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	for _, subscriptions := range []map[event.EventName]*subscribers{e.events, e.patterns} {
+		for toEventName, subscribers := range subscriptions {
+			for _, subscriber := range subscribers.list {
+				fn(toEventName, subscriber)
+			}
 		}
-		addressCh <- event.Payload
 	}
 }
 
@@ -168,113 +322,397 @@ func (e *eventEngine) broadcaster(event *event.Event) {
 //
 // IMPORTANT: Register an event before you try to publish or subscribe to it.
 func (e *eventEngine) RegisterEvents(eventNames ...event.EventName) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
 	for _, eventName := range eventNames {
 		if _, exists := e.events[(eventName)]; exists {
 			log.Println("event already exists")
 			continue
 		}
 
-		// e.events[(*eventName)].names = e.events[:len(e.events[*eventName].names)-1]
-
-		// e.events[(eventName)].names = make([]*event.SubscriberName, size)
-		// e.events[(eventName)].addressChs = make([]chan<- any, size)
-
-		// e.events[(eventName)] = &subscribers{
-		// 	names:      make([]*event.SubscriberName, size),
-		// 	addressChs: make([]chan<- any, size),
-		// }
-		// Todo: I need to find a way to "make()" names and addressChs slices in &subscribers{} for proper memory allocation. Fix it!!!!!!!!!!
 		e.events[(eventName)] = &subscribers{}
 	}
 
 	log.Println("registering event:", eventNames)
 }
 
-func (e *eventEngine) Subscribe(toEventName event.EventName, newSubscriber *event.Subscriber) error {
-	if _, ok := e.events[toEventName]; !ok {
-		return fmt.Errorf(
+// Subscribe adds newSubscriber to the subscribers of toEventName.
+//
+// toEventName can be a pattern matching a family of dotted event names,
+// where "*" matches exactly one token and a trailing ">" matches one or more
+// tokens, e.g. "product.*" or "product.>". Patterns do not need to be
+// registered, so they also match events registered after subscribing.
+//
+// The event engine closes newSubscriber.AddressCh once it is unsubscribed
+// from every event, see [CancelFunc], or the event engine shut down.
+func (e *eventEngine) Subscribe(toEventName event.EventName, newSubscriber *event.Subscriber) (CancelFunc, error) {
+	if newSubscriber.AddressCh == nil {
+		return nil, fmt.Errorf(
+			"subscriber '%v's addressCh is nil. check this event handler to make sure it has been initialized",
+			newSubscriber.Name,
+		)
+	}
+
+	if IsPattern(toEventName) {
+		if err := validatePattern(toEventName); err != nil {
+			return nil, err
+		}
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.closed {
+		return nil, errEngineShutDown(toEventName, newSubscriber.Name)
+	}
+
+	subscriptions := e.events
+	if IsPattern(toEventName) {
+
+		subscriptions = e.patterns
+		if _, ok := subscriptions[toEventName]; !ok {
+			subscriptions[toEventName] = &subscribers{}
+		}
+	}
+
+	if _, ok := subscriptions[toEventName]; !ok {
+		return nil, fmt.Errorf(
 			"event '%v' not found. check the service whom is responsible for calling 'eventEngine.RegisterEvents(eventName)' to add an event to the eventEngine and make sure they called it and Registered the eventName or check if you passed the right event name",
 			toEventName,
 		)
 	}
 
-	// e.events[toEventName].names[len(e.events[toEventName].names)-1] = &newSubscriber.Name
-	// e.events[toEventName].addressChs[len(e.events[toEventName].addressChs)-1] = newSubscriber.AddressCh
+	subscriber := newEngineSubscriber(
+		newSubscriber.Name,
+		newSubscriber.AddressCh,
+		newSubscriber.Backpressure,
+		newSubscriber.BlockTimeout,
+	)
+	e.addSubscriber(subscriptions, toEventName, subscriber)
 
-	// Todo: the append is making more memory allocation than necessary. Fix it!!!!!!!!!!
-	e.events[toEventName] = &subscribers{
-		names:      append(e.events[toEventName].names, &newSubscriber.Name),
-		addressChs: append(e.events[toEventName].addressChs, newSubscriber.AddressCh),
-	}
+	return e.cancelFunc(toEventName, subscriber), nil
+}
 
-	return nil
+func errEngineShutDown(toEventName event.EventName, subscriberName event.SubscriberName) error {
+	return fmt.Errorf(
+		"subscriber '%v' can not subscribe to event '%v', the event engine is shut down",
+		subscriberName,
+		toEventName,
+	)
 }
 
-func (e *eventEngine) Publish(event *event.Event) error {
-	if _, exists := e.events[event.Name]; !exists {
-		return fmt.Errorf(
-			"event %v not found. check the service which is to publish the event to make sure they called the 'RegisterEvents()'",
-			event.Name,
+// SubscribeHandler binds toEventName to handler.PayloadType and subscribes
+// handler to it. The event engine creates the addressCh of handler and runs a
+// go routine that calls handler.Handle for every event published to
+// toEventName until the event engine shuts down.
+//
+// Unlike Subscribe, toEventName does not need to be registered beforehand,
+// as the handler already declares the payload type of the event.
+//
+// If toEventName is a pattern (see Subscribe), it is not bound to a payload
+// type. Instead, handler only receives the events of the family whose payload
+// is assignable to handler.PayloadType, e.g. every event for a handler of
+// type any.
+//
+// Once unsubscribed, see [CancelFunc], the handler is still called with the
+// events that were delivered to it before.
+func (e *eventEngine) SubscribeHandler(toEventName event.EventName, handler *Handler) (CancelFunc, error) {
+	if handler == nil || handler.SubscriptionConfig == nil || handler.Handle == nil || handler.PayloadType == nil {
+		return nil, fmt.Errorf(
+			"either handler, its 'SubscriptionConfig', 'Handle' or 'PayloadType' is nil when subscribing to event '%v'",
+			toEventName,
 		)
 	}
 
-	// e.wg.Add(1)
-	// defer e.wg.Done()
-	e.eventEngineCh <- event
+	if IsPattern(toEventName) {
+		if err := validatePattern(toEventName); err != nil {
+			return nil, err
+		}
+	}
 
-	return nil
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.closed {
+		return nil, errEngineShutDown(toEventName, handler.SubscriberName)
+	}
+
+	subscriptions := e.events
+	if IsPattern(toEventName) {
+		subscriptions = e.patterns
+	}
+
+	if boundType, ok := e.payloadTypes[toEventName]; ok && boundType != handler.PayloadType {
+		return nil, fmt.Errorf(
+			"%w: subscriber '%v' expects '%v' but event '%v' is bound to '%v'",
+			ErrPayloadTypeMismatch,
+			handler.SubscriberName,
+			handler.PayloadType,
+			toEventName,
+			boundType,
+		)
+	}
+
+	if version, payloadType, ok := e.schemaOf(toEventName); ok && payloadType != handler.PayloadType {
+		return nil, fmt.Errorf(
+			"%w: subscriber '%v' expects '%v' but version %d of the schema of event '%v' is '%v'",
+			ErrPayloadTypeMismatch,
+			handler.SubscriberName,
+			handler.PayloadType,
+			version,
+			toEventName,
+			payloadType,
+		)
+	}
+
+	key := handlerKey{
+		eventName:      toEventName,
+		subscriberName: handler.SubscriberName,
+	}
+	if _, ok := e.handlers[key]; ok {
+		return nil, fmt.Errorf(
+			"subscriber '%v' is already subscribed to event '%v'",
+			handler.SubscriberName,
+			toEventName,
+		)
+	}
+
+	if handler.Durable && e.EventStore == nil {
+		return nil, fmt.Errorf(
+			"%w: subscriber '%v' can not subscribe to event '%v' durably",
+			ErrNoEventStore,
+			handler.SubscriberName,
+			toEventName,
+		)
+	}
+
+	if handler.Durable && handler.Concurrency > 1 {
+		return nil, fmt.Errorf(
+			"%w: subscriber '%v' can not subscribe to event '%v' durably with %d workers",
+			ErrDurableConcurrency,
+			handler.SubscriberName,
+			toEventName,
+			handler.Concurrency,
+		)
+	}
+
+	// nothing is written before the handler passed every check above, so a
+	// rejected handler leaves no trace, e.g. a payload type bound to the event.
+	if _, ok := subscriptions[toEventName]; !ok {
+		subscriptions[toEventName] = &subscribers{}
+	}
+
+	if !IsPattern(toEventName) {
+		e.payloadTypes[toEventName] = handler.PayloadType
+	}
+
+	if handler.AddressChSize == 0 {
+		handler.AddressChSize = 10
+	}
+	if handler.Concurrency == 0 {
+		handler.Concurrency = 1
+	}
+	if handler.HandlerTimeout <= 0 {
+		handler.HandlerTimeout = DefaultHandlerTimeout
+	}
+	handler.Retry = handler.Retry.withDefaults()
+	handler.supervisor = newSupervisor(handler.Restart.withDefaults())
+	handler.metrics = newHandlerMetrics()
+	handler.shards = make([]sync.Mutex, handler.Concurrency)
+	e.handlers[key] = handler
+	addressCh := make(chan *event.Event, handler.AddressChSize)
+
+	subscriber := newEngineSubscriber(
+		handler.SubscriberName,
+		addressCh,
+		handler.Backpressure,
+		handler.BlockTimeout,
+	)
+	subscriber.byHandler = true
+	handler.subscriber = subscriber
+	e.addSubscriber(subscriptions, toEventName, subscriber)
+
+	e.InternalSrvWG.Add(1)
+	e.handlersWG.Add(1)
+	go e.runHandler(toEventName, handler, addressCh)
+
+	return e.cancelFunc(toEventName, subscriber), nil
 }
 
-func (e *eventEngine) shutdownSubscribersAddressCh() {
-	log.Println("waiting to shut addressChs down")
+// runHandler calls handler for every event sent to addressCh, from
+// handler.Concurrency workers, see runWorkers. It returns once the event
+// engine closes addressCh, when handler is unsubscribed or during shutdown,
+// and the workers finished.
+//
+// A durable handler first catches up on the events recorded since its
+// checkpoint. Catching up and the workers run under the supervisor of
+// handler, see supervise.
+func (e *eventEngine) runHandler(eventName event.EventName, handler *Handler, addressCh <-chan *event.Event) {
+	defer e.InternalSrvWG.Done()
+	defer e.handlersWG.Done()
 
-	for _, subscribers := range e.events {
-		for _, addressCh := range subscribers.addressChs {
-			if addressCh == nil {
-				continue
-			}
-			close(addressCh)
-		}
+	log.Printf("%s is listening to %s...\n", handler.SubscriberName, eventName)
+
+	var replayedSequence uint64
+	if handler.Durable {
+		e.supervise(eventName, handler, func() {
+			replayedSequence = e.catchUp(eventName, handler)
+		})
 	}
 
-	log.Println("\033[35m addressChs shutting down\033[0m")
+	e.runWorkers(eventName, handler, addressCh, func(newEvent *event.Event) bool {
+		// already handled while catching up.
+		return newEvent.Sequence != 0 && newEvent.Sequence <= replayedSequence
+	})
+
+	log.Printf("shutting down %s handler of %s\n", eventName, handler.SubscriberName)
 }
 
-func (e *eventEngine) shutdownEventEngineCh() {
-	log.Println("waiting to shut event engine down")
-	close(e.eventEngineCh)
-	log.Println("\033[35m event engine shutting down\033[0m")
+// handle calls handler with newEvent, dead letters newEvent if handler keeps
+// failing and moves the checkpoint of a durable handler past newEvent. If the
+// retries of handler are interrupted, see [ErrHandlingInterrupted], newEvent
+// is left as it is.
+// Handlers are called with one event of the same ordering key at a time,
+// whether live or replayed.
+//
+// handler stops being retried once ctx is done, see handlerContext.
+//
+// A panic of handler is passed on as a [HandlerPanic] carrying newEvent.
+func (e *eventEngine) handle(ctx context.Context, toEventName event.EventName, handler *Handler, newEvent *event.Event) {
+	defer recoverHandlerPanic(newEvent)
+
+	shard := &handler.shards[handler.shardOf(newEvent)]
+	shard.Lock()
+	defer shard.Unlock()
+
+	// a pattern subscriber can receive events of any payload type.
+	if payloadType := reflect.TypeOf(newEvent.Payload); payloadType == nil || !payloadType.AssignableTo(handler.PayloadType) {
+		log.Printf(
+			"subscriber '%s' skipped event %s, its payload '%T' is not a '%v'\n",
+			handler.SubscriberName,
+			newEvent,
+			newEvent.Payload,
+			handler.PayloadType,
+		)
+		e.acks.done(newEvent.ID, handler.subscriber, nil)
+		return
+	}
+
+	attempts, err := e.handleWithRetry(ctx, handler, newEvent)
+	if errors.Is(err, ErrHandlingInterrupted) {
+		// publishers waiting for newEvent are told it was not handled, and it
+		// is handled again once it is relayed or replayed.
+		log.Printf("subscriber '%s' stopped handling event %s: %v\n", handler.SubscriberName, newEvent, err)
+		e.acks.done(newEvent.ID, handler.subscriber, err)
+		return
+	}
+	if err != nil {
+		e.deadLetter(handler, newEvent, attempts, err)
+	} else {
+		handler.metrics.handled.Add(1)
+	}
+	e.acks.done(newEvent.ID, handler.subscriber, err)
+
+	if handler.Durable && newEvent.Sequence != 0 {
+		e.saveCheckpoint(toEventName, handler, newEvent.Sequence)
+	}
 }
 
-func (e *eventEngine) publishWorkers() {
-	numOfWorkers := max((runtime.NumCPU() / 5), 1)
+// Publish fills in the parts of the envelope of newEvent the publisher left
+// out, e.g. when it was not created with [event.New], and sends it to the
+// subscribers of newEvent.Name.
+//
+// It gives up after DefaultPublishTimeout, see PublishContext.
+func (e *eventEngine) Publish(newEvent *event.Event) error {
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultPublishTimeout)
+	defer cancel()
 
-	for range numOfWorkers {
-		e.wg.Add(1)
-		go func() {
-			defer e.wg.Done()
+	return e.PublishContext(ctx, newEvent)
+}
 
-			// for job := range e.jobsCh {
-			// 	log.Println("\033[31m deadlock\033[0m")
-			// 	e.eventEngineCh <- job
-			// 	log.Println("\033[91m done deadlock\033[0m")
-			// }
+// prepare checks newEvent can be published and fills in the parts of its
+// envelope the publisher left out.
+func (e *eventEngine) prepare(newEvent *event.Event) error {
+	e.mu.RLock()
+	_, exists := e.events[newEvent.Name]
+	boundType, bound := e.payloadTypes[newEvent.Name]
+	e.mu.RUnlock()
 
-			for {
-				select {
-				case <-e.DoneCh:
-					return
+	if !exists {
+		return fmt.Errorf(
+			"event %v not found. check the service which is to publish the event to make sure they called the 'RegisterEvents()'",
+			newEvent.Name,
+		)
+	}
 
-					// case job := <-e.jobsCh:
-					// e.eventEngineCh <- job
-				}
-			}
-		}()
+	if bound && reflect.TypeOf(newEvent.Payload) != boundType {
+		return fmt.Errorf(
+			"%w: event '%v' is bound to '%v' but was published with '%T'",
+			ErrPayloadTypeMismatch,
+			newEvent.Name,
+			boundType,
+			newEvent.Payload,
+		)
+	}
+
+	// an event with a schema is published with the current version of its
+	// payload, and stamped with that version.
+	if version, payloadType, ok := e.schemaOf(newEvent.Name); ok {
+		if reflect.TypeOf(newEvent.Payload) != payloadType {
+			return fmt.Errorf(
+				"%w: version %d of the schema of event '%v' is '%v' but it was published with '%T'",
+				ErrPayloadTypeMismatch,
+				version,
+				newEvent.Name,
+				payloadType,
+				newEvent.Payload,
+			)
+		}
+		newEvent.Version = version
+	}
+
+	if newEvent.ID == uuid.Nil {
+		newEvent.ID = uuid.New()
+	}
+
+	if newEvent.Version == 0 {
+		newEvent.Version = event.CurrentVersion
+	}
+
+	if newEvent.OccurredAt.IsZero() {
+		newEvent.OccurredAt = time.Now().UTC()
+	}
+
+	if newEvent.CorrelationID == "" {
+		// an event nothing else is correlated with starts its own correlation.
+		newEvent.CorrelationID = newEvent.ID.String()
 	}
+
+	return nil
 }
 
-func (e *eventEngine) shutdownWorkersJobsCh() {
-	log.Println("waiting to shut jobsCh down")
-	// close(e.jobsCh)
-	log.Println("\033[35m jobsCh shutting down\033[0m")
+func (e *eventEngine) shutdownSubscribersAddressCh() {
+	log.Println("waiting to shut addressChs down")
+
+	e.mu.Lock()
+	e.closed = true
+	close(e.closedCh)
+	e.mu.Unlock()
+
+	// the same addressCh can subscribe to several events, so it is only closed
+	// once, after its last subscription.
+	e.teardown(
+		e.removeSubscribers(func(event.EventName, *subscriber) bool { return true }),
+	)
+
+	log.Println("\033[35m addressChs shutting down\033[0m")
+}
+
+func (e *eventEngine) shutdownEventEngineCh() {
+	log.Println("waiting to shut event engine down")
+	if err := e.Transport.Close(); err != nil {
+		log.Printf("failed to close the transport of the event engine: %v\n", err)
+	}
+	log.Println("\033[35m event engine shutting down\033[0m")
 }
@@ -1,10 +1,13 @@
 package eventengine
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log"
 	"sync"
 	"testing"
+	"time"
 
 	"github.com/eng-by-sjb/yellow-pines-e-commerce-backend/internal/eventengine/event"
 )
@@ -20,11 +23,13 @@ func Test_eventEngine(t *testing.T) {
 		EventEngineConfig: &EventEngineConfig{
 			DoneCh:        doneCh,
 			InternalSrvWG: &InternalSrvWG,
+			Transport:     NewChannelTransport(1),
 		},
-		events:        make(map[event.EventName]*subscribers, 20),
-		eventEngineCh: make(chan *event.Event, 1),
-		// jobsCh:        make(chan *event.Event, 2),
+		events:           make(map[event.EventName]*subscribers, 20),
+		dispatcherDoneCh: make(chan struct{}),
+		closedCh:         make(chan struct{}),
 	}
+	close(eventEngine.dispatcherDoneCh) // no dispatcher of scheduled events runs.
 
 	InternalSrvWG.Add(1)
 	go eventEngine.listen() // go routine 1
@@ -35,8 +40,8 @@ func Test_eventEngine(t *testing.T) {
 	eventEngine.RegisterEvents(eventTest.Name)
 
 	// register a subscriber1 for an event.
-	subscriberAddressCh1 := make(chan any, 2)
-	err = eventEngine.Subscribe(
+	subscriberAddressCh1 := make(chan *event.Event, 2)
+	_, err = eventEngine.Subscribe(
 		eventTest.Name,
 		&event.Subscriber{
 			Name:      "test_subscriber_name.1",
@@ -67,8 +72,8 @@ func Test_eventEngine(t *testing.T) {
 	}() //go routine 2
 
 	// register a subscriber2 for an event.
-	subscriberAddressCh2 := make(chan any, 2)
-	err = eventEngine.Subscribe(
+	subscriberAddressCh2 := make(chan *event.Event, 2)
+	_, err = eventEngine.Subscribe(
 		eventTest.Name,
 		&event.Subscriber{
 			Name:      "test_subscriber_name.2",
@@ -115,3 +120,569 @@ func Test_eventEngine(t *testing.T) {
 	close(doneCh)
 	InternalSrvWG.Wait()
 }
+
+func Test_Subscribe(t *testing.T) {
+	type testPayload struct {
+		Value int
+	}
+
+	doneCh := make(chan struct{})
+	InternalSrvWG := sync.WaitGroup{}
+
+	engine := NewEventEngine(
+		&EventEngineConfig{
+			DoneCh:        doneCh,
+			InternalSrvWG: &InternalSrvWG,
+		},
+	)
+
+	var eventName event.EventName = "test.event.engine.typed"
+	engine.RegisterEvents(eventName)
+
+	receivedCh := make(chan int, 1)
+	_, err := Subscribe(
+		engine,
+		eventName,
+		&SubscriptionConfig{SubscriberName: "test_subscriber_name.typed"},
+		func(ctx context.Context, payload *testPayload) error {
+			receivedCh <- payload.Value
+			return nil
+		},
+	)
+	if err != nil {
+		t.Fatalf("expected to subscribe, got: %v", err)
+	}
+
+	_, err = Subscribe(
+		engine,
+		eventName,
+		&SubscriptionConfig{SubscriberName: "test_subscriber_name.mismatch"},
+		func(ctx context.Context, payload string) error { return nil },
+	)
+	if !errors.Is(err, ErrPayloadTypeMismatch) {
+		t.Fatalf("expected subscribing with another payload type to fail with ErrPayloadTypeMismatch, got: %v", err)
+	}
+
+	err = engine.Publish(&event.Event{Name: eventName, Payload: testPayload{Value: 1}})
+	if !errors.Is(err, ErrPayloadTypeMismatch) {
+		t.Fatalf("expected publishing a value instead of a pointer to fail with ErrPayloadTypeMismatch, got: %v", err)
+	}
+
+	if err = engine.Publish(&event.Event{Name: eventName, Payload: &testPayload{Value: 2}}); err != nil {
+		t.Fatalf("expected to publish, got: %v", err)
+	}
+
+	select {
+	case value := <-receivedCh:
+		if value != 2 {
+			t.Fatalf("expected handler to receive 2, got: %d", value)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected handler to receive the published payload")
+	}
+
+	close(doneCh)
+	InternalSrvWG.Wait()
+}
+
+func Test_SubscribeHandler_rejected(t *testing.T) {
+	type testPayload struct {
+		Value int
+	}
+
+	doneCh := make(chan struct{})
+	InternalSrvWG := sync.WaitGroup{}
+
+	engine := NewEventEngine(
+		&EventEngineConfig{
+			DoneCh:        doneCh,
+			InternalSrvWG: &InternalSrvWG,
+		},
+	)
+	defer func() {
+		close(doneCh)
+		InternalSrvWG.Wait()
+	}()
+
+	var eventName event.EventName = "test.event.engine.rejected"
+	engine.RegisterEvents(eventName)
+
+	_, err := Subscribe(
+		engine,
+		eventName,
+		&SubscriptionConfig{SubscriberName: "test_subscriber_name.durable", Durable: true},
+		func(ctx context.Context, payload *testPayload) error { return nil },
+	)
+	if !errors.Is(err, ErrNoEventStore) {
+		t.Fatalf("expected subscribing durably without an event store to fail with ErrNoEventStore, got: %v", err)
+	}
+
+	// the rejected subscriber did not bind the event to its payload type.
+	receivedCh := make(chan string, 1)
+	_, err = Subscribe(
+		engine,
+		eventName,
+		&SubscriptionConfig{SubscriberName: "test_subscriber_name.other"},
+		func(ctx context.Context, payload *string) error {
+			receivedCh <- *payload
+			return nil
+		},
+	)
+	if err != nil {
+		t.Fatalf("expected to subscribe with another payload type than the rejected subscriber, got: %v", err)
+	}
+
+	payload := "pine"
+	if err = engine.Publish(&event.Event{Name: eventName, Payload: &payload}); err != nil {
+		t.Fatalf("expected to publish, got: %v", err)
+	}
+
+	select {
+	case value := <-receivedCh:
+		if value != payload {
+			t.Fatalf("expected handler to receive %q, got: %q", payload, value)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected handler to receive the published payload")
+	}
+}
+
+func Test_deadLetter(t *testing.T) {
+	type testPayload struct {
+		Value int
+	}
+
+	doneCh := make(chan struct{})
+	InternalSrvWG := sync.WaitGroup{}
+	deadLetterStore := NewMemoryDeadLetterStore()
+
+	engine := NewEventEngine(
+		&EventEngineConfig{
+			DoneCh:          doneCh,
+			InternalSrvWG:   &InternalSrvWG,
+			DeadLetterStore: deadLetterStore,
+		},
+	)
+
+	var eventName event.EventName = "test.event.engine.dead.letter"
+	engine.RegisterEvents(eventName)
+
+	var mu sync.Mutex
+	attempts := 0
+	shouldFail := true
+	deadLetteredCh := make(chan error, 1)
+
+	_, err := Subscribe(
+		engine,
+		eventName,
+		&SubscriptionConfig{
+			SubscriberName: "test_subscriber_name.failing",
+			Retry: &RetryPolicy{
+				MaxAttempts:    3,
+				InitialBackoff: time.Millisecond,
+				MaxBackoff:     2 * time.Millisecond,
+				Multiplier:     2,
+			},
+			OnDeadLetter: func(ctx context.Context, payload any, err error) error {
+				deadLetteredCh <- err
+				return nil
+			},
+		},
+		func(ctx context.Context, payload *testPayload) error {
+			mu.Lock()
+			defer mu.Unlock()
+
+			attempts++
+			if shouldFail {
+				return errors.New("handler failed")
+			}
+			return nil
+		},
+	)
+	if err != nil {
+		t.Fatalf("expected to subscribe, got: %v", err)
+	}
+
+	if err = engine.Publish(&event.Event{Name: eventName, Payload: &testPayload{Value: 1}}); err != nil {
+		t.Fatalf("expected to publish, got: %v", err)
+	}
+
+	select {
+	case <-deadLetteredCh:
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected event to be dead lettered")
+	}
+
+	mu.Lock()
+	if attempts != 3 {
+		t.Fatalf("expected handler to be called 3 times, got: %d", attempts)
+	}
+	shouldFail = false
+	mu.Unlock()
+
+	deadLetters, err := engine.ListDeadLetters(context.Background())
+	if err != nil || len(deadLetters) != 1 {
+		t.Fatalf("expected 1 dead letter, got: %d, %v", len(deadLetters), err)
+	}
+
+	if err = engine.RedriveDeadLetter(context.Background(), deadLetters[0].DeadLetterID); err != nil {
+		t.Fatalf("expected to redrive dead letter, got: %v", err)
+	}
+
+	deadLetters, _ = engine.ListDeadLetters(context.Background())
+	if len(deadLetters) != 0 {
+		t.Fatalf("expected redriven dead letter to be removed, got: %d", len(deadLetters))
+	}
+
+	close(doneCh)
+	InternalSrvWG.Wait()
+}
+
+func Test_deadLetter_shutdown(t *testing.T) {
+	type testPayload struct {
+		Value int
+	}
+
+	doneCh := make(chan struct{})
+	InternalSrvWG := sync.WaitGroup{}
+
+	engine := NewEventEngine(
+		&EventEngineConfig{
+			DoneCh:        doneCh,
+			InternalSrvWG: &InternalSrvWG,
+		},
+	)
+
+	var eventName event.EventName = "test.event.engine.dead.letter.shutdown"
+	engine.RegisterEvents(eventName)
+
+	attemptedCh := make(chan struct{}, 1)
+	deadLetteredCh := make(chan error, 1)
+
+	_, err := Subscribe(
+		engine,
+		eventName,
+		&SubscriptionConfig{
+			SubscriberName: "test_subscriber_name.failing",
+			Retry: &RetryPolicy{
+				MaxAttempts:    3,
+				InitialBackoff: time.Hour,
+			},
+			OnDeadLetter: func(ctx context.Context, payload any, err error) error {
+				deadLetteredCh <- err
+				return nil
+			},
+		},
+		func(ctx context.Context, payload *testPayload) error {
+			attemptedCh <- struct{}{}
+			return errors.New("handler failed")
+		},
+	)
+	if err != nil {
+		t.Fatalf("expected to subscribe, got: %v", err)
+	}
+
+	publishedCh := make(chan error, 1)
+	go func() {
+		publishedCh <- engine.PublishAndWait(
+			context.Background(),
+			&event.Event{Name: eventName, Payload: &testPayload{Value: 1}},
+		)
+	}()
+
+	<-attemptedCh
+	close(doneCh)
+
+	// the waiting publisher, e.g. the outbox relay, is told the event was not
+	// handled, so it publishes it again later.
+	select {
+	case err := <-publishedCh:
+		if !errors.Is(err, ErrHandlingInterrupted) {
+			t.Fatalf("expected error '%v', got: %v", ErrHandlingInterrupted, err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected the publisher to stop waiting once the event engine shut down")
+	}
+
+	InternalSrvWG.Wait()
+
+	select {
+	case err := <-deadLetteredCh:
+		t.Fatalf("expected the event not to be dead lettered, got: %v", err)
+	default:
+	}
+
+	deadLetters, err := engine.ListDeadLetters(context.Background())
+	if err != nil || len(deadLetters) != 0 {
+		t.Fatalf("expected no dead letters, got: %d, %v", len(deadLetters), err)
+	}
+}
+
+func Test_subscriberBackpressure(t *testing.T) {
+	testCases := []struct {
+		name            string
+		backpressure    event.BackpressurePolicy
+		expectedDropped uint64
+		expectedPayload []int
+	}{
+		{
+			name:            "drop newest keeps the events already in the addressCh",
+			backpressure:    event.DropNewestPolicy,
+			expectedDropped: 3,
+			expectedPayload: []int{1, 2},
+		},
+		{
+			name:            "drop oldest keeps the latest events",
+			backpressure:    event.DropOldestPolicy,
+			expectedDropped: 3,
+			expectedPayload: []int{4, 5},
+		},
+		{
+			name:            "block with timeout drops events after waiting",
+			backpressure:    event.BlockWithTimeoutPolicy,
+			expectedDropped: 3,
+			expectedPayload: []int{1, 2},
+		},
+		{
+			name:            "spill keeps every event in order",
+			backpressure:    event.SpillPolicy,
+			expectedDropped: 0,
+			expectedPayload: []int{1, 2, 3, 4, 5},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			addressCh := make(chan *event.Event, 2)
+			subscriber := newEngineSubscriber(
+				"test_subscriber_name.slow",
+				addressCh,
+				tc.backpressure,
+				time.Millisecond,
+			)
+
+			for i := range 5 {
+				subscriber.deliver(
+					&event.Event{
+						Name:    "test.event.engine.backpressure",
+						Payload: i + 1,
+					},
+					&acknowledgements{},
+				)
+			}
+
+			var received []int
+			go func() {
+				subscriber.close()
+				close(addressCh)
+			}()
+			for receivedEvent := range addressCh {
+				received = append(received, receivedEvent.Payload.(int))
+			}
+
+			if subscriber.dropped.Load() != tc.expectedDropped {
+				t.Fatalf("expected %d dropped events, got: %d", tc.expectedDropped, subscriber.dropped.Load())
+			}
+
+			if fmt.Sprint(received) != fmt.Sprint(tc.expectedPayload) {
+				t.Fatalf("expected to receive %v, got: %v", tc.expectedPayload, received)
+			}
+		})
+	}
+}
+
+func Test_envelope(t *testing.T) {
+	type testPayload struct {
+		Value int
+	}
+
+	doneCh := make(chan struct{})
+	InternalSrvWG := sync.WaitGroup{}
+
+	engine := NewEventEngine(
+		&EventEngineConfig{
+			DoneCh:        doneCh,
+			InternalSrvWG: &InternalSrvWG,
+		},
+	)
+
+	var parentEventName event.EventName = "test.event.engine.parent"
+	var childEventName event.EventName = "test.event.engine.child"
+	engine.RegisterEvents(parentEventName, childEventName)
+
+	parentCh := make(chan *event.Event, 1)
+	childCh := make(chan *event.Event, 1)
+
+	_, err := Subscribe(
+		engine,
+		parentEventName,
+		&SubscriptionConfig{SubscriberName: "test_subscriber_name.parent"},
+		func(ctx context.Context, payload *testPayload) error {
+			parentEvent, _ := event.FromContext(ctx)
+			parentCh <- parentEvent
+
+			return engine.Publish(
+				event.New(ctx, "test_producer.child", childEventName, &testPayload{Value: payload.Value + 1}),
+			)
+		},
+	)
+	if err != nil {
+		t.Fatalf("expected to subscribe, got: %v", err)
+	}
+
+	_, err = Subscribe(
+		engine,
+		childEventName,
+		&SubscriptionConfig{SubscriberName: "test_subscriber_name.child"},
+		func(ctx context.Context, payload *testPayload) error {
+			childEvent, _ := event.FromContext(ctx)
+			childCh <- childEvent
+			return nil
+		},
+	)
+	if err != nil {
+		t.Fatalf("expected to subscribe, got: %v", err)
+	}
+
+	ctx := event.ContextWithCorrelationID(context.Background(), "test-request-id")
+	err = engine.Publish(
+		event.New(ctx, "test_producer.parent", parentEventName, &testPayload{Value: 1}),
+	)
+	if err != nil {
+		t.Fatalf("expected to publish, got: %v", err)
+	}
+
+	var parentEvent, childEvent *event.Event
+	for _, ch := range []chan *event.Event{parentCh, childCh} {
+		select {
+		case received := <-ch:
+			if received == nil {
+				t.Fatal("expected handler context to carry the event")
+			}
+			if received.Name == parentEventName {
+				parentEvent = received
+			} else {
+				childEvent = received
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatal("expected both handlers to receive their event")
+		}
+	}
+
+	if parentEvent.ID == childEvent.ID || childEvent.CausationID != parentEvent.ID {
+		t.Fatalf("expected child event to be caused by %s, got: %s", parentEvent.ID, childEvent.CausationID)
+	}
+
+	if parentEvent.CorrelationID != "test-request-id" || childEvent.CorrelationID != "test-request-id" {
+		t.Fatalf("expected both events to be correlated by the request id, got: %s and %s", parentEvent.CorrelationID, childEvent.CorrelationID)
+	}
+
+	if childEvent.Producer != "test_producer.child" || childEvent.OccurredAt.IsZero() || childEvent.Version != event.CurrentVersion {
+		t.Fatalf("expected child envelope to be populated, got: %s", childEvent)
+	}
+
+	close(doneCh)
+	InternalSrvWG.Wait()
+}
+
+func Test_patternSubscriptions(t *testing.T) {
+	type testPayload struct {
+		Value int
+	}
+
+	doneCh := make(chan struct{})
+	InternalSrvWG := sync.WaitGroup{}
+
+	engine := NewEventEngine(
+		&EventEngineConfig{
+			DoneCh:        doneCh,
+			InternalSrvWG: &InternalSrvWG,
+		},
+	)
+
+	var createdEventName event.EventName = "test.product.created"
+	var stockEventName event.EventName = "test.product.stock.updated"
+	var otherEventName event.EventName = "test.order.created"
+	engine.RegisterEvents(createdEventName, stockEventName, otherEventName)
+
+	singleLevelCh := make(chan event.EventName, 3)
+	multiLevelCh := make(chan event.EventName, 3)
+
+	_, err := Subscribe(
+		engine,
+		"test.product.*",
+		&SubscriptionConfig{SubscriberName: "test_subscriber_name.single_level"},
+		func(ctx context.Context, _ any) error {
+			receivedEvent, _ := event.FromContext(ctx)
+			singleLevelCh <- receivedEvent.Name
+			return nil
+		},
+	)
+	if err != nil {
+		t.Fatalf("expected to subscribe to pattern, got: %v", err)
+	}
+
+	_, err = Subscribe(
+		engine,
+		"test.product.>",
+		&SubscriptionConfig{SubscriberName: "test_subscriber_name.multi_level"},
+		func(ctx context.Context, _ *testPayload) error {
+			receivedEvent, _ := event.FromContext(ctx)
+			multiLevelCh <- receivedEvent.Name
+			return nil
+		},
+	)
+	if err != nil {
+		t.Fatalf("expected to subscribe to pattern, got: %v", err)
+	}
+
+	_, err = Subscribe(
+		engine,
+		"test.>.created",
+		&SubscriptionConfig{SubscriberName: "test_subscriber_name.invalid"},
+		func(context.Context, any) error { return nil },
+	)
+	if err == nil {
+		t.Fatal("expected '>' not in last position to be rejected")
+	}
+
+	for _, eventName := range []event.EventName{createdEventName, stockEventName, otherEventName} {
+		err := engine.Publish(
+			event.New(context.Background(), "test_producer", eventName, &testPayload{Value: 1}),
+		)
+		if err != nil {
+			t.Fatalf("expected to publish %s, got: %v", eventName, err)
+		}
+	}
+
+	received := func(ch chan event.EventName, want int) map[event.EventName]bool {
+		names := make(map[event.EventName]bool)
+		for range want {
+			select {
+			case name := <-ch:
+				names[name] = true
+			case <-time.After(2 * time.Second):
+				t.Fatalf("expected %d events, got: %v", want, names)
+			}
+		}
+
+		select {
+		case name := <-ch:
+			t.Fatalf("expected no more events, got: %s", name)
+		case <-time.After(100 * time.Millisecond):
+		}
+
+		return names
+	}
+
+	if names := received(singleLevelCh, 1); !names[createdEventName] {
+		t.Fatalf("expected 'test.product.*' to receive only %s, got: %v", createdEventName, names)
+	}
+
+	if names := received(multiLevelCh, 2); !names[createdEventName] || !names[stockEventName] {
+		t.Fatalf("expected 'test.product.>' to receive every product event, got: %v", names)
+	}
+
+	close(doneCh)
+	InternalSrvWG.Wait()
+}
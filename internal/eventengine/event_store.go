@@ -0,0 +1,403 @@
+package eventengine
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/eng-by-sjb/yellow-pines-e-commerce-backend/internal/eventengine/event"
+	"github.com/google/uuid"
+)
+
+// ErrNoEventStore is returned when replaying events, or subscribing durably,
+// to an event engine without an EventStore.
+var ErrNoEventStore = errors.New("event engine has no event store")
+
+// ErrDurableConcurrency is returned when subscribing durably with more than
+// one worker. The workers would save their checkpoints out of order, so one
+// could move the checkpoint past an event another one is still handling,
+// which would then never be replayed after a crash.
+var ErrDurableConcurrency = errors.New("durable subscriptions are handled by a single worker")
+
+// replayBatchSize is how many recorded events are read from the event store
+// at once when replaying.
+const replayBatchSize = 100
+
+// EventRecord is a published event as recorded in the event log.
+type EventRecord struct {
+	Sequence      uint64          `json:"sequence"`
+	EventID       uuid.UUID       `json:"eventID"`
+	EventName     event.EventName `json:"eventName"`
+	EventVersion  uint16          `json:"eventVersion"`
+	OccurredAt    time.Time       `json:"occurredAt"`
+	CorrelationID string          `json:"correlationID"`
+	CausationID   uuid.UUID       `json:"causationID"`
+	Producer      string          `json:"producer"`
+	Payload       json.RawMessage `json:"payload"`
+	RecordedAt    time.Time       `json:"recordedAt"`
+}
+
+// event rebuilds the envelope of the recorded event around payload.
+func (r *EventRecord) event(payload any) *event.Event {
+	return &event.Event{
+		ID:            r.EventID,
+		Name:          r.EventName,
+		Version:       r.EventVersion,
+		OccurredAt:    r.OccurredAt,
+		CorrelationID: r.CorrelationID,
+		CausationID:   r.CausationID,
+		Producer:      r.Producer,
+		Sequence:      r.Sequence,
+		Payload:       payload,
+	}
+}
+
+// EventStore is an append-only log of every event published to the event
+// engine, together with how far each durable subscriber got through it.
+type EventStore interface {
+	// Append records record and sets its Sequence and RecordedAt. Appending an
+	// event that was already recorded, e.g. because the outbox relayed it
+	// again, returns the record it was first appended as.
+	Append(ctx context.Context, record *EventRecord) error
+	// AppendBatch appends every record of records like Append, or none of
+	// them.
+	AppendBatch(ctx context.Context, records []*EventRecord) error
+	// Read returns up to limit records with a sequence after afterSequence
+	// that occurred at or after since, ordered by sequence. A zero since
+	// returns them regardless of when they occurred.
+	Read(ctx context.Context, afterSequence uint64, since time.Time, limit int) ([]*EventRecord, error)
+	// Checkpoint returns the sequence of the last event subscriberName handled
+	// for its subscription to eventName, or 0 if it has not handled any yet.
+	Checkpoint(ctx context.Context, subscriberName event.SubscriberName, eventName event.EventName) (uint64, error)
+	// SaveCheckpoint sets the checkpoint of subscriberName for eventName. A
+	// checkpoint never moves back, so saving an older sequence does nothing.
+	SaveCheckpoint(ctx context.Context, subscriberName event.SubscriberName, eventName event.EventName, sequence uint64) error
+}
+
+// Replayer replays the events recorded in the event store to a subscriber.
+type Replayer interface {
+	Replay(ctx context.Context, toEventName event.EventName, subscriberName event.SubscriberName, from ReplayPosition) error
+}
+
+// ReplayPosition is where in the event log a replay starts.
+type ReplayPosition struct {
+	afterSequence uint64
+	since         time.Time
+	checkpoint    bool
+}
+
+// FromCheckpoint replays the events after the checkpoint of the subscriber,
+// or every event if it has none yet.
+var FromCheckpoint = ReplayPosition{checkpoint: true}
+
+// FromSequence replays the events recorded after sequence. FromSequence(0)
+// replays every event.
+func FromSequence(sequence uint64) ReplayPosition {
+	return ReplayPosition{afterSequence: sequence}
+}
+
+// FromTime replays the events that occurred at or after t.
+func FromTime(t time.Time) ReplayPosition {
+	return ReplayPosition{since: t}
+}
+
+type checkpointKey struct {
+	subscriberName event.SubscriberName
+	eventName      event.EventName
+}
+
+type memoryEventStore struct {
+	mu          sync.RWMutex
+	records     []*EventRecord
+	byEventID   map[uuid.UUID]*EventRecord
+	checkpoints map[checkpointKey]uint64
+}
+
+// NewMemoryEventStore returns an [EventStore] that keeps the event log in
+// memory. It is lost when the server restarts, so it is meant for tests.
+func NewMemoryEventStore() EventStore {
+	return &memoryEventStore{
+		byEventID:   make(map[uuid.UUID]*EventRecord),
+		checkpoints: make(map[checkpointKey]uint64),
+	}
+}
+
+func (s *memoryEventStore) Append(ctx context.Context, record *EventRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.appendLocked(record)
+	return nil
+}
+
+func (s *memoryEventStore) AppendBatch(ctx context.Context, records []*EventRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, record := range records {
+		s.appendLocked(record)
+	}
+	return nil
+}
+
+// appendLocked appends record. It must be called with s.mu held.
+func (s *memoryEventStore) appendLocked(record *EventRecord) {
+	if recorded, ok := s.byEventID[record.EventID]; ok {
+		*record = *recorded
+		return
+	}
+
+	record.Sequence = uint64(len(s.records)) + 1
+	record.RecordedAt = time.Now().UTC()
+
+	recorded := *record
+	s.records = append(s.records, &recorded)
+	s.byEventID[record.EventID] = &recorded
+}
+
+func (s *memoryEventStore) Read(ctx context.Context, afterSequence uint64, since time.Time, limit int) ([]*EventRecord, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	records := []*EventRecord{}
+	// sequences start at 1, so the record after afterSequence is at index
+	// afterSequence.
+	for i := afterSequence; i < uint64(len(s.records)) && len(records) < limit; i++ {
+		record := s.records[i]
+		if !since.IsZero() && record.OccurredAt.Before(since) {
+			continue
+		}
+
+		recorded := *record
+		records = append(records, &recorded)
+	}
+
+	return records, nil
+}
+
+func (s *memoryEventStore) Checkpoint(ctx context.Context, subscriberName event.SubscriberName, eventName event.EventName) (uint64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.checkpoints[checkpointKey{subscriberName, eventName}], nil
+}
+
+func (s *memoryEventStore) SaveCheckpoint(ctx context.Context, subscriberName event.SubscriberName, eventName event.EventName, sequence uint64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := checkpointKey{subscriberName, eventName}
+	s.checkpoints[key] = max(s.checkpoints[key], sequence)
+
+	return nil
+}
+
+// record appends newEvents to the event store, all of them or none, and sets
+// their Sequence.
+func (e *eventEngine) record(ctx context.Context, newEvents ...*event.Event) error {
+	ctx, cancel := context.WithTimeout(ctx, (10 * time.Second))
+	defer cancel()
+
+	records := make([]*EventRecord, 0, len(newEvents))
+	for _, newEvent := range newEvents {
+		rawPayload, err := json.Marshal(newEvent.Payload)
+		if err != nil {
+			return fmt.Errorf(
+				"failed to marshal payload of event %s for the event store: %w",
+				newEvent,
+				err,
+			)
+		}
+
+		records = append(records, &EventRecord{
+			EventID:       newEvent.ID,
+			EventName:     newEvent.Name,
+			EventVersion:  newEvent.Version,
+			OccurredAt:    newEvent.OccurredAt,
+			CorrelationID: newEvent.CorrelationID,
+			CausationID:   newEvent.CausationID,
+			Producer:      newEvent.Producer,
+			Payload:       rawPayload,
+		})
+	}
+
+	if len(records) == 1 {
+		if err := e.EventStore.Append(ctx, records[0]); err != nil {
+			return fmt.Errorf(
+				"failed to record event %s in the event store: %w",
+				newEvents[0],
+				err,
+			)
+		}
+	} else if err := e.EventStore.AppendBatch(ctx, records); err != nil {
+		return fmt.Errorf(
+			"failed to record a batch of %d events in the event store: %w",
+			len(records),
+			err,
+		)
+	}
+
+	for i, record := range records {
+		newEvents[i].Sequence = record.Sequence
+	}
+
+	return nil
+}
+
+// Replay hands the events recorded in the event store from the given
+// position to the handler subscriberName subscribed to toEventName with, one
+// at a time and in the order they were recorded. It returns once every event
+// recorded so far was handled, or ctx is done.
+//
+// Events the handler fails to handle are dead lettered like live ones. As a
+// replay can hand the handler events it already handled, handlers should be
+// idempotent.
+func (e *eventEngine) Replay(
+	ctx context.Context,
+	toEventName event.EventName,
+	subscriberName event.SubscriberName,
+	from ReplayPosition,
+) error {
+	if e.EventStore == nil {
+		return ErrNoEventStore
+	}
+
+	e.mu.RLock()
+	handler, ok := e.handlers[handlerKey{
+		eventName:      toEventName,
+		subscriberName: subscriberName,
+	}]
+	e.mu.RUnlock()
+	if !ok {
+		return fmt.Errorf(
+			"%w: subscriber '%v' is not subscribed to event '%v'",
+			ErrSubscriptionNotFound,
+			subscriberName,
+			toEventName,
+		)
+	}
+
+	if from.checkpoint {
+		checkpoint, err := e.EventStore.Checkpoint(ctx, subscriberName, toEventName)
+		if err != nil {
+			return err
+		}
+		from = FromSequence(checkpoint)
+	}
+
+	_, err := e.replay(ctx, toEventName, handler, from)
+	return err
+}
+
+// catchUp replays the events recorded since the checkpoint of a durable
+// handler before it handles live events. It returns the sequence of the last
+// event it read, so the live events it already replayed can be skipped.
+func (e *eventEngine) catchUp(toEventName event.EventName, handler *Handler) uint64 {
+	ctx := e.ctx
+
+	checkpoint, err := e.EventStore.Checkpoint(ctx, handler.SubscriberName, toEventName)
+	if err != nil {
+		log.Printf(
+			"failed to get checkpoint of subscriber '%s' for %s, replaying every event: %v\n",
+			handler.SubscriberName,
+			toEventName,
+			err,
+		)
+	}
+
+	lastSequence, err := e.replay(ctx, toEventName, handler, FromSequence(checkpoint))
+	if err != nil {
+		log.Printf(
+			"failed to catch subscriber '%s' up on %s after sequence %d: %v\n",
+			handler.SubscriberName,
+			toEventName,
+			lastSequence,
+			err,
+		)
+	}
+
+	if lastSequence > checkpoint {
+		log.Printf(
+			"subscriber '%s' caught up on %s from sequence %d to %d\n",
+			handler.SubscriberName,
+			toEventName,
+			checkpoint,
+			lastSequence,
+		)
+	}
+
+	return lastSequence
+}
+
+// replay hands the recorded events from the given position that toEventName
+// matches to handler. It returns the sequence of the last event it read.
+func (e *eventEngine) replay(
+	ctx context.Context,
+	toEventName event.EventName,
+	handler *Handler,
+	from ReplayPosition,
+) (uint64, error) {
+	lastSequence := from.afterSequence
+
+	for {
+		records, err := e.EventStore.Read(ctx, lastSequence, from.since, replayBatchSize)
+		if err != nil {
+			return lastSequence, err
+		}
+
+		for _, record := range records {
+			select {
+			case <-ctx.Done():
+				return lastSequence, ctx.Err()
+			case <-e.DoneCh:
+				return lastSequence, nil
+			default:
+			}
+
+			if SubscribedTo(toEventName, record.EventName) {
+				payload, version, err := e.decodePayload(record.EventName, record.EventVersion, record.Payload, handler.PayloadType)
+				if err != nil {
+					log.Printf(
+						"subscriber '%s' skipped recorded event %d of %s: %v\n",
+						handler.SubscriberName,
+						record.Sequence,
+						record.EventName,
+						err,
+					)
+				} else {
+					replayedEvent := record.event(payload)
+					replayedEvent.Version = version
+					e.handle(ctx, toEventName, handler, replayedEvent)
+				}
+			}
+
+			lastSequence = record.Sequence
+		}
+
+		if len(records) < replayBatchSize {
+			return lastSequence, nil
+		}
+	}
+}
+
+// saveCheckpoint records that handler handled the events of toEventName up
+// to sequence.
+func (e *eventEngine) saveCheckpoint(toEventName event.EventName, handler *Handler, sequence uint64) {
+	ctx, cancel := context.WithTimeout(context.Background(), (10 * time.Second))
+	defer cancel()
+
+	err := e.EventStore.SaveCheckpoint(ctx, handler.SubscriberName, toEventName, sequence)
+	if err != nil {
+		log.Printf(
+			"failed to save checkpoint %d of subscriber '%s' for %s: %v\n",
+			sequence,
+			handler.SubscriberName,
+			toEventName,
+			err,
+		)
+	}
+}
@@ -0,0 +1,192 @@
+package eventengine
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/eng-by-sjb/yellow-pines-e-commerce-backend/internal/eventengine/event"
+)
+
+const (
+	eventRecordFields = "sequence, event_id, event_name, event_version, occurred_at, correlation_id, causation_id, producer, payload, recorded_at"
+)
+
+type postgresEventStore struct {
+	db *sql.DB
+}
+
+// NewPostgresEventStore returns an [EventStore] backed by the event_log and
+// event_checkpoints tables, so the event log and checkpoints survive
+// restarts.
+func NewPostgresEventStore(db *sql.DB) EventStore {
+	return &postgresEventStore{
+		db: db,
+	}
+}
+
+func (s *postgresEventStore) Append(ctx context.Context, record *EventRecord) error {
+	return appendRecord(ctx, s.db, record)
+}
+
+func (s *postgresEventStore) AppendBatch(ctx context.Context, records []*EventRecord) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf(
+			"failed to begin transaction in event store: %w",
+			err,
+		)
+	}
+	defer tx.Rollback()
+
+	for _, record := range records {
+		if err := appendRecord(ctx, tx, record); err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf(
+			"failed to commit batch of events in event store: %w",
+			err,
+		)
+	}
+
+	return nil
+}
+
+// queryRower is either a *sql.DB or a *sql.Tx.
+type queryRower interface {
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+func appendRecord(ctx context.Context, db queryRower, record *EventRecord) error {
+	// on conflict, the no-op update makes RETURNING return the record the
+	// event was first appended as.
+	err := db.QueryRowContext(
+		ctx,
+		`INSERT INTO event_log(event_id, event_name, event_version, occurred_at, correlation_id, causation_id, producer, payload)
+		VALUES($1, $2, $3, $4, $5, $6, $7, $8)
+		ON CONFLICT (event_id) DO UPDATE SET event_id = EXCLUDED.event_id
+		RETURNING sequence, recorded_at`,
+		record.EventID,
+		record.EventName,
+		record.EventVersion,
+		record.OccurredAt,
+		record.CorrelationID,
+		record.CausationID,
+		record.Producer,
+		[]byte(record.Payload),
+	).Scan(
+		&record.Sequence,
+		&record.RecordedAt,
+	)
+	if err != nil {
+		return fmt.Errorf(
+			"failed to insert event in event store: %w",
+			err,
+		)
+	}
+
+	return nil
+}
+
+func (s *postgresEventStore) Read(ctx context.Context, afterSequence uint64, since time.Time, limit int) ([]*EventRecord, error) {
+	query := fmt.Sprintf(
+		"SELECT %s FROM event_log WHERE sequence > $1 AND ($2::TIMESTAMP IS NULL OR occurred_at >= $2) ORDER BY sequence LIMIT $3",
+		eventRecordFields,
+	)
+
+	var sinceParam sql.NullTime
+	if !since.IsZero() {
+		sinceParam = sql.NullTime{Time: since, Valid: true}
+	}
+
+	rows, err := s.db.QueryContext(ctx, query, afterSequence, sinceParam, limit)
+	if err != nil {
+		return nil, fmt.Errorf(
+			"failed to query events in event store: %w",
+			err,
+		)
+	}
+	defer rows.Close()
+
+	records := []*EventRecord{}
+	for rows.Next() {
+		record, err := scanRowIntoEventRecord(rows)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, record)
+	}
+
+	return records, rows.Err()
+}
+
+func (s *postgresEventStore) Checkpoint(ctx context.Context, subscriberName event.SubscriberName, eventName event.EventName) (uint64, error) {
+	var sequence uint64
+
+	err := s.db.QueryRowContext(
+		ctx,
+		"SELECT COALESCE(MAX(sequence), 0) FROM event_checkpoints WHERE subscriber_name = $1 AND event_name = $2",
+		subscriberName,
+		eventName,
+	).Scan(&sequence)
+	if err != nil {
+		return 0, fmt.Errorf(
+			"failed to get checkpoint in event store: %w",
+			err,
+		)
+	}
+
+	return sequence, nil
+}
+
+func (s *postgresEventStore) SaveCheckpoint(ctx context.Context, subscriberName event.SubscriberName, eventName event.EventName, sequence uint64) error {
+	_, err := s.db.ExecContext(
+		ctx,
+		`INSERT INTO event_checkpoints(subscriber_name, event_name, sequence)
+		VALUES($1, $2, $3)
+		ON CONFLICT (subscriber_name, event_name) DO UPDATE
+		SET sequence = GREATEST(event_checkpoints.sequence, EXCLUDED.sequence), updated_at = NOW()`,
+		subscriberName,
+		eventName,
+		sequence,
+	)
+	if err != nil {
+		return fmt.Errorf(
+			"failed to save checkpoint in event store: %w",
+			err,
+		)
+	}
+
+	return nil
+}
+
+func scanRowIntoEventRecord(row rowScanner) (*EventRecord, error) {
+	record := new(EventRecord)
+	var payload []byte
+
+	err := row.Scan(
+		&record.Sequence,
+		&record.EventID,
+		&record.EventName,
+		&record.EventVersion,
+		&record.OccurredAt,
+		&record.CorrelationID,
+		&record.CausationID,
+		&record.Producer,
+		&payload,
+		&record.RecordedAt,
+	)
+	if err != nil {
+		return nil, fmt.Errorf(
+			"failed to scan row into event record in event store: %w",
+			err,
+		)
+	}
+	record.Payload = payload
+
+	return record, nil
+}
@@ -0,0 +1,185 @@
+package eventengine
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/eng-by-sjb/yellow-pines-e-commerce-backend/internal/eventengine/event"
+	"github.com/google/uuid"
+)
+
+func Test_memoryEventStore(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryEventStore()
+	start := time.Now().UTC()
+
+	records := []*EventRecord{
+		{EventID: uuid.New(), EventName: "test.event.store.a", OccurredAt: start.Add(-time.Hour), Payload: []byte(`1`)},
+		{EventID: uuid.New(), EventName: "test.event.store.b", OccurredAt: start, Payload: []byte(`2`)},
+		{EventID: uuid.New(), EventName: "test.event.store.a", OccurredAt: start.Add(time.Hour), Payload: []byte(`3`)},
+	}
+	for i, record := range records {
+		if err := store.Append(ctx, record); err != nil {
+			t.Fatalf("expected to append, got: %v", err)
+		}
+		if record.Sequence != uint64(i+1) {
+			t.Fatalf("expected sequence %d, got: %d", i+1, record.Sequence)
+		}
+	}
+
+	again := &EventRecord{EventID: records[1].EventID, EventName: "test.event.store.b"}
+	if err := store.Append(ctx, again); err != nil || again.Sequence != 2 {
+		t.Fatalf("expected appending an event again to return sequence 2, got: %d, %v", again.Sequence, err)
+	}
+
+	read, _ := store.Read(ctx, 1, time.Time{}, 10)
+	if len(read) != 2 || read[0].Sequence != 2 || read[1].Sequence != 3 {
+		t.Fatalf("expected the records after sequence 1, got: %+v", read)
+	}
+
+	read, _ = store.Read(ctx, 0, start, 10)
+	if len(read) != 2 || read[0].Sequence != 2 {
+		t.Fatalf("expected the records that occurred since start, got: %+v", read)
+	}
+
+	read, _ = store.Read(ctx, 0, time.Time{}, 1)
+	if len(read) != 1 || read[0].Sequence != 1 {
+		t.Fatalf("expected limit to be applied, got: %+v", read)
+	}
+
+	store.SaveCheckpoint(ctx, "test_subscriber_name", "test.event.store.a", 3)
+	store.SaveCheckpoint(ctx, "test_subscriber_name", "test.event.store.a", 1)
+	if checkpoint, _ := store.Checkpoint(ctx, "test_subscriber_name", "test.event.store.a"); checkpoint != 3 {
+		t.Fatalf("expected the checkpoint not to move back from 3, got: %d", checkpoint)
+	}
+}
+
+func Test_durableSubscription(t *testing.T) {
+	type testPayload struct {
+		Value int
+	}
+
+	var eventName event.EventName = "test.event.engine.durable"
+	store := NewMemoryEventStore()
+
+	// newEngine starts an event engine recording in store, as if the server
+	// started again.
+	newEngine := func() (Engine, func()) {
+		doneCh := make(chan struct{})
+		InternalSrvWG := &sync.WaitGroup{}
+
+		engine := NewEventEngine(
+			&EventEngineConfig{
+				DoneCh:        doneCh,
+				InternalSrvWG: InternalSrvWG,
+				EventStore:    store,
+			},
+		)
+		engine.RegisterEvents(eventName)
+
+		return engine, func() {
+			close(doneCh)
+			InternalSrvWG.Wait()
+		}
+	}
+
+	publish := func(engine Engine, value int) {
+		err := engine.Publish(event.New(context.Background(), "test_producer", eventName, &testPayload{Value: value}))
+		if err != nil {
+			t.Fatalf("expected to publish, got: %v", err)
+		}
+	}
+
+	subscribe := func(engine Engine, handledCh chan int) {
+		_, err := Subscribe(
+			engine,
+			eventName,
+			&SubscriptionConfig{
+				SubscriberName: "test_subscriber_name.durable",
+				Durable:        true,
+			},
+			func(_ context.Context, payload *testPayload) error {
+				handledCh <- payload.Value
+				return nil
+			},
+		)
+		if err != nil {
+			t.Fatalf("expected to subscribe durably, got: %v", err)
+		}
+	}
+
+	expectHandled := func(handledCh chan int, want ...int) {
+		for _, value := range want {
+			select {
+			case got := <-handledCh:
+				if got != value {
+					t.Fatalf("expected to handle %d, got: %d", value, got)
+				}
+			case <-time.After(2 * time.Second):
+				t.Fatalf("expected to handle %d", value)
+			}
+		}
+
+		select {
+		case got := <-handledCh:
+			t.Fatalf("expected no more events, got: %d", got)
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+
+	// events published before the subscriber exists are replayed to it.
+	engine, shutdown := newEngine()
+	publish(engine, 1)
+	publish(engine, 2)
+
+	handledCh := make(chan int, 10)
+	subscribe(engine, handledCh)
+	publish(engine, 3)
+	expectHandled(handledCh, 1, 2, 3)
+	shutdown()
+
+	// after a restart, it resumes after its checkpoint.
+	engine, shutdown = newEngine()
+	publish(engine, 4)
+
+	handledCh = make(chan int, 10)
+	subscribe(engine, handledCh)
+	expectHandled(handledCh, 4)
+
+	// replaying from a sequence hands it the events again.
+	err := engine.Replay(context.Background(), eventName, "test_subscriber_name.durable", FromSequence(2))
+	if err != nil {
+		t.Fatalf("expected to replay, got: %v", err)
+	}
+	expectHandled(handledCh, 3, 4)
+	shutdown()
+
+	doneCh := make(chan struct{})
+	defer close(doneCh)
+
+	_, err = Subscribe(
+		NewEventEngine(&EventEngineConfig{DoneCh: doneCh, InternalSrvWG: &sync.WaitGroup{}}),
+		eventName,
+		&SubscriptionConfig{SubscriberName: "test_subscriber_name.durable", Durable: true},
+		func(context.Context, *testPayload) error { return nil },
+	)
+	if err == nil {
+		t.Fatal("expected subscribing durably without an event store to fail")
+	}
+
+	engine, shutdown = newEngine()
+	defer shutdown()
+
+	_, err = Subscribe(
+		engine,
+		eventName,
+		&SubscriptionConfig{SubscriberName: "test_subscriber_name.durable", Durable: true, Concurrency: 2},
+		func(context.Context, *testPayload) error { return nil },
+	)
+	if !errors.Is(err, ErrDurableConcurrency) {
+		t.Fatalf("expected subscribing durably with several workers to fail with ErrDurableConcurrency, got: %v", err)
+	}
+}
@@ -0,0 +1,610 @@
+// Package eventenginetest provides a fake event engine, so features that
+// publish and subscribe to events can be unit tested without the go routines
+// and channels of the real one.
+//
+// The fake records every event published to it and hands events to the
+// handlers subscribed to them, either on a go routine of its own like the
+// event engine ([New]) or before Publish returns ([NewSynchronous]).
+//
+//	engine := eventenginetest.NewSynchronous(t)
+//	eventengine.RunModule(engine, inventory.NewEventsModule(&inventory.HandlerEventsConfig{EventEngine: engine, Service: service}))
+//
+//	engine.Publish(event.New(ctx, "test", event.ProductCreatedEventName, &event.ProductCreatedEvent{...}))
+//	engine.AssertPublished(t, event.InventoryCreatedEventName, eventenginetest.Payload(
+//		func(payload *event.InventoryCreatedEvent) bool { return payload.ProductID == productID },
+//	))
+package eventenginetest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/eng-by-sjb/yellow-pines-e-commerce-backend/internal/eventengine"
+	"github.com/eng-by-sjb/yellow-pines-e-commerce-backend/internal/eventengine/event"
+)
+
+var _ eventengine.SubscribeRegisterPublisher = (*Engine)(nil)
+
+// Engine is a fake [eventengine.SubscribeRegisterPublisher]. It checks
+// events are registered and payload types match like the event engine does,
+// but it does not retry handlers: a handler that fails is dead lettered at
+// once, i.e. its OnDeadLetter is called, and the failure is recorded, see
+// Failures.
+//
+// Unlike the event engine, it never closes the AddressCh of a subscriber.
+type Engine struct {
+	synchronous bool
+
+	mu            sync.Mutex
+	idle          *sync.Cond // signalled whenever undelivered drops to 0.
+	registered    map[event.EventName]bool
+	payloadTypes  map[event.EventName]reflect.Type
+	subscriptions []*subscription
+	published     []*event.Event
+	failures      []*Failure
+	publishedCh   chan struct{} // closed and replaced whenever an event is published.
+
+	// pending and undelivered are only used when delivering asynchronously.
+	pending     []*event.Event
+	undelivered int
+	pendingCh   chan struct{}
+	closeCh     chan struct{}
+	closedCh    chan struct{}
+	closeOnce   sync.Once
+}
+
+// subscription is either a handler or a subscriber reading its own AddressCh.
+type subscription struct {
+	toEventName event.EventName
+	name        event.SubscriberName
+	handler     *eventengine.Handler
+	addressCh   chan<- *event.Event
+}
+
+// Failure is an event a handler failed to handle.
+type Failure struct {
+	Event          *event.Event
+	SubscriberName event.SubscriberName
+	Err            error
+}
+
+// New returns a fake event engine that hands published events to handlers
+// on a go routine of its own, in the order they were published. Use Wait or
+// WaitFor to wait for them to be handled.
+//
+// The go routine is stopped once t finished.
+func New(t testing.TB) *Engine {
+	e := newEngine(false)
+
+	go e.run()
+	t.Cleanup(e.Close)
+
+	return e
+}
+
+// NewSynchronous returns a fake event engine whose Publish hands the event
+// to every handler subscribed to it, and to the handlers of the events they
+// publish in turn, before it returns.
+func NewSynchronous(t testing.TB) *Engine {
+	return newEngine(true)
+}
+
+func newEngine(synchronous bool) *Engine {
+	e := &Engine{
+		synchronous:  synchronous,
+		registered:   make(map[event.EventName]bool),
+		payloadTypes: make(map[event.EventName]reflect.Type),
+		publishedCh:  make(chan struct{}),
+		pendingCh:    make(chan struct{}, 1),
+		closeCh:      make(chan struct{}),
+		closedCh:     make(chan struct{}),
+	}
+	e.idle = sync.NewCond(&e.mu)
+
+	return e
+}
+
+func (e *Engine) RegisterEvents(eventNames ...event.EventName) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	for _, eventName := range eventNames {
+		e.registered[eventName] = true
+	}
+}
+
+// Subscribe subscribes newSubscriber to toEventName, which has to be
+// registered unless it is a pattern. Events are sent to its AddressCh, and
+// Publish blocks while it is full.
+func (e *Engine) Subscribe(toEventName event.EventName, newSubscriber *event.Subscriber) (eventengine.CancelFunc, error) {
+	if newSubscriber.AddressCh == nil {
+		return nil, fmt.Errorf("subscriber '%v's addressCh is nil", newSubscriber.Name)
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if !e.registered[toEventName] && !eventengine.IsPattern(toEventName) {
+		return nil, fmt.Errorf("event '%v' not found. register it before subscribing to it", toEventName)
+	}
+
+	return e.addSubscription(&subscription{
+		toEventName: toEventName,
+		name:        newSubscriber.Name,
+		addressCh:   newSubscriber.AddressCh,
+	}), nil
+}
+
+// SubscribeHandler subscribes handler to toEventName and, unless it is a
+// pattern, binds toEventName to handler.PayloadType.
+func (e *Engine) SubscribeHandler(toEventName event.EventName, handler *eventengine.Handler) (eventengine.CancelFunc, error) {
+	if handler == nil || handler.SubscriptionConfig == nil || handler.Handle == nil || handler.PayloadType == nil {
+		return nil, fmt.Errorf(
+			"either handler, its 'SubscriptionConfig', 'Handle' or 'PayloadType' is nil when subscribing to event '%v'",
+			toEventName,
+		)
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if boundType, ok := e.payloadTypes[toEventName]; ok && boundType != handler.PayloadType {
+		return nil, fmt.Errorf(
+			"%w: subscriber '%v' expects '%v' but event '%v' is bound to '%v'",
+			eventengine.ErrPayloadTypeMismatch,
+			handler.SubscriberName,
+			handler.PayloadType,
+			toEventName,
+			boundType,
+		)
+	}
+
+	for _, s := range e.subscriptions {
+		if s.handler != nil && s.toEventName == toEventName && s.name == handler.SubscriberName {
+			return nil, fmt.Errorf(
+				"subscriber '%v' is already subscribed to event '%v'",
+				handler.SubscriberName,
+				toEventName,
+			)
+		}
+	}
+
+	if !eventengine.IsPattern(toEventName) {
+		e.payloadTypes[toEventName] = handler.PayloadType
+	}
+
+	return e.addSubscription(&subscription{
+		toEventName: toEventName,
+		name:        handler.SubscriberName,
+		handler:     handler,
+	}), nil
+}
+
+// addSubscription must be called with e.mu held.
+func (e *Engine) addSubscription(s *subscription) eventengine.CancelFunc {
+	e.subscriptions = append(e.subscriptions, s)
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			e.removeSubscriptions(func(other *subscription) bool { return other == s })
+		})
+	}
+}
+
+func (e *Engine) Unsubscribe(toEventName event.EventName, subscriberName event.SubscriberName) error {
+	removed := e.removeSubscriptions(func(s *subscription) bool {
+		return s.toEventName == toEventName && s.name == subscriberName
+	})
+	if removed == 0 {
+		return fmt.Errorf(
+			"%w: subscriber '%v' is not subscribed to event '%v'",
+			eventengine.ErrSubscriptionNotFound,
+			subscriberName,
+			toEventName,
+		)
+	}
+
+	return nil
+}
+
+func (e *Engine) removeSubscriptions(remove func(s *subscription) bool) int {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	kept := make([]*subscription, 0, len(e.subscriptions))
+	for _, s := range e.subscriptions {
+		if !remove(s) {
+			kept = append(kept, s)
+		}
+	}
+	removed := len(e.subscriptions) - len(kept)
+	e.subscriptions = kept
+
+	return removed
+}
+
+// Publish records newEvent and hands it to its subscribers. Like the event
+// engine, it fails if newEvent is not registered or its payload does not
+// match the type its name is bound to.
+func (e *Engine) Publish(newEvent *event.Event) error {
+	e.mu.Lock()
+
+	if err := e.check(newEvent); err != nil {
+		e.mu.Unlock()
+		return err
+	}
+
+	e.enqueue(newEvent)
+
+	return nil
+}
+
+// PublishContext is Publish, except that it fails if ctx is done already.
+func (e *Engine) PublishContext(ctx context.Context, newEvent *event.Event) error {
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("failed to publish: %w", err)
+	}
+
+	return e.Publish(newEvent)
+}
+
+// PublishBatch publishes every event of newEvents in order, or none of them
+// if one fails the checks of Publish.
+func (e *Engine) PublishBatch(ctx context.Context, newEvents ...*event.Event) error {
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("failed to publish: %w", err)
+	}
+
+	e.mu.Lock()
+	for _, newEvent := range newEvents {
+		if err := e.check(newEvent); err != nil {
+			e.mu.Unlock()
+			return err
+		}
+	}
+	e.mu.Unlock()
+
+	for _, newEvent := range newEvents {
+		e.mu.Lock()
+		e.enqueue(newEvent)
+	}
+
+	return nil
+}
+
+// PublishAndWait publishes newEvent and waits until it was handed to its
+// subscribers, including the events their handlers published meanwhile. It
+// returns the errors of the handlers that failed to handle newEvent.
+func (e *Engine) PublishAndWait(ctx context.Context, newEvent *event.Event) error {
+	if err := e.PublishContext(ctx, newEvent); err != nil {
+		return err
+	}
+
+	waitedCh := make(chan struct{})
+	go func() {
+		e.Wait()
+		close(waitedCh)
+	}()
+
+	select {
+	case <-waitedCh:
+	case <-ctx.Done():
+		return fmt.Errorf("event %s was published, but not every subscriber handled it: %w", newEvent, ctx.Err())
+	}
+
+	var errs []error
+	for _, failure := range e.Failures() {
+		if failure.Event == newEvent {
+			errs = append(errs, fmt.Errorf("subscriber '%s': %w", failure.SubscriberName, failure.Err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// check fails like the event engine if newEvent can not be published. e.mu
+// must be held.
+func (e *Engine) check(newEvent *event.Event) error {
+	if !e.registered[newEvent.Name] {
+		return fmt.Errorf(
+			"event %v not found. check the service which is to publish the event to make sure they called the 'RegisterEvents()'",
+			newEvent.Name,
+		)
+	}
+
+	if boundType, ok := e.payloadTypes[newEvent.Name]; ok && reflect.TypeOf(newEvent.Payload) != boundType {
+		return fmt.Errorf(
+			"%w: event '%v' is bound to '%v' but was published with '%T'",
+			eventengine.ErrPayloadTypeMismatch,
+			newEvent.Name,
+			boundType,
+			newEvent.Payload,
+		)
+	}
+
+	return nil
+}
+
+// enqueue records newEvent and hands it to its subscribers. It is called with
+// e.mu held, and releases it.
+func (e *Engine) enqueue(newEvent *event.Event) {
+	e.published = append(e.published, newEvent)
+	close(e.publishedCh)
+	e.publishedCh = make(chan struct{})
+
+	if e.synchronous {
+		e.mu.Unlock()
+		e.deliver(newEvent)
+		return
+	}
+
+	e.pending = append(e.pending, newEvent)
+	e.undelivered++
+	e.mu.Unlock()
+
+	select {
+	case e.pendingCh <- struct{}{}:
+	default: // the go routine delivering events was already signalled.
+	}
+}
+
+// run delivers the pending events until the fake is closed.
+func (e *Engine) run() {
+	defer close(e.closedCh)
+
+	for {
+		select {
+		case <-e.closeCh:
+			return
+		case <-e.pendingCh:
+		}
+
+		for {
+			e.mu.Lock()
+			if len(e.pending) == 0 {
+				e.mu.Unlock()
+				break
+			}
+			newEvent := e.pending[0]
+			e.pending = e.pending[1:]
+			e.mu.Unlock()
+
+			e.deliver(newEvent)
+
+			e.mu.Lock()
+			e.undelivered--
+			if e.undelivered == 0 {
+				e.idle.Broadcast()
+			}
+			e.mu.Unlock()
+		}
+	}
+}
+
+// deliver hands newEvent to every subscription it matches.
+func (e *Engine) deliver(newEvent *event.Event) {
+	e.mu.Lock()
+	var matching []*subscription
+	for _, s := range e.subscriptions {
+		if eventengine.SubscribedTo(s.toEventName, newEvent.Name) {
+			matching = append(matching, s)
+		}
+	}
+	e.mu.Unlock()
+
+	for _, s := range matching {
+		if s.handler == nil {
+			s.addressCh <- newEvent
+			continue
+		}
+
+		e.handle(s.handler, newEvent)
+	}
+}
+
+// handle calls handler with newEvent once, with a context like the one the
+// event engine passes, and dead letters newEvent if handler fails.
+func (e *Engine) handle(handler *eventengine.Handler, newEvent *event.Event) {
+	// a pattern subscriber can receive events of any payload type.
+	if payloadType := reflect.TypeOf(newEvent.Payload); payloadType == nil || !payloadType.AssignableTo(handler.PayloadType) {
+		return
+	}
+
+	timeout := handler.HandlerTimeout
+	if timeout <= 0 {
+		timeout = eventengine.DefaultHandlerTimeout
+	}
+
+	ctx := event.ContextWithEvent(newEvent.Context(), newEvent)
+	handlerCtx, cancel := context.WithTimeout(ctx, timeout)
+	err := handler.Handle(handlerCtx, newEvent.Payload)
+	cancel()
+
+	if err == nil {
+		return
+	}
+
+	e.mu.Lock()
+	e.failures = append(e.failures, &Failure{
+		Event:          newEvent,
+		SubscriberName: handler.SubscriberName,
+		Err:            err,
+	})
+	e.mu.Unlock()
+
+	if handler.OnDeadLetter != nil {
+		if err := handler.OnDeadLetter(ctx, newEvent.Payload, err); err != nil {
+			e.mu.Lock()
+			e.failures = append(e.failures, &Failure{
+				Event:          newEvent,
+				SubscriberName: handler.SubscriberName,
+				Err:            fmt.Errorf("OnDeadLetter failed: %w", err),
+			})
+			e.mu.Unlock()
+		}
+	}
+}
+
+// Wait returns once every event published so far was handed to its
+// subscribers, including the events their handlers published meanwhile.
+func (e *Engine) Wait() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	for e.undelivered > 0 {
+		e.idle.Wait()
+	}
+}
+
+// Close waits for the events published so far to be delivered, then stops
+// the go routine delivering them. Events published afterwards are still
+// recorded but not delivered. It is called once the test of [New] finished.
+func (e *Engine) Close() {
+	if e.synchronous {
+		return
+	}
+
+	e.closeOnce.Do(func() {
+		e.Wait()
+		close(e.closeCh)
+		<-e.closedCh
+	})
+}
+
+// Published returns every event published so far, in the order they were
+// published.
+func (e *Engine) Published() []*event.Event {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	return append([]*event.Event(nil), e.published...)
+}
+
+// Failures returns the events handlers failed to handle so far.
+func (e *Engine) Failures() []*Failure {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	return append([]*Failure(nil), e.failures...)
+}
+
+// Reset forgets the events published and the failures recorded so far, but
+// keeps the registered events and subscriptions.
+func (e *Engine) Reset() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.published = nil
+	e.failures = nil
+}
+
+// Matcher reports whether a published event is the one looked for. A nil
+// Matcher matches every event.
+type Matcher func(e *event.Event) bool
+
+// Payload returns a [Matcher] of the events whose payload is a T that match
+// accepts. A nil match accepts every T.
+func Payload[T any](match func(payload T) bool) Matcher {
+	return func(e *event.Event) bool {
+		payload, ok := e.Payload.(T)
+		if !ok {
+			return false
+		}
+
+		return match == nil || match(payload)
+	}
+}
+
+// find returns the first event published so far that eventName, which can be
+// a pattern, and matcher match.
+func (e *Engine) find(eventName event.EventName, matcher Matcher) (*event.Event, bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	for _, published := range e.published {
+		if eventengine.SubscribedTo(eventName, published.Name) && (matcher == nil || matcher(published)) {
+			return published, true
+		}
+	}
+
+	return nil, false
+}
+
+// AssertPublished fails t unless an event named eventName, which can be a
+// pattern, that matcher matches was published. It returns the first such
+// event.
+func (e *Engine) AssertPublished(t testing.TB, eventName event.EventName, matcher Matcher) *event.Event {
+	t.Helper()
+
+	published, ok := e.find(eventName, matcher)
+	if !ok {
+		t.Fatalf(
+			"expected event '%s' to be published, got: %s",
+			eventName,
+			e.publishedNames(),
+		)
+	}
+
+	return published
+}
+
+// AssertNotPublished fails t if an event named eventName, which can be a
+// pattern, that matcher matches was published.
+func (e *Engine) AssertNotPublished(t testing.TB, eventName event.EventName, matcher Matcher) {
+	t.Helper()
+
+	if published, ok := e.find(eventName, matcher); ok {
+		t.Fatalf("expected event '%s' not to be published, got: %s", eventName, published)
+	}
+}
+
+// WaitFor returns the first event named eventName, which can be a pattern,
+// that was published, waiting up to timeout for it to be published.
+func (e *Engine) WaitFor(eventName event.EventName, timeout time.Duration) (*event.Event, error) {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	for {
+		e.mu.Lock()
+		publishedCh := e.publishedCh
+		e.mu.Unlock()
+
+		if published, ok := e.find(eventName, nil); ok {
+			return published, nil
+		}
+
+		select {
+		case <-publishedCh:
+		case <-timer.C:
+			return nil, fmt.Errorf(
+				"event '%s' was not published within %v, got: %s",
+				eventName,
+				timeout,
+				e.publishedNames(),
+			)
+		}
+	}
+}
+
+func (e *Engine) publishedNames() string {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if len(e.published) == 0 {
+		return "no events"
+	}
+
+	names := make([]string, 0, len(e.published))
+	for _, published := range e.published {
+		names = append(names, string(published.Name))
+	}
+
+	return strings.Join(names, ", ")
+}
@@ -0,0 +1,129 @@
+package eventenginetest
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/eng-by-sjb/yellow-pines-e-commerce-backend/internal/eventengine"
+	"github.com/eng-by-sjb/yellow-pines-e-commerce-backend/internal/eventengine/event"
+)
+
+type testPayload struct {
+	Value int
+}
+
+const (
+	requestedEventName event.EventName = "test.fake.requested"
+	answeredEventName  event.EventName = "test.fake.answered"
+)
+
+// subscribeAnswerer subscribes a handler to requestedEventName that publishes
+// answeredEventName with the value it received, and fails for negative
+// values.
+func subscribeAnswerer(t *testing.T, engine *Engine) {
+	engine.RegisterEvents(requestedEventName, answeredEventName)
+
+	_, err := eventengine.Subscribe(
+		engine,
+		requestedEventName,
+		&eventengine.SubscriptionConfig{SubscriberName: "test_answerer"},
+		func(ctx context.Context, payload *testPayload) error {
+			if payload.Value < 0 {
+				return errors.New("negative value")
+			}
+
+			return engine.Publish(event.New(ctx, "test_answerer", answeredEventName, &testPayload{Value: payload.Value}))
+		},
+	)
+	if err != nil {
+		t.Fatalf("expected to subscribe, got: %v", err)
+	}
+}
+
+func valueIs(value int) Matcher {
+	return Payload(func(payload *testPayload) bool { return payload.Value == value })
+}
+
+func Test_Synchronous(t *testing.T) {
+	engine := NewSynchronous(t)
+	subscribeAnswerer(t, engine)
+
+	requested := event.New(context.Background(), "test_producer", requestedEventName, &testPayload{Value: 1})
+	if err := engine.Publish(requested); err != nil {
+		t.Fatalf("expected to publish, got: %v", err)
+	}
+
+	// the handler ran before Publish returned.
+	answered := engine.AssertPublished(t, answeredEventName, valueIs(1))
+	if answered.CausationID != requested.ID {
+		t.Fatalf("expected the answer to be caused by the request, got causation id: %s", answered.CausationID)
+	}
+	engine.AssertPublished(t, "test.fake.*", nil)
+	engine.AssertNotPublished(t, answeredEventName, valueIs(2))
+
+	if err := engine.Publish(event.New(context.Background(), "test_producer", requestedEventName, &testPayload{Value: -1})); err != nil {
+		t.Fatalf("expected to publish, got: %v", err)
+	}
+
+	if failures := engine.Failures(); len(failures) != 1 || failures[0].SubscriberName != "test_answerer" {
+		t.Fatalf("expected the handler to fail once, got: %v", failures)
+	}
+
+	err := engine.Publish(event.New(context.Background(), "test_producer", requestedEventName, "not a *testPayload"))
+	if !errors.Is(err, eventengine.ErrPayloadTypeMismatch) {
+		t.Fatalf("expected '%v', got: %v", eventengine.ErrPayloadTypeMismatch, err)
+	}
+
+	err = engine.Publish(event.New(context.Background(), "test_producer", "test.fake.unregistered", &testPayload{}))
+	if err == nil {
+		t.Fatal("expected publishing an unregistered event to fail")
+	}
+
+	engine.Reset()
+	if published := engine.Published(); len(published) != 0 {
+		t.Fatalf("expected no events after reset, got: %d", len(published))
+	}
+}
+
+func Test_Asynchronous(t *testing.T) {
+	engine := New(t)
+	subscribeAnswerer(t, engine)
+
+	if _, err := engine.WaitFor(answeredEventName, 10*time.Millisecond); err == nil {
+		t.Fatal("expected to time out waiting for an event that was not published")
+	}
+
+	for value := range 3 {
+		if err := engine.Publish(event.New(context.Background(), "test_producer", requestedEventName, &testPayload{Value: value})); err != nil {
+			t.Fatalf("expected to publish, got: %v", err)
+		}
+	}
+
+	answered, err := engine.WaitFor(answeredEventName, 2*time.Second)
+	if err != nil {
+		t.Fatalf("expected the answer to be published, got: %v", err)
+	}
+	if value := answered.Payload.(*testPayload).Value; value != 0 {
+		t.Fatalf("expected the first answer to be 0, got: %d", value)
+	}
+
+	engine.Wait()
+
+	published := engine.Published()
+	if len(published) != 6 {
+		t.Fatalf("expected 3 requests and 3 answers once every event was handled, got: %d events", len(published))
+	}
+
+	// events are handled in the order they were published.
+	value := 0
+	for _, e := range published {
+		if e.Name == answeredEventName {
+			if got := e.Payload.(*testPayload).Value; got != value {
+				t.Fatalf("expected answer %d, got: %d", value, got)
+			}
+			value++
+		}
+	}
+}
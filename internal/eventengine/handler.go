@@ -0,0 +1,125 @@
+package eventengine
+
+import (
+	"context"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/eng-by-sjb/yellow-pines-e-commerce-backend/internal/eventengine/event"
+)
+
+type SubscriptionConfig struct {
+	SubscriberName event.SubscriberName // Name of subscriber
+	AddressChSize  uint16               // Buffer size of the addressCh the event engine creates for the subscriber.
+	Retry          *RetryPolicy         // How a failing handler is retried. Defaults to DefaultRetryPolicy.
+	Restart        *RestartPolicy       // How a handler that panicked is restarted. Defaults to DefaultRestartPolicy.
+
+	Backpressure event.BackpressurePolicy // What to do when the addressCh is full. Defaults to event.BlockPolicy.
+	BlockTimeout time.Duration            // How long to wait when Backpressure is event.BlockWithTimeoutPolicy.
+
+	// HandlerTimeout is how long one attempt to handle an event may take
+	// before the context of the handler is canceled. Defaults to
+	// DefaultHandlerTimeout.
+	HandlerTimeout time.Duration
+
+	// Concurrency is how many workers handle the events of the subscriber
+	// at the same time. Defaults to 1, which handles every event in the order
+	// it was published.
+	Concurrency uint16
+
+	// OrderingKey extracts the key of the events that must be handled in the
+	// order they were published from a payload, e.g. the id of a product, see
+	// [OrderBy]. Events with the same key are handled one at a time by the
+	// same worker, while events with different keys are handled in parallel.
+	// Without it, or for an empty key, events are spread across the workers
+	// and can be handled in any order.
+	OrderingKey func(payload any) string
+
+	// Durable subscriptions keep a checkpoint in the EventStore of the event
+	// engine, and first replay the events recorded after it when subscribing.
+	// That way a new subscriber handles every event recorded so far, and a
+	// subscriber that restarts resumes where it left off. Live events wait in
+	// its addressCh while it catches up. A durable subscription can not have
+	// more than one worker, see ErrDurableConcurrency.
+	Durable bool
+
+	// OnDeadLetter is called with the payload and the last error after the
+	// handler exhausted its retries and the event was dead lettered, e.g. to
+	// publish a failure event. It is optional.
+	OnDeadLetter func(ctx context.Context, payload any, err error) error
+}
+
+// DefaultHandlerTimeout is the HandlerTimeout of subscriptions that do not set
+// one.
+const DefaultHandlerTimeout = 30 * time.Second
+
+// Handler is a subscription whose addressCh is read by the event engine,
+// which calls Handle with every payload published to the subscribed event.
+//
+// Handler is usually built by [Subscribe] rather than by hand.
+type Handler struct {
+	*SubscriptionConfig
+	PayloadType reflect.Type // The payload type the subscribed event is bound to.
+	Handle      func(ctx context.Context, payload any) error
+
+	supervisor *supervisor     // restarts the workers of the handler once it panicked, and keeps its health.
+	metrics    *handlerMetrics // what the handler did, see Inspector.
+	shards     []sync.Mutex    // one per worker, held while Handle is called, so live and replayed events with the same ordering key are handled one at a time.
+	subscriber *subscriber     // the subscriber of the handler, which acknowledges the events it handled, see PublishAndWait.
+}
+
+// handlerContext returns the context handler is called with for newEvent.
+//
+// It carries the values of the context newEvent was published from, e.g. the
+// request id and the id of the admin who made the request, and newEvent
+// itself, see [event.FromContext]. It is not canceled when the request that
+// published newEvent ends, but when ctx is done, e.g. once the shutdown grace
+// period of the event engine passed.
+func handlerContext(ctx context.Context, newEvent *event.Event) (context.Context, context.CancelFunc) {
+	handlerCtx, cancel := context.WithCancel(
+		event.ContextWithEvent(newEvent.Context(), newEvent),
+	)
+	stop := context.AfterFunc(ctx, cancel)
+
+	return handlerCtx, func() {
+		stop()
+		cancel()
+	}
+}
+
+// Subscribe subscribes handle to toEventName and binds toEventName to the
+// payload type T. Publishing toEventName with any other payload type fails
+// with [ErrPayloadTypeMismatch], so handle only ever receives a T.
+//
+// The returned [CancelFunc] unsubscribes handle again.
+//
+//	cancel, err := eventengine.Subscribe(
+//		engine,
+//		event.ProductCreatedEventName,
+//		&eventengine.SubscriptionConfig{SubscriberName: subscriberName},
+//		h.productCreatedEventHandler, // func(ctx, *event.ProductCreatedEvent) error
+//	)
+func Subscribe[T any](
+	engine Subscriber,
+	toEventName event.EventName,
+	cfg *SubscriptionConfig,
+	handle func(ctx context.Context, payload T) error,
+) (CancelFunc, error) {
+	return engine.SubscribeHandler(toEventName, NewHandler(cfg, handle))
+}
+
+// NewHandler returns the [Handler] that calls handle with the payloads of
+// type T of the events it is subscribed to, e.g. for a [Module].
+func NewHandler[T any](
+	cfg *SubscriptionConfig,
+	handle func(ctx context.Context, payload T) error,
+) *Handler {
+	return &Handler{
+		SubscriptionConfig: cfg,
+		PayloadType:        reflect.TypeFor[T](),
+		Handle: func(ctx context.Context, payload any) error {
+			return handle(ctx, payload.(T))
+		},
+	}
+}
@@ -0,0 +1,178 @@
+package eventengine
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/eng-by-sjb/yellow-pines-e-commerce-backend/internal/eventengine/event"
+)
+
+func Test_handlerContext(t *testing.T) {
+	type testPayload struct {
+		Value int
+	}
+	type requestIDKey struct{}
+
+	doneCh := make(chan struct{})
+	InternalSrvWG := sync.WaitGroup{}
+
+	engine := NewEventEngine(
+		&EventEngineConfig{
+			DoneCh:              doneCh,
+			InternalSrvWG:       &InternalSrvWG,
+			ShutdownGracePeriod: 50 * time.Millisecond,
+		},
+	)
+
+	var eventName event.EventName = "test.event.engine.handler.context"
+	var slowEventName event.EventName = "test.event.engine.handler.context.slow"
+	engine.RegisterEvents(eventName, slowEventName)
+
+	type result struct {
+		requestID any
+		err       error
+	}
+	resultCh := make(chan result, 10)
+
+	handle := func(ctx context.Context, payload *testPayload) error {
+		if payload.Value == 0 {
+			resultCh <- result{requestID: ctx.Value(requestIDKey{}), err: ctx.Err()}
+			return nil
+		}
+
+		// block until the handler times out or the engine cancels it.
+		<-ctx.Done()
+		resultCh <- result{err: ctx.Err()}
+		return ctx.Err()
+	}
+
+	for toEventName, handlerTimeout := range map[event.EventName]time.Duration{
+		eventName:     100 * time.Millisecond,
+		slowEventName: time.Hour,
+	} {
+		_, err := Subscribe(
+			engine,
+			toEventName,
+			&SubscriptionConfig{
+				SubscriberName: "test_subscriber_name",
+				HandlerTimeout: handlerTimeout,
+				Retry:          &RetryPolicy{MaxAttempts: 1},
+			},
+			handle,
+		)
+		if err != nil {
+			t.Fatalf("expected to subscribe, got: %v", err)
+		}
+	}
+
+	receive := func() result {
+		select {
+		case r := <-resultCh:
+			return r
+		case <-time.After(2 * time.Second):
+			t.Fatal("expected the handler to be called")
+			return result{}
+		}
+	}
+
+	t.Run("carries the values but not the cancellation of the publisher", func(t *testing.T) {
+		requestCtx, cancelRequest := context.WithCancel(
+			context.WithValue(context.Background(), requestIDKey{}, "request-id"),
+		)
+		newEvent := event.New(requestCtx, "test_producer", eventName, &testPayload{Value: 0})
+		cancelRequest() // the request ends before the event is handled.
+
+		if err := engine.Publish(newEvent); err != nil {
+			t.Fatalf("expected to publish, got: %v", err)
+		}
+
+		r := receive()
+		if r.requestID != "request-id" {
+			t.Fatalf("expected the handler context to carry the request id, got: %v", r.requestID)
+		}
+		if r.err != nil {
+			t.Fatalf("expected the handler context not to be canceled, got: %v", r.err)
+		}
+	})
+
+	t.Run("times out", func(t *testing.T) {
+		if err := engine.Publish(event.New(context.Background(), "test_producer", eventName, &testPayload{Value: 1})); err != nil {
+			t.Fatalf("expected to publish, got: %v", err)
+		}
+
+		if r := receive(); !errors.Is(r.err, context.DeadlineExceeded) {
+			t.Fatalf("expected the handler to time out, got: %v", r.err)
+		}
+	})
+
+	t.Run("is canceled after the shutdown grace period", func(t *testing.T) {
+		if err := engine.Publish(event.New(context.Background(), "test_producer", slowEventName, &testPayload{Value: 1})); err != nil {
+			t.Fatalf("expected to publish, got: %v", err)
+		}
+
+		close(doneCh)
+
+		if r := receive(); !errors.Is(r.err, context.Canceled) {
+			t.Fatalf("expected the handler to be canceled, got: %v", r.err)
+		}
+
+		InternalSrvWG.Wait()
+	})
+}
+
+func Test_cancelHandlersAfterShutdown(t *testing.T) {
+	type testPayload struct {
+		Value int
+	}
+
+	doneCh := make(chan struct{})
+	InternalSrvWG := sync.WaitGroup{}
+
+	engine := NewEventEngine(
+		&EventEngineConfig{
+			DoneCh:              doneCh,
+			InternalSrvWG:       &InternalSrvWG,
+			ShutdownGracePeriod: time.Hour,
+		},
+	)
+
+	var eventName event.EventName = "test.event.engine.handler.shutdown"
+	engine.RegisterEvents(eventName)
+
+	handledCh := make(chan struct{}, 1)
+	_, err := Subscribe(
+		engine,
+		eventName,
+		&SubscriptionConfig{SubscriberName: "test_subscriber_name"},
+		func(ctx context.Context, payload *testPayload) error {
+			handledCh <- struct{}{}
+			return nil
+		},
+	)
+	if err != nil {
+		t.Fatalf("expected to subscribe, got: %v", err)
+	}
+
+	if err := engine.Publish(event.New(context.Background(), "test_producer", eventName, &testPayload{})); err != nil {
+		t.Fatalf("expected to publish, got: %v", err)
+	}
+	<-handledCh
+
+	close(doneCh)
+
+	// the grace period is not waited out once every handler returned.
+	shutDownCh := make(chan struct{})
+	go func() {
+		InternalSrvWG.Wait()
+		close(shutDownCh)
+	}()
+
+	select {
+	case <-shutDownCh:
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected the event engine to shut down once its handlers returned")
+	}
+}
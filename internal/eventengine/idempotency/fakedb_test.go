@@ -0,0 +1,135 @@
+package idempotency
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"maps"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+// fakeDB is an in memory processed_events ledger behind a database/sql
+// driver, which understands the query of MarkProcessed only. Writes of a
+// transaction are only kept once it is committed.
+type fakeDB struct {
+	mu        sync.Mutex
+	processed map[string]bool // event id and subscriber name, joined by "/".
+}
+
+var fakeDBs sync.Map // dsn -> *fakeDB
+
+func init() {
+	sql.Register("fakeprocessedevents", fakeDriver{})
+}
+
+// newFakeDB returns a database with an empty processed_events ledger.
+func newFakeDB(t *testing.T) (*sql.DB, *fakeDB) {
+	t.Helper()
+
+	fdb := &fakeDB{processed: make(map[string]bool)}
+	dsn := uuid.NewString()
+	fakeDBs.Store(dsn, fdb)
+
+	db, err := sql.Open("fakeprocessedevents", dsn)
+	if err != nil {
+		t.Fatalf("expected to open the fake database, got: %v", err)
+	}
+	t.Cleanup(func() {
+		db.Close()
+		fakeDBs.Delete(dsn)
+	})
+
+	return db, fdb
+}
+
+// isProcessed reports whether the ledger has a committed record of
+// subscriberName processing eventID.
+func (db *fakeDB) isProcessed(eventID uuid.UUID, subscriberName string) bool {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	return db.processed[eventID.String()+"/"+subscriberName]
+}
+
+type fakeDriver struct{}
+
+func (fakeDriver) Open(dsn string) (driver.Conn, error) {
+	fdb, ok := fakeDBs.Load(dsn)
+	if !ok {
+		return nil, fmt.Errorf("no fake database '%s'", dsn)
+	}
+
+	return &fakeConn{db: fdb.(*fakeDB)}, nil
+}
+
+type fakeConn struct {
+	db *fakeDB
+	tx *fakeTx
+}
+
+func (c *fakeConn) Prepare(query string) (driver.Stmt, error) {
+	return nil, errors.New("fake database does not prepare statements")
+}
+
+func (c *fakeConn) Close() error { return nil }
+
+func (c *fakeConn) Begin() (driver.Tx, error) {
+	return c.BeginTx(context.Background(), driver.TxOptions{})
+}
+
+func (c *fakeConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	c.db.mu.Lock()
+	defer c.db.mu.Unlock()
+
+	c.tx = &fakeTx{conn: c, processed: maps.Clone(c.db.processed)}
+	return c.tx, nil
+}
+
+// ExecContext runs query in the transaction of c, or else on the database
+// itself.
+func (c *fakeConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	c.db.mu.Lock()
+	defer c.db.mu.Unlock()
+
+	processed := c.db.processed
+	if c.tx != nil {
+		processed = c.tx.processed
+	}
+
+	if !strings.HasPrefix(query, "INSERT INTO processed_events(") {
+		return nil, fmt.Errorf("fake database does not understand query: %s", query)
+	}
+
+	key := fmt.Sprintf("%v/%v", args[0].Value, args[1].Value)
+	if processed[key] {
+		return driver.RowsAffected(0), nil
+	}
+
+	processed[key] = true
+	return driver.RowsAffected(1), nil
+}
+
+type fakeTx struct {
+	conn      *fakeConn
+	processed map[string]bool
+}
+
+func (tx *fakeTx) Commit() error {
+	tx.conn.db.mu.Lock()
+	defer tx.conn.db.mu.Unlock()
+
+	tx.conn.db.processed = tx.processed
+	tx.conn.tx = nil
+	return nil
+}
+
+func (tx *fakeTx) Rollback() error {
+	tx.conn.tx = nil
+	return nil
+}
@@ -0,0 +1,74 @@
+// Package idempotency lets subscribers process each event once in effect,
+// although the event engine can deliver an event more than once, e.g. when a
+// handler is retried, the outbox relay publishes an event again or events are
+// replayed.
+package idempotency
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/eng-by-sjb/yellow-pines-e-commerce-backend/internal/eventengine/event"
+)
+
+// ErrAlreadyProcessed is returned by [MarkProcessed] when the subscriber
+// already processed the event.
+var ErrAlreadyProcessed = errors.New("event was already processed by subscriber")
+
+// MarkProcessed records in the processed_events ledger, using tx, that
+// subscriberName processed the event ctx carries, see [event.FromContext].
+// As the record is committed or rolled back together with the subscriber's
+// own writes, the subscriber's writes for an event are made once.
+//
+// It returns ErrAlreadyProcessed if subscriberName already processed the
+// event, in which case the caller should roll tx back and treat the event as
+// handled. If ctx carries no event, e.g. outside of an event handler, there is
+// nothing to record and it returns nil.
+func MarkProcessed(ctx context.Context, tx *sql.Tx, subscriberName event.SubscriberName) error {
+	if tx == nil {
+		return errors.New("tx is nil when marking an event processed")
+	}
+
+	processedEvent, ok := event.FromContext(ctx)
+	if !ok {
+		return nil
+	}
+
+	result, err := tx.ExecContext(
+		ctx,
+		`INSERT INTO processed_events(event_id, subscriber_name) VALUES($1, $2) ON CONFLICT (event_id, subscriber_name) DO NOTHING`,
+		processedEvent.ID,
+		subscriberName,
+	)
+	if err != nil {
+		return fmt.Errorf(
+			"failed to mark event %s processed by subscriber '%s': %w",
+			processedEvent,
+			subscriberName,
+			err,
+		)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf(
+			"failed to mark event %s processed by subscriber '%s': %w",
+			processedEvent,
+			subscriberName,
+			err,
+		)
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf(
+			"%w: event %s, subscriber '%s'",
+			ErrAlreadyProcessed,
+			processedEvent,
+			subscriberName,
+		)
+	}
+
+	return nil
+}
@@ -0,0 +1,75 @@
+package idempotency
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/eng-by-sjb/yellow-pines-e-commerce-backend/internal/eventengine/event"
+)
+
+type testPayload struct{}
+
+func Test_MarkProcessed(t *testing.T) {
+	const subscriberName event.SubscriberName = "test.subscriber"
+
+	db, fdb := newFakeDB(t)
+	processedEvent := event.New(context.Background(), "test", "test.event", &testPayload{})
+	ctx := event.ContextWithEvent(context.Background(), processedEvent)
+
+	// markProcessed marks the event processed in a transaction, which is
+	// committed if commit is true and rolled back otherwise.
+	markProcessed := func(commit bool) error {
+		tx, err := db.BeginTx(ctx, nil)
+		if err != nil {
+			t.Fatalf("expected to begin a transaction, got: %v", err)
+		}
+		defer tx.Rollback()
+
+		if err := MarkProcessed(ctx, tx, subscriberName); err != nil {
+			return err
+		}
+
+		if commit {
+			if err := tx.Commit(); err != nil {
+				t.Fatalf("expected to commit the transaction, got: %v", err)
+			}
+		}
+		return nil
+	}
+
+	// a rolled back record leaves the event to be processed again.
+	if err := markProcessed(false); err != nil {
+		t.Fatalf("expected to mark the event processed, got: %v", err)
+	}
+	if fdb.isProcessed(processedEvent.ID, string(subscriberName)) {
+		t.Fatal("expected the event not to be processed once the transaction was rolled back")
+	}
+
+	if err := markProcessed(true); err != nil {
+		t.Fatalf("expected to mark the event processed, got: %v", err)
+	}
+	if !fdb.isProcessed(processedEvent.ID, string(subscriberName)) {
+		t.Fatal("expected the event to be processed once the transaction was committed")
+	}
+
+	if err := markProcessed(true); !errors.Is(err, ErrAlreadyProcessed) {
+		t.Fatalf("expected error '%v', got: %v", ErrAlreadyProcessed, err)
+	}
+
+	// other subscribers process the event on their own.
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatalf("expected to begin a transaction, got: %v", err)
+	}
+	defer tx.Rollback()
+
+	if err := MarkProcessed(ctx, tx, "test.other_subscriber"); err != nil {
+		t.Fatalf("expected another subscriber to mark the event processed, got: %v", err)
+	}
+
+	// there is nothing to record outside of an event handler.
+	if err := MarkProcessed(context.Background(), tx, subscriberName); err != nil {
+		t.Fatalf("expected no error without an event, got: %v", err)
+	}
+}
@@ -0,0 +1,358 @@
+package eventengine
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/eng-by-sjb/yellow-pines-e-commerce-backend/internal/eventengine/event"
+)
+
+// latencyBuckets are the upper bounds of the buckets of the histograms of
+// how long handlers take, in seconds.
+var latencyBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30}
+
+// Inspector shows what the event engine is doing, for admins and for
+// monitoring.
+type Inspector interface {
+	// Snapshot returns the registered events, their subscribers and their
+	// live stats.
+	Snapshot() *EventsSnapshot
+	// WriteMetrics writes the metrics of the event engine to w in the
+	// Prometheus text format.
+	WriteMetrics(w io.Writer) error
+}
+
+// histogram counts observed durations into latencyBuckets.
+type histogram struct {
+	mu      sync.Mutex
+	buckets []uint64 // observations per bucket, the last one above every upper bound.
+	sum     time.Duration
+	count   uint64
+}
+
+func newHistogram() *histogram {
+	return &histogram{
+		buckets: make([]uint64, len(latencyBuckets)+1),
+	}
+}
+
+func (h *histogram) observe(d time.Duration) {
+	seconds := d.Seconds()
+	bucket := sort.SearchFloat64s(latencyBuckets, seconds)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.buckets[bucket]++
+	h.sum += d
+	h.count++
+}
+
+// HistogramSnapshot is how long the calls of a handler took.
+type HistogramSnapshot struct {
+	Count   uint64            `json:"count"`
+	Sum     float64           `json:"sumSeconds"`
+	Mean    float64           `json:"meanSeconds"`
+	Buckets []HistogramBucket `json:"buckets"`
+}
+
+// HistogramBucket is how many calls took up to UpperBound seconds.
+type HistogramBucket struct {
+	UpperBound float64 `json:"le"`
+	Count      uint64  `json:"count"` // cumulative, like in Prometheus.
+}
+
+func (h *histogram) snapshot() *HistogramSnapshot {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	snapshot := &HistogramSnapshot{
+		Count:   h.count,
+		Sum:     h.sum.Seconds(),
+		Buckets: make([]HistogramBucket, len(latencyBuckets)),
+	}
+	if h.count > 0 {
+		snapshot.Mean = snapshot.Sum / float64(h.count)
+	}
+
+	var cumulative uint64
+	for i, upperBound := range latencyBuckets {
+		cumulative += h.buckets[i]
+		snapshot.Buckets[i] = HistogramBucket{
+			UpperBound: upperBound,
+			Count:      cumulative,
+		}
+	}
+
+	return snapshot
+}
+
+// handlerMetrics counts what a handler did.
+type handlerMetrics struct {
+	handled atomic.Uint64 // events handled successfully.
+	failed  atomic.Uint64 // events dead lettered.
+	latency *histogram    // how long each attempt to handle an event took.
+}
+
+func newHandlerMetrics() *handlerMetrics {
+	return &handlerMetrics{
+		latency: newHistogram(),
+	}
+}
+
+// publishedCounter counts the events published per event name.
+type publishedCounter struct {
+	mu     sync.RWMutex
+	counts map[event.EventName]*atomic.Uint64
+}
+
+func (c *publishedCounter) inc(eventName event.EventName) {
+	c.mu.RLock()
+	count, ok := c.counts[eventName]
+	c.mu.RUnlock()
+
+	if !ok {
+		c.mu.Lock()
+		if c.counts == nil {
+			c.counts = make(map[event.EventName]*atomic.Uint64)
+		}
+		if count, ok = c.counts[eventName]; !ok {
+			count = &atomic.Uint64{}
+			c.counts[eventName] = count
+		}
+		c.mu.Unlock()
+	}
+
+	count.Add(1)
+}
+
+func (c *publishedCounter) get(eventName event.EventName) uint64 {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if count, ok := c.counts[eventName]; ok {
+		return count.Load()
+	}
+	return 0
+}
+
+// EventsSnapshot is what the event engine is doing, see [Inspector].
+type EventsSnapshot struct {
+	Events []*EventSnapshot `json:"events"`
+}
+
+// EventSnapshot is a registered event, or a pattern subscribed to, with its
+// subscribers.
+type EventSnapshot struct {
+	Name        event.EventName       `json:"name"`
+	Pattern     bool                  `json:"pattern"`
+	PayloadType string                `json:"payloadType,omitempty"` // the type the event is bound to by its handlers, if any.
+	Published   uint64                `json:"published"`             // events published by this instance of the server.
+	Subscribers []*SubscriberSnapshot `json:"subscribers"`
+}
+
+// SubscriberSnapshot is the live stats of a subscriber of an event. The
+// fields of handlers are left out for raw subscribers.
+type SubscriberSnapshot struct {
+	SubscriberName event.SubscriberName `json:"subscriberName"`
+	Backpressure   string               `json:"backpressure"`
+	QueueDepth     int                  `json:"queueDepth"`
+	Spilled        int                  `json:"spilled"`
+	Dropped        uint64               `json:"dropped"`
+	Status         HealthStatus         `json:"status,omitempty"`
+	Restarts       uint64               `json:"restarts,omitempty"`
+	Handled        uint64               `json:"handled"`
+	Failed         uint64               `json:"failed"`
+	Latency        *HistogramSnapshot   `json:"latency,omitempty"`
+}
+
+// Snapshot returns every registered event and pattern subscribed to, ordered
+// by name, with their subscribers and live stats.
+func (e *eventEngine) Snapshot() *EventsSnapshot {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	snapshot := &EventsSnapshot{Events: []*EventSnapshot{}}
+
+	for _, subscriptions := range []map[event.EventName]*subscribers{e.events, e.patterns} {
+		for eventName, subscribers := range subscriptions {
+			eventSnapshot := &EventSnapshot{
+				Name:        eventName,
+				Pattern:     IsPattern(eventName),
+				Published:   e.published.get(eventName),
+				Subscribers: make([]*SubscriberSnapshot, 0, len(subscribers.list)),
+			}
+			if payloadType, ok := e.payloadTypes[eventName]; ok {
+				eventSnapshot.PayloadType = payloadType.String()
+			}
+
+			for _, subscriber := range subscribers.list {
+				eventSnapshot.Subscribers = append(
+					eventSnapshot.Subscribers,
+					e.subscriberSnapshot(eventName, subscriber),
+				)
+			}
+
+			sort.Slice(eventSnapshot.Subscribers, func(i, j int) bool {
+				return eventSnapshot.Subscribers[i].SubscriberName < eventSnapshot.Subscribers[j].SubscriberName
+			})
+
+			snapshot.Events = append(snapshot.Events, eventSnapshot)
+		}
+	}
+
+	sort.Slice(snapshot.Events, func(i, j int) bool {
+		return snapshot.Events[i].Name < snapshot.Events[j].Name
+	})
+
+	return snapshot
+}
+
+// subscriberSnapshot returns the live stats of subscriber of eventName. The
+// caller must hold e.mu.
+func (e *eventEngine) subscriberSnapshot(eventName event.EventName, subscriber *subscriber) *SubscriberSnapshot {
+	subscriberSnapshot := &SubscriberSnapshot{
+		SubscriberName: subscriber.name,
+		Backpressure:   subscriber.backpressure.String(),
+		QueueDepth:     len(subscriber.addressCh),
+		Dropped:        subscriber.dropped.Load(),
+	}
+
+	if subscriber.spillQueue != nil {
+		subscriberSnapshot.Spilled = subscriber.spillQueue.len()
+	}
+
+	handler, ok := e.handlers[handlerKey{eventName: eventName, subscriberName: subscriber.name}]
+	if !ok {
+		return subscriberSnapshot
+	}
+
+	handler.supervisor.mu.Lock()
+	subscriberSnapshot.Status = handler.supervisor.status
+	subscriberSnapshot.Restarts = handler.supervisor.restarts
+	handler.supervisor.mu.Unlock()
+
+	subscriberSnapshot.Handled = handler.metrics.handled.Load()
+	subscriberSnapshot.Failed = handler.metrics.failed.Load()
+	subscriberSnapshot.Latency = handler.metrics.latency.snapshot()
+
+	return subscriberSnapshot
+}
+
+// WriteMetrics writes the metrics of the event engine to w in the Prometheus
+// text format.
+func (e *eventEngine) WriteMetrics(w io.Writer) error {
+	snapshot := e.Snapshot()
+	buf := bufio.NewWriter(w)
+
+	writeFamily := func(name, help, metricType string, write func()) {
+		fmt.Fprintf(buf, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, metricType)
+		write()
+	}
+
+	writeFamily(
+		"eventengine_events_published_total",
+		"Events published by this instance, by event name.",
+		"counter",
+		func() {
+			for _, eventSnapshot := range snapshot.Events {
+				if !eventSnapshot.Pattern {
+					fmt.Fprintf(buf, "eventengine_events_published_total{event=%s} %d\n", quoteLabel(string(eventSnapshot.Name)), eventSnapshot.Published)
+				}
+			}
+		},
+	)
+
+	// forEachSubscriberSnapshot writes a sample per subscriber of every event.
+	forEachSubscriberSnapshot := func(write func(labels string, subscriberSnapshot *SubscriberSnapshot)) {
+		for _, eventSnapshot := range snapshot.Events {
+			for _, subscriberSnapshot := range eventSnapshot.Subscribers {
+				labels := fmt.Sprintf(
+					"event=%s,subscriber=%s",
+					quoteLabel(string(eventSnapshot.Name)),
+					quoteLabel(string(subscriberSnapshot.SubscriberName)),
+				)
+				write(labels, subscriberSnapshot)
+			}
+		}
+	}
+
+	subscriberFamilies := []struct {
+		name, help, metricType string
+		value                  func(subscriberSnapshot *SubscriberSnapshot) uint64
+	}{
+		{
+			"eventengine_subscriber_queue_depth", "Events waiting in the addressCh of a subscriber.", "gauge",
+			func(s *SubscriberSnapshot) uint64 { return uint64(s.QueueDepth) },
+		},
+		{
+			"eventengine_subscriber_spilled", "Events waiting in the spill queue of a subscriber.", "gauge",
+			func(s *SubscriberSnapshot) uint64 { return uint64(s.Spilled) },
+		},
+		{
+			"eventengine_subscriber_dropped_total", "Events dropped because the addressCh of a subscriber was full.", "counter",
+			func(s *SubscriberSnapshot) uint64 { return s.Dropped },
+		},
+		{
+			"eventengine_handler_handled_total", "Events a handler handled successfully.", "counter",
+			func(s *SubscriberSnapshot) uint64 { return s.Handled },
+		},
+		{
+			"eventengine_handler_failures_total", "Events a handler failed to handle, which were dead lettered.", "counter",
+			func(s *SubscriberSnapshot) uint64 { return s.Failed },
+		},
+		{
+			"eventengine_handler_restarts_total", "Restarts of a handler after it panicked.", "counter",
+			func(s *SubscriberSnapshot) uint64 { return s.Restarts },
+		},
+	}
+
+	for _, family := range subscriberFamilies {
+		writeFamily(family.name, family.help, family.metricType, func() {
+			forEachSubscriberSnapshot(func(labels string, subscriberSnapshot *SubscriberSnapshot) {
+				fmt.Fprintf(buf, "%s{%s} %d\n", family.name, labels, family.value(subscriberSnapshot))
+			})
+		})
+	}
+
+	writeFamily(
+		"eventengine_handler_duration_seconds",
+		"How long a handler took to handle an event, per attempt.",
+		"histogram",
+		func() {
+			forEachSubscriberSnapshot(func(labels string, subscriberSnapshot *SubscriberSnapshot) {
+				latency := subscriberSnapshot.Latency
+				if latency == nil {
+					return
+				}
+
+				for _, bucket := range latency.Buckets {
+					fmt.Fprintf(
+						buf,
+						"eventengine_handler_duration_seconds_bucket{%s,le=\"%s\"} %d\n",
+						labels,
+						strconv.FormatFloat(bucket.UpperBound, 'g', -1, 64),
+						bucket.Count,
+					)
+				}
+				fmt.Fprintf(buf, "eventengine_handler_duration_seconds_bucket{%s,le=\"+Inf\"} %d\n", labels, latency.Count)
+				fmt.Fprintf(buf, "eventengine_handler_duration_seconds_sum{%s} %s\n", labels, strconv.FormatFloat(latency.Sum, 'g', -1, 64))
+				fmt.Fprintf(buf, "eventengine_handler_duration_seconds_count{%s} %d\n", labels, latency.Count)
+			})
+		},
+	)
+
+	return buf.Flush()
+}
+
+// quoteLabel quotes a label value of the Prometheus text format.
+func quoteLabel(value string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value) + `"`
+}
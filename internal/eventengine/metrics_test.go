@@ -0,0 +1,183 @@
+package eventengine
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/eng-by-sjb/yellow-pines-e-commerce-backend/internal/eventengine/event"
+)
+
+func Test_histogram(t *testing.T) {
+	h := newHistogram()
+	h.observe(time.Millisecond)
+	h.observe(20 * time.Millisecond)
+	h.observe(time.Minute)
+
+	snapshot := h.snapshot()
+	if snapshot.Count != 3 {
+		t.Fatalf("expected 3 observations, got: %d", snapshot.Count)
+	}
+
+	expected := map[float64]uint64{0.005: 1, 0.025: 2, 30: 2}
+	for _, bucket := range snapshot.Buckets {
+		if count, ok := expected[bucket.UpperBound]; ok && bucket.Count != count {
+			t.Fatalf("expected %d observations up to %vs, got: %d", count, bucket.UpperBound, bucket.Count)
+		}
+	}
+}
+
+func Test_Snapshot(t *testing.T) {
+	type testPayload struct {
+		Value int
+	}
+
+	doneCh := make(chan struct{})
+	InternalSrvWG := sync.WaitGroup{}
+
+	engine := NewEventEngine(
+		&EventEngineConfig{
+			DoneCh:        doneCh,
+			InternalSrvWG: &InternalSrvWG,
+		},
+	)
+
+	var eventName event.EventName = "test.event.engine.inspected"
+	engine.RegisterEvents(eventName)
+
+	handledCh := make(chan struct{}, 2)
+	_, err := Subscribe(
+		engine,
+		eventName,
+		&SubscriptionConfig{
+			SubscriberName: "test_subscriber_name",
+			Retry:          &RetryPolicy{MaxAttempts: 1},
+		},
+		func(_ context.Context, payload *testPayload) error {
+			defer func() { handledCh <- struct{}{} }()
+
+			if payload.Value < 0 {
+				return errors.New("negative value")
+			}
+			return nil
+		},
+	)
+	if err != nil {
+		t.Fatalf("expected to subscribe, got: %v", err)
+	}
+
+	for _, value := range []int{1, -1} {
+		if err := engine.Publish(event.New(context.Background(), "test_producer", eventName, &testPayload{Value: value})); err != nil {
+			t.Fatalf("expected to publish, got: %v", err)
+		}
+	}
+
+	for range 2 {
+		select {
+		case <-handledCh:
+		case <-time.After(2 * time.Second):
+			t.Fatal("expected both events to be handled")
+		}
+	}
+
+	close(doneCh)
+	InternalSrvWG.Wait()
+
+	// the published counts outlive the subscribers, which are removed at
+	// shutdown.
+	snapshot := engine.Snapshot()
+	var inspected *EventSnapshot
+	for _, eventSnapshot := range snapshot.Events {
+		if eventSnapshot.Name == eventName {
+			inspected = eventSnapshot
+		}
+	}
+	if inspected == nil || inspected.Published != 2 {
+		t.Fatalf("expected 2 published events of %s, got: %+v", eventName, inspected)
+	}
+
+	var metrics strings.Builder
+	if err := engine.WriteMetrics(&metrics); err != nil {
+		t.Fatalf("expected to write the metrics, got: %v", err)
+	}
+
+	for _, expected := range []string{
+		"# TYPE eventengine_events_published_total counter",
+		`eventengine_events_published_total{event="test.event.engine.inspected"} 2`,
+		"# TYPE eventengine_handler_duration_seconds histogram",
+	} {
+		if !strings.Contains(metrics.String(), expected) {
+			t.Fatalf("expected the metrics to contain '%s', got:\n%s", expected, metrics.String())
+		}
+	}
+}
+
+func Test_subscriberSnapshot(t *testing.T) {
+	type testPayload struct{}
+
+	doneCh := make(chan struct{})
+	InternalSrvWG := sync.WaitGroup{}
+
+	engine := NewEventEngine(
+		&EventEngineConfig{
+			DoneCh:        doneCh,
+			InternalSrvWG: &InternalSrvWG,
+		},
+	)
+	defer func() {
+		close(doneCh)
+		InternalSrvWG.Wait()
+	}()
+
+	var eventName event.EventName = "test.event.engine.subscriber.inspected"
+
+	handledCh := make(chan struct{}, 2)
+	_, err := Subscribe(
+		engine,
+		eventName,
+		&SubscriptionConfig{
+			SubscriberName: "test_subscriber_name",
+			Retry:          &RetryPolicy{MaxAttempts: 1},
+		},
+		func(context.Context, *testPayload) error {
+			handledCh <- struct{}{}
+			return errors.New("test failure")
+		},
+	)
+	if err != nil {
+		t.Fatalf("expected to subscribe, got: %v", err)
+	}
+
+	if err := engine.Publish(event.New(context.Background(), "test_producer", eventName, &testPayload{})); err != nil {
+		t.Fatalf("expected to publish, got: %v", err)
+	}
+
+	select {
+	case <-handledCh:
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected the event to be handled")
+	}
+
+	// the failure is counted once the event was dead lettered.
+	for deadline := time.Now().Add(2 * time.Second); ; time.Sleep(10 * time.Millisecond) {
+		subscribers := engine.Snapshot().Events[0].Subscribers
+		if len(subscribers) != 1 {
+			t.Fatalf("expected 1 subscriber, got: %d", len(subscribers))
+		}
+
+		subscriber := subscribers[0]
+		if subscriber.Failed == 1 {
+			if subscriber.Handled != 0 || subscriber.Status != HealthRunning || subscriber.Latency.Count != 1 {
+				t.Fatalf("expected 1 failed attempt of a running handler, got: %+v", subscriber)
+			}
+			break
+		}
+
+		if time.Now().After(deadline) {
+			t.Fatalf("expected the failure to be counted, got: %+v", subscriber)
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package eventengine
+
+import (
+	"fmt"
+	"log"
+	"sort"
+
+	"github.com/eng-by-sjb/yellow-pines-e-commerce-backend/internal/eventengine/event"
+)
+
+// Module is the part of a feature that talks to the event engine: the events
+// the feature emits, and the handlers of the events it handles, which are
+// usually built with [NewHandler].
+//
+//	eventengine.RunModule(engine, &eventengine.Module{
+//		Name:  subscriberName,
+//		Emits: []event.EventName{event.InventoryCreatedEventName},
+//		Handlers: map[event.EventName]*eventengine.Handler{
+//			event.ProductCreatedEventName: eventengine.NewHandler(
+//				&eventengine.SubscriptionConfig{},
+//				h.productCreatedEventHandler, // func(ctx, *event.ProductCreatedEvent) error
+//			),
+//		},
+//	})
+type Module struct {
+	Name          event.SubscriberName         // The SubscriberName of the handlers that do not set one.
+	Emits         []event.EventName            // The events the feature publishes, which are registered.
+	Handlers      map[event.EventName]*Handler // The handler of every event or pattern the feature subscribes to.
+	AddressChSize uint16                       // The AddressChSize of the handlers that do not set one.
+}
+
+// RunModule registers the events module emits and subscribes its handlers,
+// with SubscribeHandler, so the event engine runs them until it shuts down.
+// If a handler can not be subscribed, the handlers subscribed before are
+// unsubscribed again and the error is returned.
+//
+// The returned [CancelFunc] unsubscribes every handler of module.
+func RunModule(engine SubscribeRegisterPublisher, module *Module) (CancelFunc, error) {
+	if module == nil || module.Name == "" {
+		return nil, fmt.Errorf("either module is nil or its 'Name' is empty")
+	}
+
+	if len(module.Emits) > 0 {
+		engine.RegisterEvents(module.Emits...)
+	}
+
+	// subscribe in a stable order, so a failure does not depend on the order
+	// of the map.
+	eventNames := make([]event.EventName, 0, len(module.Handlers))
+	for eventName := range module.Handlers {
+		eventNames = append(eventNames, eventName)
+	}
+	sort.Slice(eventNames, func(i, j int) bool { return eventNames[i] < eventNames[j] })
+
+	cancels := make([]CancelFunc, 0, len(eventNames))
+	cancelAll := func() {
+		for _, cancel := range cancels {
+			cancel()
+		}
+	}
+
+	for _, eventName := range eventNames {
+		handler := module.Handlers[eventName]
+		if handler == nil || handler.SubscriptionConfig == nil {
+			cancelAll()
+			return nil, fmt.Errorf(
+				"either the handler of module '%s' for event '%s' or its 'SubscriptionConfig' is nil",
+				module.Name,
+				eventName,
+			)
+		}
+
+		if handler.SubscriberName == "" {
+			handler.SubscriberName = module.Name
+		}
+		if handler.AddressChSize == 0 {
+			handler.AddressChSize = module.AddressChSize
+		}
+
+		cancel, err := engine.SubscribeHandler(eventName, handler)
+		if err != nil {
+			cancelAll()
+			return nil, fmt.Errorf(
+				"error in module '%s' subscribing to event '%s': %w",
+				module.Name,
+				eventName,
+				err,
+			)
+		}
+		cancels = append(cancels, cancel)
+	}
+
+	log.Printf("module '%s' is running, handling %v\n", module.Name, eventNames)
+
+	return cancelAll, nil
+}
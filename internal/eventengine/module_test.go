@@ -0,0 +1,95 @@
+package eventengine
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/eng-by-sjb/yellow-pines-e-commerce-backend/internal/eventengine/event"
+)
+
+func Test_RunModule(t *testing.T) {
+	type testPayload struct {
+		Value int
+	}
+
+	doneCh := make(chan struct{})
+	InternalSrvWG := sync.WaitGroup{}
+
+	engine := NewEventEngine(
+		&EventEngineConfig{
+			DoneCh:        doneCh,
+			InternalSrvWG: &InternalSrvWG,
+		},
+	)
+	defer func() {
+		close(doneCh)
+		InternalSrvWG.Wait()
+	}()
+
+	const (
+		emittedEventName event.EventName = "test.module.emitted"
+		handledEventName event.EventName = "test.module.handled"
+	)
+
+	handledCh := make(chan int, 1)
+	cancel, err := RunModule(engine, &Module{
+		Name:  "test_module",
+		Emits: []event.EventName{emittedEventName},
+		Handlers: map[event.EventName]*Handler{
+			handledEventName: NewHandler(
+				&SubscriptionConfig{},
+				func(_ context.Context, payload *testPayload) error {
+					handledCh <- payload.Value
+					return nil
+				},
+			),
+		},
+	})
+	if err != nil {
+		t.Fatalf("expected to run the module, got: %v", err)
+	}
+
+	// the emitted event was registered, so it can be published.
+	if err := engine.Publish(event.New(context.Background(), "test_module", emittedEventName, &testPayload{})); err != nil {
+		t.Fatalf("expected to publish the emitted event, got: %v", err)
+	}
+
+	if err := engine.Publish(event.New(context.Background(), "test_producer", handledEventName, &testPayload{Value: 1})); err != nil {
+		t.Fatalf("expected to publish, got: %v", err)
+	}
+
+	select {
+	case value := <-handledCh:
+		if value != 1 {
+			t.Fatalf("expected 1 to be handled, got: %d", value)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected the handler of the module to be called")
+	}
+
+	// the handlers take the name of the module.
+	if err := engine.Unsubscribe(handledEventName, "test_module"); err != nil {
+		t.Fatalf("expected the handler to be subscribed as the module, got: %v", err)
+	}
+	cancel()
+
+	// a handler that can not be subscribed unsubscribes the ones before it.
+	_, err = RunModule(engine, &Module{
+		Name: "test_failing_module",
+		Handlers: map[event.EventName]*Handler{
+			"test.module.a": NewHandler(&SubscriptionConfig{}, func(context.Context, *testPayload) error { return nil }),
+			"test.module.b": {},
+		},
+	})
+	if err == nil {
+		t.Fatal("expected the module to fail, as a handler has no SubscriptionConfig")
+	}
+
+	err = engine.Unsubscribe("test.module.a", "test_failing_module")
+	if !errors.Is(err, ErrSubscriptionNotFound) {
+		t.Fatalf("expected the handlers of the failing module to be unsubscribed, got: %v", err)
+	}
+}
@@ -0,0 +1,105 @@
+package eventengine
+
+import (
+	"hash/fnv"
+	"sync"
+
+	"github.com/eng-by-sjb/yellow-pines-e-commerce-backend/internal/eventengine/event"
+)
+
+// OrderBy returns an OrderingKey that extracts the key of an event from its
+// payload of type T, e.g. the id of the product it is about. Events whose
+// payload is not a T, e.g. those a pattern subscriber skips, have no key.
+//
+//	OrderingKey: eventengine.OrderBy(func(payload *event.ProductCreatedEvent) string {
+//		return payload.ProductID.String()
+//	}),
+func OrderBy[T any](key func(payload T) string) func(payload any) string {
+	return func(payload any) string {
+		typed, ok := payload.(T)
+		if !ok {
+			return ""
+		}
+
+		return key(typed)
+	}
+}
+
+// shardOf returns the worker of handler that handles newEvent. Events with
+// the same ordering key always go to the same worker, and events without one
+// are spread across the workers by their id.
+func (h *Handler) shardOf(newEvent *event.Event) int {
+	if len(h.shards) == 1 {
+		return 0
+	}
+
+	hash := fnv.New32a()
+
+	var key string
+	if h.OrderingKey != nil {
+		key = h.OrderingKey(newEvent.Payload)
+	}
+
+	if key != "" {
+		hash.Write([]byte(key))
+	} else {
+		hash.Write(newEvent.ID[:])
+	}
+
+	return int(hash.Sum32() % uint32(len(h.shards)))
+}
+
+// runWorkers starts handler.Concurrency workers calling handler for the
+// events of their shard, and hands them the events sent to addressCh until
+// it is closed. Events that skip reports true for are dropped. It returns
+// once the workers handled every event they were handed.
+//
+// A slow event only holds up the events of its own shard, while the other
+// workers keep handling theirs. Workers are restarted after a panic, see
+// supervise, and dead letter the events they are handed once the handler
+// failed, so a failed handler does not hold up the event engine.
+func (e *eventEngine) runWorkers(
+	toEventName event.EventName,
+	handler *Handler,
+	addressCh <-chan *event.Event,
+	skip func(newEvent *event.Event) bool,
+) {
+	var workers sync.WaitGroup
+
+	shardChs := make([]chan *event.Event, len(handler.shards))
+	for i := range shardChs {
+		shardChs[i] = make(chan *event.Event, handler.AddressChSize)
+
+		workers.Add(1)
+		go func(shardCh <-chan *event.Event) {
+			defer workers.Done()
+
+			e.supervise(toEventName, handler, func() {
+				for newEvent := range shardCh {
+					e.handle(e.ctx, toEventName, handler, newEvent)
+				}
+			})
+
+			// shardCh is closed already, unless the handler failed.
+			for newEvent := range shardCh {
+				e.deadLetter(handler, newEvent, 0, ErrSubscriberFailed)
+				e.acks.done(newEvent.ID, handler.subscriber, ErrSubscriberFailed)
+			}
+		}(shardChs[i])
+	}
+
+	for newEvent := range addressCh {
+		if skip(newEvent) {
+			e.acks.done(newEvent.ID, handler.subscriber, nil)
+			continue
+		}
+
+		shardChs[handler.shardOf(newEvent)] <- newEvent
+	}
+
+	for _, shardCh := range shardChs {
+		close(shardCh)
+	}
+
+	workers.Wait()
+}
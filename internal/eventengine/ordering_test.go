@@ -0,0 +1,123 @@
+package eventengine
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/eng-by-sjb/yellow-pines-e-commerce-backend/internal/eventengine/event"
+)
+
+func Test_orderedDelivery(t *testing.T) {
+	type testPayload struct {
+		Key   string
+		Value int
+	}
+
+	const (
+		keyCount    = 4
+		valueCount  = 10
+		concurrency = 4
+	)
+
+	doneCh := make(chan struct{})
+	InternalSrvWG := sync.WaitGroup{}
+
+	engine := NewEventEngine(
+		&EventEngineConfig{
+			DoneCh:        doneCh,
+			InternalSrvWG: &InternalSrvWG,
+		},
+	)
+
+	var eventName event.EventName = "test.event.engine.ordered"
+	engine.RegisterEvents(eventName)
+
+	var (
+		mu         sync.Mutex
+		handled    = make(map[string][]int)
+		running    int
+		maxRunning int
+		handledWG  sync.WaitGroup
+	)
+	handledWG.Add(keyCount * valueCount)
+
+	_, err := Subscribe(
+		engine,
+		eventName,
+		&SubscriptionConfig{
+			SubscriberName: "test_subscriber_name",
+			Concurrency:    concurrency,
+			OrderingKey: OrderBy(func(payload *testPayload) string {
+				return payload.Key
+			}),
+		},
+		func(_ context.Context, payload *testPayload) error {
+			defer handledWG.Done()
+
+			mu.Lock()
+			running++
+			maxRunning = max(maxRunning, running)
+			mu.Unlock()
+
+			time.Sleep(time.Millisecond)
+
+			mu.Lock()
+			running--
+			handled[payload.Key] = append(handled[payload.Key], payload.Value)
+			mu.Unlock()
+
+			return nil
+		},
+	)
+	if err != nil {
+		t.Fatalf("expected to subscribe, got: %v", err)
+	}
+
+	for value := range valueCount {
+		for key := range keyCount {
+			payload := &testPayload{Key: fmt.Sprintf("key-%d", key), Value: value}
+			if err := engine.Publish(event.New(context.Background(), "test_producer", eventName, payload)); err != nil {
+				t.Fatalf("expected to publish, got: %v", err)
+			}
+		}
+	}
+
+	handledWG.Wait()
+	close(doneCh)
+	InternalSrvWG.Wait()
+
+	for key, values := range handled {
+		for i, value := range values {
+			if value != i {
+				t.Fatalf("expected the events of %s to be handled in order, got: %v", key, values)
+			}
+		}
+	}
+
+	if maxRunning < 2 {
+		t.Fatalf("expected events with different keys to be handled in parallel, got at most %d at a time", maxRunning)
+	}
+}
+
+func Test_shardOf(t *testing.T) {
+	handler := &Handler{
+		SubscriptionConfig: &SubscriptionConfig{
+			OrderingKey: OrderBy(func(payload string) string { return payload }),
+		},
+		shards: make([]sync.Mutex, 8),
+	}
+
+	first := handler.shardOf(event.New(context.Background(), "test_producer", "test.event", "same key"))
+	second := handler.shardOf(event.New(context.Background(), "test_producer", "test.event", "same key"))
+	if first != second {
+		t.Fatalf("expected events with the same key to go to the same worker, got: %d and %d", first, second)
+	}
+
+	// a payload that is not a string has no key, and goes to any worker.
+	if shard := handler.shardOf(event.New(context.Background(), "test_producer", "test.event", 1)); shard < 0 || shard >= 8 {
+		t.Fatalf("expected a worker between 0 and 7, got: %d", shard)
+	}
+}
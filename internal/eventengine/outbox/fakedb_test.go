@@ -0,0 +1,255 @@
+package outbox
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// fakeOutboxRow is a row of the outbox table of fakeDB.
+type fakeOutboxRow struct {
+	id             int64
+	eventID        string
+	eventName      string
+	eventVersion   int64
+	occurredAt     time.Time
+	correlationID  string
+	causationID    string
+	producer       string
+	payload        []byte
+	attempts       int64
+	lastError      string
+	deliveredAt    *time.Time
+	deadLetteredAt *time.Time
+}
+
+// fakeDB is an in memory outbox table behind a database/sql driver, which
+// understands the queries of the outbox package only. Writes of a transaction
+// are only kept once it is committed.
+type fakeDB struct {
+	mu     sync.Mutex
+	nextID int64
+	rows   map[int64]*fakeOutboxRow
+}
+
+var fakeDBs sync.Map // dsn -> *fakeDB
+
+func init() {
+	sql.Register("fakeoutbox", fakeDriver{})
+}
+
+// newFakeDB returns a database with an empty outbox table.
+func newFakeDB(t *testing.T) (*sql.DB, *fakeDB) {
+	t.Helper()
+
+	fdb := &fakeDB{rows: make(map[int64]*fakeOutboxRow)}
+	dsn := uuid.NewString()
+	fakeDBs.Store(dsn, fdb)
+
+	db, err := sql.Open("fakeoutbox", dsn)
+	if err != nil {
+		t.Fatalf("expected to open the fake database, got: %v", err)
+	}
+	t.Cleanup(func() {
+		db.Close()
+		fakeDBs.Delete(dsn)
+	})
+
+	return db, fdb
+}
+
+// row returns a copy of the row with id.
+func (db *fakeDB) row(id int64) fakeOutboxRow {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	return *db.rows[id]
+}
+
+func (db *fakeDB) snapshot() map[int64]*fakeOutboxRow {
+	rows := make(map[int64]*fakeOutboxRow, len(db.rows))
+	for id, row := range db.rows {
+		copied := *row
+		rows[id] = &copied
+	}
+
+	return rows
+}
+
+type fakeDriver struct{}
+
+func (fakeDriver) Open(dsn string) (driver.Conn, error) {
+	fdb, ok := fakeDBs.Load(dsn)
+	if !ok {
+		return nil, fmt.Errorf("no fake database '%s'", dsn)
+	}
+
+	return &fakeConn{db: fdb.(*fakeDB)}, nil
+}
+
+type fakeConn struct {
+	db *fakeDB
+	tx *fakeTx
+}
+
+func (c *fakeConn) Prepare(query string) (driver.Stmt, error) {
+	return nil, errors.New("fake database does not prepare statements")
+}
+
+func (c *fakeConn) Close() error { return nil }
+
+func (c *fakeConn) Begin() (driver.Tx, error) {
+	return c.BeginTx(context.Background(), driver.TxOptions{})
+}
+
+func (c *fakeConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	c.db.mu.Lock()
+	defer c.db.mu.Unlock()
+
+	c.tx = &fakeTx{conn: c, rows: c.db.snapshot(), nextID: c.db.nextID}
+	return c.tx, nil
+}
+
+func (c *fakeConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	_, err := c.run(query, args)
+	return driver.RowsAffected(1), err
+}
+
+func (c *fakeConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	return c.run(query, args)
+}
+
+// run runs query in the transaction of c, or else on the database itself.
+func (c *fakeConn) run(query string, args []driver.NamedValue) (*fakeRows, error) {
+	c.db.mu.Lock()
+	defer c.db.mu.Unlock()
+
+	rows, nextID := c.db.rows, &c.db.nextID
+	if c.tx != nil {
+		rows, nextID = c.tx.rows, &c.tx.nextID
+	}
+
+	query = strings.Join(strings.Fields(query), " ")
+	switch {
+	case strings.HasPrefix(query, "INSERT INTO outbox("):
+		*nextID++
+		rows[*nextID] = &fakeOutboxRow{
+			id:            *nextID,
+			eventID:       args[0].Value.(string),
+			eventName:     args[1].Value.(string),
+			eventVersion:  args[2].Value.(int64),
+			occurredAt:    args[3].Value.(time.Time),
+			correlationID: args[4].Value.(string),
+			causationID:   args[5].Value.(string),
+			producer:      args[6].Value.(string),
+			payload:       args[7].Value.([]byte),
+		}
+		return &fakeRows{}, nil
+
+	case strings.HasPrefix(query, "SELECT id, event_id"):
+		limit, maxAttempts := args[0].Value.(int64), args[1].Value.(int64)
+
+		ids := make([]int64, 0, len(rows))
+		for id, row := range rows {
+			if row.deliveredAt == nil && row.deadLetteredAt == nil && row.attempts < maxAttempts {
+				ids = append(ids, id)
+			}
+		}
+		sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+		if int64(len(ids)) > limit {
+			ids = ids[:limit]
+		}
+
+		result := &fakeRows{
+			columns: []string{"id", "event_id", "event_name", "event_version", "occurred_at", "correlation_id", "causation_id", "producer", "payload"},
+		}
+		for _, id := range ids {
+			row := rows[id]
+			result.values = append(result.values, []driver.Value{
+				row.id, row.eventID, row.eventName, row.eventVersion, row.occurredAt,
+				row.correlationID, row.causationID, row.producer, row.payload,
+			})
+		}
+		return result, nil
+
+	case strings.HasPrefix(query, "UPDATE outbox SET delivered_at"):
+		now := time.Now()
+		for _, id := range strings.Split(strings.Trim(args[0].Value.(string), "{}"), ",") {
+			id, _ := strconv.ParseInt(id, 10, 64)
+			if row, ok := rows[id]; ok {
+				row.deliveredAt = &now
+			}
+		}
+		return &fakeRows{}, nil
+
+	case strings.HasPrefix(query, "UPDATE outbox SET attempts"):
+		row, ok := rows[args[0].Value.(int64)]
+		if !ok {
+			return &fakeRows{columns: []string{"dead_lettered"}}, nil
+		}
+
+		row.attempts++
+		row.lastError = args[1].Value.(string)
+		if row.attempts >= args[2].Value.(int64) {
+			now := time.Now()
+			row.deadLetteredAt = &now
+		}
+		return &fakeRows{
+			columns: []string{"dead_lettered"},
+			values:  [][]driver.Value{{row.deadLetteredAt != nil}},
+		}, nil
+
+	default:
+		return nil, fmt.Errorf("fake database does not understand query: %s", query)
+	}
+}
+
+type fakeTx struct {
+	conn   *fakeConn
+	rows   map[int64]*fakeOutboxRow
+	nextID int64
+}
+
+func (tx *fakeTx) Commit() error {
+	tx.conn.db.mu.Lock()
+	defer tx.conn.db.mu.Unlock()
+
+	tx.conn.db.rows, tx.conn.db.nextID = tx.rows, tx.nextID
+	tx.conn.tx = nil
+	return nil
+}
+
+func (tx *fakeTx) Rollback() error {
+	tx.conn.tx = nil
+	return nil
+}
+
+type fakeRows struct {
+	columns []string
+	values  [][]driver.Value
+}
+
+func (r *fakeRows) Columns() []string { return r.columns }
+
+func (r *fakeRows) Close() error { return nil }
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if len(r.values) == 0 {
+		return io.EOF
+	}
+
+	copy(dest, r.values[0])
+	r.values = r.values[1:]
+	return nil
+}
@@ -0,0 +1,56 @@
+package outbox
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/eng-by-sjb/yellow-pines-e-commerce-backend/internal/eventengine/event"
+)
+
+// Insert writes newEvent into the outbox table using tx, so the event is
+// committed or rolled back together with the caller's own writes. The relay
+// picks it up and publishes it to the event engine once tx is committed, with
+// the same envelope, so redeliveries of it keep its id.
+//
+// newEvent should be created with [event.New], as its envelope is stored as is.
+func Insert(ctx context.Context, tx *sql.Tx, newEvent *event.Event) error {
+	if tx == nil || newEvent == nil {
+		return errors.New(
+			"either tx or event is nil when inserting into the outbox",
+		)
+	}
+
+	payload, err := json.Marshal(newEvent.Payload)
+	if err != nil {
+		return fmt.Errorf(
+			"failed to marshal payload of event '%s' for the outbox: %w",
+			newEvent.Name,
+			err,
+		)
+	}
+
+	_, err = tx.ExecContext(
+		ctx,
+		`INSERT INTO outbox(event_id, event_name, event_version, occurred_at, correlation_id, causation_id, producer, payload) VALUES($1, $2, $3, $4, $5, $6, $7, $8)`,
+		newEvent.ID,
+		newEvent.Name,
+		newEvent.Version,
+		newEvent.OccurredAt,
+		newEvent.CorrelationID,
+		newEvent.CausationID,
+		newEvent.Producer,
+		payload,
+	)
+	if err != nil {
+		return fmt.Errorf(
+			"failed to insert event '%s' into the outbox: %w",
+			newEvent.Name,
+			err,
+		)
+	}
+
+	return nil
+}
@@ -0,0 +1,309 @@
+package outbox
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/eng-by-sjb/yellow-pines-e-commerce-backend/internal/eventengine"
+	"github.com/eng-by-sjb/yellow-pines-e-commerce-backend/internal/eventengine/event"
+)
+
+type testPayload struct {
+	Value int `json:"value"`
+}
+
+const testEventName event.EventName = "test.outbox"
+
+// fakePublisher records the events it publishes and waits for, and fails to
+// publish the ones fail returns an error for. Publishing without waiting for
+// the handlers fails, as the relay must not do so.
+type fakePublisher struct {
+	mu        sync.Mutex
+	published []*event.Event
+	fail      func(ev *event.Event) error
+}
+
+var errNotWaited = errors.New("fake publisher only publishes and waits")
+
+func (p *fakePublisher) Publish(ev *event.Event) error {
+	return errNotWaited
+}
+
+func (p *fakePublisher) PublishContext(ctx context.Context, ev *event.Event) error {
+	return errNotWaited
+}
+
+func (p *fakePublisher) PublishBatch(ctx context.Context, evs ...*event.Event) error {
+	return errNotWaited
+}
+
+func (p *fakePublisher) PublishAndWait(ctx context.Context, ev *event.Event) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.fail != nil {
+		if err := p.fail(ev); err != nil {
+			return err
+		}
+	}
+
+	p.published = append(p.published, ev)
+	return nil
+}
+
+// values returns the payload values of the published events, in order.
+func (p *fakePublisher) values() []int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	values := make([]int, 0, len(p.published))
+	for _, ev := range p.published {
+		values = append(values, ev.Payload.(*testPayload).Value)
+	}
+	return values
+}
+
+// insert inserts events into the outbox of db in a committed transaction.
+func insert(t *testing.T, db *sql.DB, events ...*event.Event) {
+	t.Helper()
+
+	tx, err := db.BeginTx(context.Background(), nil)
+	if err != nil {
+		t.Fatalf("expected to begin a transaction, got: %v", err)
+	}
+	defer tx.Rollback()
+
+	for _, newEvent := range events {
+		if err := Insert(context.Background(), tx, newEvent); err != nil {
+			t.Fatalf("expected to insert into the outbox, got: %v", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("expected to commit, got: %v", err)
+	}
+}
+
+func newTestEvent(name event.EventName, value int) *event.Event {
+	return event.New(context.Background(), "test_producer", name, &testPayload{Value: value})
+}
+
+func newTestRelay(db *sql.DB, publisher *fakePublisher, maxAttempts uint16) *relay {
+	return &relay{
+		RelayConfig: &RelayConfig{
+			DoneCh:    make(chan struct{}),
+			DB:        db,
+			Publisher: publisher,
+			PayloadTypes: map[event.EventName]PayloadFactory{
+				testEventName: func() any { return new(testPayload) },
+			},
+			BatchSize:   50,
+			MaxAttempts: maxAttempts,
+		},
+	}
+}
+
+func equalValues(got, want []int) bool {
+	if len(got) != len(want) {
+		return false
+	}
+	for i := range got {
+		if got[i] != want[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func Test_Insert(t *testing.T) {
+	db, fdb := newFakeDB(t)
+
+	tx, err := db.BeginTx(context.Background(), nil)
+	if err != nil {
+		t.Fatalf("expected to begin a transaction, got: %v", err)
+	}
+	if err := Insert(context.Background(), tx, nil); err == nil {
+		t.Fatal("expected inserting a nil event to fail")
+	}
+	if err := Insert(context.Background(), nil, newTestEvent(testEventName, 1)); err == nil {
+		t.Fatal("expected inserting without a transaction to fail")
+	}
+	tx.Rollback()
+
+	newEvent := newTestEvent(testEventName, 1)
+	newEvent.Version = 3
+	insert(t, db, newEvent)
+
+	row := fdb.row(1)
+	if row.eventID != newEvent.ID.String() ||
+		row.eventName != string(testEventName) ||
+		row.eventVersion != 3 ||
+		row.producer != "test_producer" ||
+		string(row.payload) != `{"value":1}` {
+		t.Fatalf("expected the envelope and payload of the event to be stored, got: %+v", row)
+	}
+
+	// the relay publishes the event with the envelope it was inserted with.
+	publisher := &fakePublisher{}
+	if err := newTestRelay(db, publisher, 10).relayBatch(); err != nil {
+		t.Fatalf("expected to relay, got: %v", err)
+	}
+	if len(publisher.published) != 1 || publisher.published[0].ID != newEvent.ID || publisher.published[0].Version != 3 {
+		t.Fatalf("expected the inserted event to be published with its id, got: %+v", publisher.published)
+	}
+	if fdb.row(1).deliveredAt == nil {
+		t.Fatal("expected the published row to be marked as delivered")
+	}
+}
+
+func Test_Insert_rolledBack(t *testing.T) {
+	db, _ := newFakeDB(t)
+
+	tx, err := db.BeginTx(context.Background(), nil)
+	if err != nil {
+		t.Fatalf("expected to begin a transaction, got: %v", err)
+	}
+	if err := Insert(context.Background(), tx, newTestEvent(testEventName, 1)); err != nil {
+		t.Fatalf("expected to insert into the outbox, got: %v", err)
+	}
+	tx.Rollback()
+
+	publisher := &fakePublisher{}
+	if err := newTestRelay(db, publisher, 10).relayBatch(); err != nil {
+		t.Fatalf("expected to relay, got: %v", err)
+	}
+	if len(publisher.published) != 0 {
+		t.Fatalf("expected the event of a rolled back transaction not to be published, got: %+v", publisher.published)
+	}
+}
+
+func Test_relayBatch_order(t *testing.T) {
+	db, _ := newFakeDB(t)
+	insert(t, db, newTestEvent(testEventName, 1), newTestEvent(testEventName, 2))
+	insert(t, db, newTestEvent(testEventName, 3))
+
+	publisher := &fakePublisher{}
+	r := newTestRelay(db, publisher, 10)
+	if err := r.relayBatch(); err != nil {
+		t.Fatalf("expected to relay, got: %v", err)
+	}
+	if err := r.relayBatch(); err != nil {
+		t.Fatalf("expected to relay, got: %v", err)
+	}
+
+	if got := publisher.values(); !equalValues(got, []int{1, 2, 3}) {
+		t.Fatalf("expected the events to be published once in insertion order, got: %v", got)
+	}
+}
+
+func Test_relayBatch_decodeFailure(t *testing.T) {
+	db, fdb := newFakeDB(t)
+	insert(t, db, newTestEvent("test.outbox.unknown", 1), newTestEvent(testEventName, 2))
+
+	publisher := &fakePublisher{}
+	r := newTestRelay(db, publisher, 2)
+
+	if err := r.relayBatch(); err != nil {
+		t.Fatalf("expected to relay, got: %v", err)
+	}
+
+	failed := fdb.row(1)
+	if failed.attempts != 1 || !strings.Contains(failed.lastError, "no payload type") || failed.deliveredAt != nil || failed.deadLetteredAt != nil {
+		t.Fatalf("expected the undecodable row to be counted as failed, got: %+v", failed)
+	}
+
+	// the rows after the undecodable one wait for it, to keep their order.
+	if got := publisher.values(); len(got) != 0 {
+		t.Fatalf("expected no row after the undecodable one to be published, got: %v", got)
+	}
+
+	if err := r.relayBatch(); err != nil {
+		t.Fatalf("expected to relay, got: %v", err)
+	}
+
+	if failed := fdb.row(1); failed.attempts != 2 || failed.deadLetteredAt == nil {
+		t.Fatalf("expected the undecodable row to be dead lettered after 2 attempts, got: %+v", failed)
+	}
+
+	if got := publisher.values(); !equalValues(got, []int{2}) {
+		t.Fatalf("expected the rows after the dead lettered one to be published, got: %v", got)
+	}
+}
+
+func Test_relayBatch_publishFailure(t *testing.T) {
+	db, fdb := newFakeDB(t)
+	failing := newTestEvent(testEventName, 1)
+	insert(t, db, failing, newTestEvent(testEventName, 2))
+
+	publishErr := errors.New("test publish failed")
+	publisher := &fakePublisher{
+		fail: func(ev *event.Event) error {
+			if ev.ID == failing.ID {
+				return publishErr
+			}
+			return nil
+		},
+	}
+
+	const maxAttempts = 3
+	r := newTestRelay(db, publisher, maxAttempts)
+
+	for attempt := int64(1); attempt < maxAttempts; attempt++ {
+		if err := r.relayBatch(); err != nil {
+			t.Fatalf("expected to relay, got: %v", err)
+		}
+
+		row := fdb.row(1)
+		if row.attempts != attempt || row.lastError != publishErr.Error() || row.deadLetteredAt != nil {
+			t.Fatalf("expected attempt %d to be recorded, got: %+v", attempt, row)
+		}
+
+		// the rows after the failing one wait for it, to keep their order.
+		if got := publisher.values(); len(got) != 0 {
+			t.Fatalf("expected no row after the failing one to be published, got: %v", got)
+		}
+	}
+
+	if err := r.relayBatch(); err != nil {
+		t.Fatalf("expected to relay, got: %v", err)
+	}
+
+	row := fdb.row(1)
+	if row.attempts != maxAttempts || row.deadLetteredAt == nil || row.deliveredAt != nil {
+		t.Fatalf("expected the row to be dead lettered after %d attempts, got: %+v", maxAttempts, row)
+	}
+
+	if got := publisher.values(); !equalValues(got, []int{2}) {
+		t.Fatalf("expected the rows after the dead lettered one to be published, got: %v", got)
+	}
+
+	if err := r.relayBatch(); err != nil {
+		t.Fatalf("expected to relay, got: %v", err)
+	}
+	if got := publisher.values(); !equalValues(got, []int{2}) {
+		t.Fatalf("expected the dead lettered row not to be picked up again, got: %v", got)
+	}
+}
+
+func Test_relayBatch_publishFailureOnShutdown(t *testing.T) {
+	db, fdb := newFakeDB(t)
+	insert(t, db, newTestEvent(testEventName, 1))
+
+	publisher := &fakePublisher{
+		fail: func(*event.Event) error {
+			return eventengine.ErrEngineClosed
+		},
+	}
+
+	if err := newTestRelay(db, publisher, 10).relayBatch(); err != nil {
+		t.Fatalf("expected to relay, got: %v", err)
+	}
+
+	if row := fdb.row(1); row.attempts != 0 || row.deliveredAt != nil {
+		t.Fatalf("expected a closed event engine not to count as an attempt, got: %+v", row)
+	}
+}
@@ -0,0 +1,324 @@
+package outbox
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/eng-by-sjb/yellow-pines-e-commerce-backend/internal/eventengine"
+	"github.com/eng-by-sjb/yellow-pines-e-commerce-backend/internal/eventengine/event"
+	"github.com/lib/pq"
+)
+
+// PayloadFactory returns a pointer to a new zero value of the payload type an
+// event is published with, e.g. new(event.ProductCreatedEvent). The relay
+// unmarshals the stored payload into it before publishing.
+type PayloadFactory func() any
+
+type RelayConfig struct {
+	DoneCh        <-chan struct{}
+	InternalSrvWG *sync.WaitGroup
+	DB            *sql.DB
+	Publisher     eventengine.Publisher
+	PayloadTypes  map[event.EventName]PayloadFactory
+	Schemas       *event.SchemaRegistry // Upcasts and decodes the stored payloads of the events it knows, before PayloadTypes is looked at.
+	PollInterval  time.Duration
+	BatchSize     uint16
+	MaxAttempts   uint16 // rows that failed this many times are dead lettered and no longer picked up.
+}
+
+type relay struct {
+	*RelayConfig
+}
+
+type outboxRow struct {
+	id      int64
+	event   event.Event // envelope of the stored event, without its payload.
+	payload []byte
+}
+
+// NewRelay starts a go routine that polls the outbox table for events that
+// have not been delivered yet and publishes them, in insertion order, to the
+// event engine.
+//
+// Delivery is at-least-once: a row is only marked as delivered once every
+// subscriber handled its event, so if the process dies before, the event is
+// published again after a restart.
+func NewRelay(cfg *RelayConfig) *relay {
+	if cfg == nil {
+		log.Fatalln("'RelayConfig' can not be nil")
+	}
+
+	if cfg.DoneCh == nil || cfg.InternalSrvWG == nil || cfg.DB == nil || cfg.Publisher == nil {
+		log.Fatalln("either 'DoneCh', 'InternalSrvWG', 'DB' or 'Publisher' is nil in outbox relay")
+	}
+
+	if cfg.PollInterval == 0 {
+		cfg.PollInterval = 500 * time.Millisecond
+	}
+
+	if cfg.BatchSize == 0 {
+		cfg.BatchSize = 50
+	}
+
+	if cfg.MaxAttempts == 0 {
+		cfg.MaxAttempts = 10
+	}
+
+	r := &relay{
+		RelayConfig: cfg,
+	}
+
+	r.InternalSrvWG.Add(1)
+	go r.listen()
+
+	return r
+}
+
+func (r *relay) listen() {
+	defer r.InternalSrvWG.Done()
+
+	log.Println("outbox relay is listening...")
+
+	ticker := time.NewTicker(r.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-r.DoneCh:
+			log.Println("outbox relay is shutting down")
+			return
+
+		case <-ticker.C:
+			if err := r.relayBatch(); err != nil {
+				log.Println(err)
+			}
+		}
+	}
+}
+
+// relayBatch publishes one batch of undelivered events. Rows are locked with
+// SKIP LOCKED so several server instances can run a relay against the same
+// table without publishing the same row at the same time.
+func (r *relay) relayBatch() error {
+	ctx, cancel := context.WithTimeout(
+		context.Background(),
+		(30 * time.Second),
+	)
+	defer cancel()
+
+	tx, err := r.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf(
+			"failed to begin outbox relay transaction: %w",
+			err,
+		)
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(
+		ctx,
+		`SELECT id, event_id, event_name, event_version, occurred_at, correlation_id, causation_id, producer, payload FROM outbox
+		WHERE delivered_at IS NULL AND dead_lettered_at IS NULL AND attempts < $2
+		ORDER BY id
+		LIMIT $1
+		FOR UPDATE SKIP LOCKED`,
+		r.BatchSize,
+		r.MaxAttempts,
+	)
+	if err != nil {
+		return fmt.Errorf(
+			"failed to query undelivered events from outbox: %w",
+			err,
+		)
+	}
+
+	var batch []*outboxRow
+	for rows.Next() {
+		row := new(outboxRow)
+		err := rows.Scan(
+			&row.id,
+			&row.event.ID,
+			&row.event.Name,
+			&row.event.Version,
+			&row.event.OccurredAt,
+			&row.event.CorrelationID,
+			&row.event.CausationID,
+			&row.event.Producer,
+			&row.payload,
+		)
+		if err != nil {
+			rows.Close()
+			return fmt.Errorf(
+				"failed to scan event from outbox: %w",
+				err,
+			)
+		}
+		batch = append(batch, row)
+	}
+	rows.Close()
+
+	if len(batch) == 0 {
+		return nil
+	}
+
+	deliveredIDs := make([]int64, 0, len(batch))
+	for _, row := range batch {
+		select {
+		case <-r.DoneCh:
+			// stop publishing once shutdown has started. the rows that were not
+			// published stay undelivered and are picked up after a restart.
+			return r.markDelivered(ctx, tx, deliveredIDs)
+		default:
+		}
+
+		payload, err := r.decodePayload(row)
+		if err != nil {
+			deadLettered, err := r.markFailed(ctx, tx, row.id, err)
+			if err != nil {
+				return err
+			}
+
+			// like a row that failed to publish, see below.
+			if !deadLettered {
+				break
+			}
+			continue
+		}
+
+		newEvent := row.event
+		newEvent.Payload = payload
+
+		// the row is only delivered once the handlers of its event ran, as the
+		// event engine does not keep the events it was handed.
+		err = r.Publisher.PublishAndWait(ctx, &newEvent)
+		if err != nil {
+			log.Printf(
+				"outbox relay failed to publish event %s (outbox id %d): %v\n",
+				&newEvent,
+				row.id,
+				err,
+			)
+
+			// the event engine shutting down or the batch timing out is no
+			// failure of the row.
+			if errors.Is(err, eventengine.ErrEngineClosed) || ctx.Err() != nil {
+				break
+			}
+
+			deadLettered, err := r.markFailed(ctx, tx, row.id, err)
+			if err != nil {
+				return err
+			}
+
+			// keep the order of events by not publishing any later row of
+			// this batch, unless the row was given up on. they will be
+			// retried on the next tick.
+			if !deadLettered {
+				break
+			}
+			continue
+		}
+
+		deliveredIDs = append(deliveredIDs, row.id)
+	}
+
+	return r.markDelivered(ctx, tx, deliveredIDs)
+}
+
+// decodePayload decodes the stored payload of row with the Schemas of the
+// relay, which upcasts the payload of an older version to the current one,
+// or else into the payload type PayloadTypes makes for its event.
+func (r *relay) decodePayload(row *outboxRow) (any, error) {
+	if r.Schemas != nil {
+		if _, _, ok := r.Schemas.Current(row.event.Name); ok {
+			payload, version, err := r.Schemas.Decode(row.event.Name, row.event.Version, row.payload)
+			if err != nil {
+				return nil, err
+			}
+
+			row.event.Version = version
+			return payload, nil
+		}
+	}
+
+	newPayload, ok := r.PayloadTypes[row.event.Name]
+	if !ok {
+		return nil, fmt.Errorf(
+			"no payload type registered in outbox relay for event '%s'",
+			row.event.Name,
+		)
+	}
+
+	payload := newPayload()
+	if err := json.Unmarshal(row.payload, payload); err != nil {
+		return nil, fmt.Errorf(
+			"failed to unmarshal payload of event '%s': %w",
+			row.event.Name,
+			err,
+		)
+	}
+
+	return payload, nil
+}
+
+func (r *relay) markDelivered(ctx context.Context, tx *sql.Tx, ids []int64) error {
+	if len(ids) > 0 {
+		_, err := tx.ExecContext(
+			ctx,
+			`UPDATE outbox SET delivered_at = NOW() WHERE id = ANY($1)`,
+			pq.Array(ids),
+		)
+		if err != nil {
+			return fmt.Errorf(
+				"failed to mark outbox events as delivered: %w",
+				err,
+			)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf(
+			"failed to commit outbox relay transaction: %w",
+			err,
+		)
+	}
+
+	return nil
+}
+
+// markFailed records failure as the last error of the row with id and counts
+// the attempt. Once the row failed MaxAttempts times it is dead lettered,
+// which markFailed reports.
+func (r *relay) markFailed(ctx context.Context, tx *sql.Tx, id int64, failure error) (bool, error) {
+	var deadLettered bool
+	err := tx.QueryRowContext(
+		ctx,
+		`UPDATE outbox SET attempts = attempts + 1, last_error = $2,
+		dead_lettered_at = CASE WHEN attempts + 1 >= $3 THEN NOW() END
+		WHERE id = $1
+		RETURNING dead_lettered_at IS NOT NULL`,
+		id,
+		failure.Error(),
+		r.MaxAttempts,
+	).Scan(&deadLettered)
+	if err != nil {
+		return false, fmt.Errorf(
+			"failed to record outbox relay failure: %w",
+			err,
+		)
+	}
+
+	if deadLettered {
+		log.Printf("outbox relay dead lettered outbox id %d after %d attempts: %v\n", id, r.MaxAttempts, failure)
+	} else {
+		log.Printf("outbox relay failed outbox id %d: %v\n", id, failure)
+	}
+
+	return deadLettered, nil
+}
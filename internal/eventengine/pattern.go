@@ -0,0 +1,88 @@
+package eventengine
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/eng-by-sjb/yellow-pines-e-commerce-backend/internal/eventengine/event"
+)
+
+const (
+	// singleLevelWildcard matches exactly one token of a dotted event name,
+	// e.g. "product.*" matches "product.created" but not "product.updated.quantity".
+	singleLevelWildcard = "*"
+
+	// multiLevelWildcard matches one or more trailing tokens of a dotted event
+	// name, e.g. "product.>" matches "product.created" and "product.updated.quantity".
+	// It can only be the last token of a pattern.
+	multiLevelWildcard = ">"
+)
+
+// IsPattern reports whether name contains a wildcard token and so subscribes
+// to a family of events rather than a single one.
+func IsPattern(name event.EventName) bool {
+	for _, token := range strings.Split(string(name), ".") {
+		if token == singleLevelWildcard || token == multiLevelWildcard {
+			return true
+		}
+	}
+
+	return false
+}
+
+// validatePattern makes sure pattern has no empty tokens and that
+// multiLevelWildcard is only used as its last token.
+func validatePattern(pattern event.EventName) error {
+	tokens := strings.Split(string(pattern), ".")
+
+	for i, token := range tokens {
+		if token == "" {
+			return fmt.Errorf(
+				"event pattern '%v' has an empty token",
+				pattern,
+			)
+		}
+
+		if token == multiLevelWildcard && i != len(tokens)-1 {
+			return fmt.Errorf(
+				"event pattern '%v' can only have '%s' as its last token",
+				pattern,
+				multiLevelWildcard,
+			)
+		}
+	}
+
+	return nil
+}
+
+// matchEventName reports whether the dotted event name matches pattern.
+func matchEventName(pattern, name event.EventName) bool {
+	patternTokens := strings.Split(string(pattern), ".")
+	nameTokens := strings.Split(string(name), ".")
+
+	for i, patternToken := range patternTokens {
+		if patternToken == multiLevelWildcard {
+			return len(nameTokens) > i
+		}
+
+		if i >= len(nameTokens) {
+			return false
+		}
+
+		if patternToken != singleLevelWildcard && patternToken != nameTokens[i] {
+			return false
+		}
+	}
+
+	return len(patternTokens) == len(nameTokens)
+}
+
+// SubscribedTo reports whether a subscription to toEventName, which can be a
+// pattern, receives eventName.
+func SubscribedTo(toEventName event.EventName, eventName event.EventName) bool {
+	if IsPattern(toEventName) {
+		return matchEventName(toEventName, eventName)
+	}
+
+	return toEventName == eventName
+}
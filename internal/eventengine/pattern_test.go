@@ -0,0 +1,49 @@
+package eventengine
+
+import (
+	"testing"
+
+	"github.com/eng-by-sjb/yellow-pines-e-commerce-backend/internal/eventengine/event"
+)
+
+func Test_matchEventName(t *testing.T) {
+	testCases := []struct {
+		pattern  event.EventName
+		name     event.EventName
+		expected bool
+	}{
+		{pattern: "product.created", name: "product.created", expected: true},
+		{pattern: "product.created", name: "product.deleted", expected: false},
+		{pattern: "product.*", name: "product.created", expected: true},
+		{pattern: "product.*", name: "product.updated.quantity", expected: false},
+		{pattern: "product.*", name: "product", expected: false},
+		{pattern: "*.created", name: "product.created", expected: true},
+		{pattern: "*.created", name: "inventory.creation.failed", expected: false},
+		{pattern: "product.>", name: "product.created", expected: true},
+		{pattern: "product.>", name: "product.updated.quantity", expected: true},
+		{pattern: "product.>", name: "product", expected: false},
+		{pattern: "product.>", name: "inventory.creation.failed", expected: false},
+		{pattern: ">", name: "inventory.creation.failed", expected: true},
+		{pattern: "*.updated.>", name: "product.updated.quantity", expected: true},
+	}
+
+	for _, tc := range testCases {
+		if got := matchEventName(tc.pattern, tc.name); got != tc.expected {
+			t.Errorf("matchEventName(%q, %q) = %v, expected %v", tc.pattern, tc.name, got, tc.expected)
+		}
+	}
+}
+
+func Test_validatePattern(t *testing.T) {
+	for _, pattern := range []event.EventName{"product.>.created", "product..*", ""} {
+		if err := validatePattern(pattern); err == nil {
+			t.Errorf("expected pattern %q to be invalid", pattern)
+		}
+	}
+
+	for _, pattern := range []event.EventName{"product.*", "product.>", "*.created"} {
+		if err := validatePattern(pattern); err != nil {
+			t.Errorf("expected pattern %q to be valid, got: %v", pattern, err)
+		}
+	}
+}
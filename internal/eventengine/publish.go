@@ -0,0 +1,268 @@
+package eventengine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/eng-by-sjb/yellow-pines-e-commerce-backend/internal/eventengine/event"
+	"github.com/google/uuid"
+)
+
+// ErrEngineClosed is returned when publishing once the event engine started
+// shutting down.
+var ErrEngineClosed = errors.New("event engine is closed")
+
+// ErrEventDropped is what PublishAndWait reports for a subscriber whose
+// addressCh was full, so it dropped the event, see event.BackpressurePolicy.
+var ErrEventDropped = errors.New("event dropped by a full subscriber")
+
+// DefaultPublishTimeout is how long Publish waits for the event engine to
+// accept an event, see PublishContext.
+const DefaultPublishTimeout = 10 * time.Second
+
+// publishGate lets publishes in until the event engine starts shutting down,
+// and lets the event engine wait for the publishes in flight, so nothing is
+// sent to a closed transport.
+type publishGate struct {
+	mu       sync.Mutex
+	closed   bool
+	inFlight sync.WaitGroup
+}
+
+// enter reports false once the gate is closed. Otherwise, leave must be
+// called once the publish finished.
+func (g *publishGate) enter() bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.closed {
+		return false
+	}
+
+	g.inFlight.Add(1)
+	return true
+}
+
+func (g *publishGate) leave() {
+	g.inFlight.Done()
+}
+
+// close stops letting publishes in, and returns a channel that is closed once
+// the publishes in flight finished.
+func (g *publishGate) close() <-chan struct{} {
+	g.mu.Lock()
+	g.closed = true
+	g.mu.Unlock()
+
+	doneCh := make(chan struct{})
+	go func() {
+		g.inFlight.Wait()
+		close(doneCh)
+	}()
+
+	return doneCh
+}
+
+// PublishContext is Publish, except that it gives up once ctx is done, e.g.
+// when the request publishing the event was canceled or the transport is
+// full for too long. It returns ErrEngineClosed once the event engine started
+// shutting down.
+func (e *eventEngine) PublishContext(ctx context.Context, newEvent *event.Event) error {
+	return e.publish(ctx, newEvent)
+}
+
+// PublishBatch publishes every event of newEvents, or none of them if one can
+// not be published, e.g. because it is not registered. The events are
+// recorded in the event store in one go, and reach the subscribers in the
+// order of newEvents.
+//
+// Like PublishContext, it gives up once ctx is done, but only before the
+// events are sent, so it never publishes part of the batch.
+func (e *eventEngine) PublishBatch(ctx context.Context, newEvents ...*event.Event) error {
+	if len(newEvents) == 0 {
+		return nil
+	}
+
+	return e.publish(ctx, newEvents...)
+}
+
+// PublishAndWait publishes newEvent like PublishContext, and waits until
+// every subscriber of this instance of the server handled it. A handler has
+// handled an event once it succeeded, the event was dead lettered or its
+// retries were interrupted, and a raw subscriber once the event was sent to
+// its addressCh.
+//
+// It returns the errors of the handlers that failed, which wrap
+// ErrHandlingInterrupted if their retries were interrupted, and
+// ErrEventDropped for the subscribers that dropped newEvent. If ctx is done before every
+// subscriber handled it, newEvent is still published.
+func (e *eventEngine) PublishAndWait(ctx context.Context, newEvent *event.Event) error {
+	if err := e.prepare(newEvent); err != nil {
+		return err
+	}
+
+	ack := e.acks.await(newEvent.ID)
+	defer e.acks.forget(newEvent.ID)
+
+	if err := e.publish(ctx, newEvent); err != nil {
+		return err
+	}
+
+	select {
+	case <-ack.doneCh:
+	case <-ctx.Done():
+		return fmt.Errorf(
+			"event %s was published, but not every subscriber handled it: %w",
+			newEvent,
+			ctx.Err(),
+		)
+	}
+
+	if err := errors.Join(ack.errs...); err != nil {
+		return fmt.Errorf(
+			"event %s was published, but not every subscriber handled it: %w",
+			newEvent,
+			err,
+		)
+	}
+
+	return nil
+}
+
+// publish prepares, records and sends newEvents, all of them or none.
+func (e *eventEngine) publish(ctx context.Context, newEvents ...*event.Event) error {
+	for _, newEvent := range newEvents {
+		if err := e.prepare(newEvent); err != nil {
+			return err
+		}
+	}
+
+	if !e.gate.enter() {
+		return ErrEngineClosed
+	}
+	defer e.gate.leave()
+
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("failed to publish: %w", err)
+	}
+
+	if e.EventStore != nil {
+		if err := e.record(ctx, newEvents...); err != nil {
+			return err
+		}
+	}
+
+	for _, newEvent := range newEvents {
+		log.Printf("publishing event %s\n", newEvent)
+	}
+
+	var err error
+	if len(newEvents) == 1 {
+		err = e.Transport.Send(ctx, newEvents[0])
+	} else {
+		err = e.Transport.SendBatch(ctx, newEvents)
+	}
+	if err != nil {
+		return err
+	}
+
+	for _, newEvent := range newEvents {
+		e.published.inc(newEvent.Name)
+	}
+
+	return nil
+}
+
+// acknowledgements are the events PublishAndWait waits for, by id.
+type acknowledgements struct {
+	mu      sync.Mutex
+	pending map[uuid.UUID]*acknowledgement
+}
+
+// acknowledgement is which subscribers of an event still have to handle it.
+type acknowledgement struct {
+	broadcast bool                     // set once the subscribers are known.
+	pending   map[*subscriber]struct{} // the subscribers that did not handle the event yet.
+	errs      []error
+	doneCh    chan struct{} // closed once every subscriber handled the event.
+}
+
+// await starts waiting for the subscribers of the event with eventID.
+func (a *acknowledgements) await(eventID uuid.UUID) *acknowledgement {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.pending == nil {
+		a.pending = make(map[uuid.UUID]*acknowledgement)
+	}
+
+	ack := &acknowledgement{
+		pending: make(map[*subscriber]struct{}),
+		doneCh:  make(chan struct{}),
+	}
+	a.pending[eventID] = ack
+
+	return ack
+}
+
+func (a *acknowledgements) forget(eventID uuid.UUID) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	delete(a.pending, eventID)
+}
+
+// expect sets the subscribers the event with eventID is broadcast to, if it
+// is waited for.
+func (a *acknowledgements) expect(eventID uuid.UUID, subscribers []*subscriber) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	ack, ok := a.pending[eventID]
+	if !ok || ack.broadcast {
+		return
+	}
+
+	ack.broadcast = true
+	for _, s := range subscribers {
+		ack.pending[s] = struct{}{}
+	}
+
+	if len(ack.pending) == 0 {
+		close(ack.doneCh)
+	}
+}
+
+// done records that s handled the event with eventID, and failed to if err
+// is not nil. It does nothing unless the event is waited for and s still has
+// to handle it, e.g. for an event a durable handler handles again.
+func (a *acknowledgements) done(eventID uuid.UUID, s *subscriber, err error) {
+	if s == nil {
+		return
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	ack, ok := a.pending[eventID]
+	if !ok {
+		return
+	}
+
+	if _, ok := ack.pending[s]; !ok {
+		return
+	}
+	delete(ack.pending, s)
+
+	if err != nil {
+		ack.errs = append(ack.errs, fmt.Errorf("subscriber '%s': %w", s.name, err))
+	}
+
+	if len(ack.pending) == 0 {
+		close(ack.doneCh)
+	}
+}
@@ -0,0 +1,230 @@
+package eventengine
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/eng-by-sjb/yellow-pines-e-commerce-backend/internal/eventengine/event"
+)
+
+func Test_PublishContext(t *testing.T) {
+	type testPayload struct{}
+
+	t.Run("gives up on a full transport", func(t *testing.T) {
+		transport := NewChannelTransport(1)
+
+		if err := transport.Send(context.Background(), &event.Event{Name: "test.event.publish"}); err != nil {
+			t.Fatalf("expected to send, got: %v", err)
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+
+		err := transport.Send(ctx, &event.Event{Name: "test.event.publish"})
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Fatalf("expected '%v', got: %v", context.DeadlineExceeded, err)
+		}
+	})
+
+	t.Run("fails once the event engine shuts down", func(t *testing.T) {
+		doneCh := make(chan struct{})
+		InternalSrvWG := sync.WaitGroup{}
+
+		engine := NewEventEngine(
+			&EventEngineConfig{
+				DoneCh:        doneCh,
+				InternalSrvWG: &InternalSrvWG,
+			},
+		)
+
+		var eventName event.EventName = "test.event.publish.closed"
+		engine.RegisterEvents(eventName)
+
+		close(doneCh)
+		InternalSrvWG.Wait()
+
+		err := engine.PublishContext(context.Background(), event.New(context.Background(), "test_producer", eventName, &testPayload{}))
+		if !errors.Is(err, ErrEngineClosed) {
+			t.Fatalf("expected '%v', got: %v", ErrEngineClosed, err)
+		}
+
+		if err := engine.Publish(event.New(context.Background(), "test_producer", eventName, &testPayload{})); !errors.Is(err, ErrEngineClosed) {
+			t.Fatalf("expected '%v', got: %v", ErrEngineClosed, err)
+		}
+	})
+
+	t.Run("fails once ctx is done", func(t *testing.T) {
+		doneCh := make(chan struct{})
+		InternalSrvWG := sync.WaitGroup{}
+
+		engine := NewEventEngine(
+			&EventEngineConfig{
+				DoneCh:        doneCh,
+				InternalSrvWG: &InternalSrvWG,
+			},
+		)
+		defer func() {
+			close(doneCh)
+			InternalSrvWG.Wait()
+		}()
+
+		var eventName event.EventName = "test.event.publish.canceled"
+		engine.RegisterEvents(eventName)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		err := engine.PublishContext(ctx, event.New(context.Background(), "test_producer", eventName, &testPayload{}))
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("expected '%v', got: %v", context.Canceled, err)
+		}
+	})
+}
+
+func Test_PublishBatch(t *testing.T) {
+	type testPayload struct {
+		Value int
+	}
+
+	doneCh := make(chan struct{})
+	InternalSrvWG := sync.WaitGroup{}
+
+	engine := NewEventEngine(
+		&EventEngineConfig{
+			DoneCh:        doneCh,
+			InternalSrvWG: &InternalSrvWG,
+			EventStore:    NewMemoryEventStore(),
+		},
+	)
+	defer func() {
+		close(doneCh)
+		InternalSrvWG.Wait()
+	}()
+
+	var eventName event.EventName = "test.event.publish.batch"
+	engine.RegisterEvents(eventName)
+
+	addressCh := make(chan *event.Event, 10)
+	_, err := engine.Subscribe(
+		eventName,
+		&event.Subscriber{
+			Name:      "test_subscriber_name.batch",
+			AddressCh: addressCh,
+		},
+	)
+	if err != nil {
+		t.Fatalf("expected to subscribe, got: %v", err)
+	}
+
+	newBatch := func(eventNames ...event.EventName) []*event.Event {
+		var newEvents []*event.Event
+		for i, eventName := range eventNames {
+			newEvents = append(newEvents, event.New(context.Background(), "test_producer", eventName, &testPayload{Value: i + 1}))
+		}
+		return newEvents
+	}
+
+	// the unregistered event fails the whole batch.
+	err = engine.PublishBatch(context.Background(), newBatch(eventName, "test.event.publish.unregistered")...)
+	if err == nil {
+		t.Fatal("expected a batch with an unregistered event to fail")
+	}
+
+	if err := engine.PublishBatch(context.Background(), newBatch(eventName, eventName, eventName)...); err != nil {
+		t.Fatalf("expected to publish the batch, got: %v", err)
+	}
+
+	for expected := 1; expected <= 3; expected++ {
+		select {
+		case receivedEvent := <-addressCh:
+			if value := receivedEvent.Payload.(*testPayload).Value; value != expected {
+				t.Fatalf("expected event %d of the batch, got: %d", expected, value)
+			}
+			if receivedEvent.Sequence != uint64(expected) {
+				t.Fatalf("expected the batch to be recorded with sequence %d, got: %d", expected, receivedEvent.Sequence)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("expected event %d of the batch to be delivered", expected)
+		}
+	}
+
+	select {
+	case receivedEvent := <-addressCh:
+		t.Fatalf("expected nothing of the failed batch to be delivered, got: %s", receivedEvent)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func Test_PublishAndWait(t *testing.T) {
+	type testPayload struct {
+		Value int
+	}
+
+	doneCh := make(chan struct{})
+	InternalSrvWG := sync.WaitGroup{}
+
+	engine := NewEventEngine(
+		&EventEngineConfig{
+			DoneCh:        doneCh,
+			InternalSrvWG: &InternalSrvWG,
+		},
+	)
+	defer func() {
+		close(doneCh)
+		InternalSrvWG.Wait()
+	}()
+
+	var eventName event.EventName = "test.event.publish.wait"
+	engine.RegisterEvents(eventName)
+
+	var handled sync.Map
+	_, err := Subscribe(
+		engine,
+		eventName,
+		&SubscriptionConfig{
+			SubscriberName: "test_subscriber_name.wait",
+			Retry:          &RetryPolicy{MaxAttempts: 1},
+		},
+		func(_ context.Context, payload *testPayload) error {
+			// slow enough that PublishAndWait returning early would be noticed.
+			time.Sleep(20 * time.Millisecond)
+			handled.Store(payload.Value, true)
+
+			if payload.Value < 0 {
+				return errors.New("negative value")
+			}
+			return nil
+		},
+	)
+	if err != nil {
+		t.Fatalf("expected to subscribe, got: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	if err := engine.PublishAndWait(ctx, event.New(ctx, "test_producer", eventName, &testPayload{Value: 1})); err != nil {
+		t.Fatalf("expected every subscriber to handle the event, got: %v", err)
+	}
+	if _, ok := handled.Load(1); !ok {
+		t.Fatal("expected the event to be handled once PublishAndWait returned")
+	}
+
+	err = engine.PublishAndWait(ctx, event.New(ctx, "test_producer", eventName, &testPayload{Value: -1}))
+	if err == nil {
+		t.Fatal("expected the failure of the handler to be returned")
+	}
+	if _, ok := handled.Load(-1); !ok {
+		t.Fatal("expected the event to be handled once PublishAndWait returned")
+	}
+
+	var unsubscribedEventName event.EventName = "test.event.publish.wait.unsubscribed"
+	engine.RegisterEvents(unsubscribedEventName)
+
+	if err := engine.PublishAndWait(ctx, event.New(ctx, "test_producer", unsubscribedEventName, &testPayload{})); err != nil {
+		t.Fatalf("expected an event without subscribers to be handled at once, got: %v", err)
+	}
+}
@@ -0,0 +1,143 @@
+package eventengine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/eng-by-sjb/yellow-pines-e-commerce-backend/internal/eventengine/event"
+)
+
+// ErrHandlingInterrupted is the error of a failing handler whose retries were
+// cut short because the event engine shut down or its context was canceled.
+// The event is neither dead lettered nor checkpointed then, so it is handled
+// again once the outbox relays it again or it is replayed.
+var ErrHandlingInterrupted = errors.New("handling of event interrupted before its retries were exhausted")
+
+// RetryPolicy is how often and how long the event engine waits before calling a
+// failing handler again. The backoff between attempts grows exponentially from
+// InitialBackoff by Multiplier, and never exceeds MaxBackoff.
+type RetryPolicy struct {
+	MaxAttempts    uint16 // Number of times a handler is called before the event is dead lettered. 1 means no retries.
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	Multiplier     float64
+}
+
+// DefaultRetryPolicy is used by subscriptions that do not set a RetryPolicy.
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts:    3,
+	InitialBackoff: 200 * time.Millisecond,
+	MaxBackoff:     5 * time.Second,
+	Multiplier:     2,
+}
+
+// withDefaults returns a copy of p with its zero fields set to the values of
+// [DefaultRetryPolicy].
+func (p *RetryPolicy) withDefaults() *RetryPolicy {
+	if p == nil {
+		policy := DefaultRetryPolicy
+		return &policy
+	}
+
+	policy := *p
+	if policy.MaxAttempts == 0 {
+		policy.MaxAttempts = DefaultRetryPolicy.MaxAttempts
+	}
+
+	if policy.InitialBackoff == 0 {
+		policy.InitialBackoff = DefaultRetryPolicy.InitialBackoff
+	}
+
+	if policy.MaxBackoff < policy.InitialBackoff {
+		policy.MaxBackoff = max(DefaultRetryPolicy.MaxBackoff, policy.InitialBackoff)
+	}
+
+	if policy.Multiplier < 1 {
+		policy.Multiplier = DefaultRetryPolicy.Multiplier
+	}
+
+	return &policy
+}
+
+func (p *RetryPolicy) nextBackoff(backoff time.Duration) time.Duration {
+	return min(
+		time.Duration(float64(backoff)*p.Multiplier),
+		p.MaxBackoff,
+	)
+}
+
+// handleWithRetry calls handler with the payload of newEvent until it
+// succeeds or handler.Retry.MaxAttempts is reached. It returns the number of
+// attempts made and the error of the last attempt.
+//
+// The context passed to the handler is described by handlerContext, and each
+// attempt may take up to handler.HandlerTimeout.
+//
+// Once the event engine starts shutting down, it stops waiting between
+// attempts so a failing handler does not hold up the shutdown, and returns
+// the error of the last attempt wrapped in [ErrHandlingInterrupted]. So does
+// it once ctx is done.
+func (e *eventEngine) handleWithRetry(
+	ctx context.Context,
+	handler *Handler,
+	newEvent *event.Event,
+) (uint16, error) {
+	ctx, cancel := handlerContext(ctx, newEvent)
+	defer cancel()
+
+	backoff := handler.Retry.InitialBackoff
+
+	for attempt := uint16(1); ; attempt++ {
+		err := e.attempt(ctx, handler, newEvent)
+		if err == nil {
+			return attempt, nil
+		}
+
+		// the attempt may have failed because it was canceled.
+		if ctx.Err() != nil {
+			return attempt, fmt.Errorf("%w: %w", ErrHandlingInterrupted, err)
+		}
+
+		if attempt >= handler.Retry.MaxAttempts {
+			return attempt, err
+		}
+
+		log.Printf(
+			"subscriber '%s' failed to handle event %s (attempt %d of %d), retrying in %v: %v\n",
+			handler.SubscriberName,
+			newEvent,
+			attempt,
+			handler.Retry.MaxAttempts,
+			backoff,
+			err,
+		)
+
+		select {
+		case <-e.DoneCh:
+			return attempt, fmt.Errorf("%w: %w", ErrHandlingInterrupted, err)
+		case <-ctx.Done():
+			return attempt, fmt.Errorf("%w: %w", ErrHandlingInterrupted, err)
+		case <-time.After(backoff):
+		}
+
+		backoff = handler.Retry.nextBackoff(backoff)
+	}
+}
+
+// attempt calls handler with the payload of newEvent once, and cancels the
+// context of the handler after handler.HandlerTimeout.
+func (e *eventEngine) attempt(ctx context.Context, handler *Handler, newEvent *event.Event) error {
+	ctx, cancel := context.WithTimeout(ctx, handler.HandlerTimeout)
+	defer cancel()
+
+	if handler.metrics != nil {
+		defer func(start time.Time) {
+			handler.metrics.latency.observe(time.Since(start))
+		}(time.Now())
+	}
+
+	return handler.Handle(ctx, newEvent.Payload)
+}
@@ -0,0 +1,482 @@
+package saga
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/eng-by-sjb/yellow-pines-e-commerce-backend/internal/eventengine"
+	"github.com/eng-by-sjb/yellow-pines-e-commerce-backend/internal/eventengine/event"
+	"github.com/google/uuid"
+)
+
+type OrchestratorConfig struct {
+	DoneCh        <-chan struct{}
+	InternalSrvWG *sync.WaitGroup
+	EventEngine   eventengine.Subscriber // Where the events that complete or fail steps are subscribed to.
+	Store         Store                  // Where saga instances are persisted. Defaults to an in-memory store.
+	CheckInterval time.Duration          // How often steps are checked for timeouts. Defaults to 1s.
+}
+
+// Orchestrator runs the instances of the sagas registered with it.
+//
+// Several orchestrators, e.g. on several instances of the server, can share a
+// Store. An instance is only moved on by the first of them that updates it,
+// see [Store.Update].
+type Orchestrator struct {
+	*OrchestratorConfig
+	mu          sync.RWMutex
+	definitions map[string]*Definition
+	locks       keyedMutex // serializes the transitions of each instance.
+}
+
+func NewOrchestrator(cfg *OrchestratorConfig) *Orchestrator {
+	if cfg == nil {
+		log.Fatalln("'OrchestratorConfig' can not be nil")
+	}
+
+	if cfg.DoneCh == nil || cfg.InternalSrvWG == nil || cfg.EventEngine == nil {
+		log.Fatalln("either DoneCh, InternalSrvWG or EventEngine is nil in 'OrchestratorConfig'")
+	}
+
+	if cfg.Store == nil {
+		cfg.Store = NewMemoryStore()
+	}
+
+	if cfg.CheckInterval <= 0 {
+		cfg.CheckInterval = time.Second
+	}
+
+	o := &Orchestrator{
+		OrchestratorConfig: cfg,
+		definitions:        make(map[string]*Definition),
+	}
+
+	o.InternalSrvWG.Add(1)
+	go o.checkTimeouts()
+
+	return o
+}
+
+// trigger is a [Trigger] of a step of a saga.
+type trigger struct {
+	*Trigger
+	step      int
+	completes bool // whether the trigger completes or fails the step.
+}
+
+// Register adds definition to the sagas o runs, and subscribes to the events
+// that complete or fail its steps.
+func (o *Orchestrator) Register(definition *Definition) error {
+	if definition == nil || definition.Name == "" || len(definition.Steps) == 0 {
+		return errors.New("a saga needs a name and at least one step")
+	}
+
+	triggers := make(map[event.EventName][]*trigger)
+	for i, step := range definition.Steps {
+		if step == nil || step.Name == "" {
+			return fmt.Errorf("step %d of saga '%s' needs a name", i, definition.Name)
+		}
+
+		if step.FailedBy != nil && step.CompletedBy == nil {
+			return fmt.Errorf("step '%s' of saga '%s' has a FailedBy trigger but no CompletedBy trigger", step.Name, definition.Name)
+		}
+
+		for _, t := range []*trigger{
+			{Trigger: step.CompletedBy, step: i, completes: true},
+			{Trigger: step.FailedBy, step: i},
+		} {
+			if t.Trigger != nil {
+				triggers[t.EventName] = append(triggers[t.EventName], t)
+			}
+		}
+	}
+
+	o.mu.Lock()
+	if _, ok := o.definitions[definition.Name]; ok {
+		o.mu.Unlock()
+		return fmt.Errorf("saga '%s' is already registered", definition.Name)
+	}
+	o.definitions[definition.Name] = definition
+	o.mu.Unlock()
+
+	for eventName, eventTriggers := range triggers {
+		_, err := o.EventEngine.SubscribeHandler(
+			eventName,
+			&eventengine.Handler{
+				SubscriptionConfig: &eventengine.SubscriptionConfig{
+					SubscriberName: event.SubscriberName("saga." + definition.Name),
+				},
+				PayloadType: eventTriggers[0].payloadType,
+				Handle: func(ctx context.Context, payload any) error {
+					return o.onEvent(ctx, definition, eventTriggers, payload)
+				},
+			},
+		)
+		if err != nil {
+			return fmt.Errorf(
+				"failed to subscribe saga '%s' to event '%s': %w",
+				definition.Name,
+				eventName,
+				err,
+			)
+		}
+	}
+
+	return nil
+}
+
+// Start starts an instance of the saga sagaName for key with data, which the
+// steps can read with [Instance.Decode]. It runs the steps until one waits
+// for an event, so the returned instance is either still running or settled.
+//
+// If a step fails while starting, the completed steps are compensated and the
+// error of the step is returned together with the instance.
+func (o *Orchestrator) Start(ctx context.Context, sagaName string, key string, data any) (*Instance, error) {
+	definition, err := o.definition(sagaName)
+	if err != nil {
+		return nil, err
+	}
+
+	rawData, err := json.Marshal(data)
+	if err != nil {
+		return nil, fmt.Errorf(
+			"failed to marshal data of saga '%s' for '%s': %w",
+			sagaName,
+			key,
+			err,
+		)
+	}
+
+	unlock := o.locks.lock(sagaName, key)
+	defer unlock()
+
+	firstStep := definition.Steps[0]
+	instance := &Instance{
+		SagaID:    uuid.New(),
+		SagaName:  sagaName,
+		Key:       key,
+		Status:    StatusRunning,
+		StepName:  firstStep.Name,
+		Data:      rawData,
+		Deadline:  time.Now().UTC().Add(firstStep.timeout()),
+		StartedAt: time.Now().UTC(),
+	}
+
+	if err := o.Store.Create(ctx, instance); err != nil {
+		return nil, err
+	}
+
+	return instance, o.run(ctx, definition, instance)
+}
+
+// Get returns the instance of the saga sagaName for key.
+func (o *Orchestrator) Get(ctx context.Context, sagaName string, key string) (*Instance, error) {
+	return o.Store.GetByKey(ctx, sagaName, key)
+}
+
+// Wait waits until the instance of the saga sagaName for key is settled, or
+// ctx is done, and returns it as it is by then.
+func (o *Orchestrator) Wait(ctx context.Context, sagaName string, key string) (*Instance, error) {
+	const pollInterval = 100 * time.Millisecond
+
+	for {
+		instance, err := o.Store.GetByKey(ctx, sagaName, key)
+		if err != nil {
+			return nil, err
+		}
+
+		if instance.Status.Settled() {
+			return instance, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return instance, nil
+		case <-o.DoneCh:
+			return instance, nil
+		case <-time.After(pollInterval):
+		}
+	}
+}
+
+func (o *Orchestrator) definition(sagaName string) (*Definition, error) {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+
+	definition, ok := o.definitions[sagaName]
+	if !ok {
+		return nil, fmt.Errorf("%w: '%s'", ErrUnknownSaga, sagaName)
+	}
+
+	return definition, nil
+}
+
+// run runs the steps of instance from instance.Step on, until a step waits
+// for an event, a step fails or every step completed.
+func (o *Orchestrator) run(ctx context.Context, definition *Definition, instance *Instance) error {
+	for ; instance.Step < len(definition.Steps); instance.Step++ {
+		step := definition.Steps[instance.Step]
+
+		instance.StepName = step.Name
+		instance.Deadline = time.Now().UTC().Add(step.timeout())
+		if err := o.Store.Update(ctx, instance); err != nil {
+			return err
+		}
+
+		if step.Action != nil {
+			if err := step.Action(ctx, instance); err != nil {
+				return o.fail(
+					ctx,
+					definition,
+					instance,
+					fmt.Errorf("%w: step '%s' of saga '%s': %w", ErrStepFailed, step.Name, definition.Name, err),
+				)
+			}
+		}
+
+		if step.CompletedBy != nil {
+			return nil // the step completes once its event arrives.
+		}
+	}
+
+	instance.Status = StatusCompleted
+	instance.Deadline = time.Time{}
+	if err := o.Store.Update(ctx, instance); err != nil {
+		return err
+	}
+
+	log.Printf("saga '%s' for '%s' completed\n", definition.Name, instance.Key)
+
+	return nil
+}
+
+// fail starts compensating the completed steps of instance because of
+// failure, and returns failure, together with the error of compensating if
+// that failed too.
+func (o *Orchestrator) fail(ctx context.Context, definition *Definition, instance *Instance, failure error) error {
+	log.Printf(
+		"\033[31m saga '%s' for '%s' failed at step '%s', compensating: %v\033[0m\n",
+		definition.Name,
+		instance.Key,
+		instance.StepName,
+		failure,
+	)
+
+	instance.Status = StatusCompensating
+	instance.Error = failure.Error()
+	if err := o.compensate(ctx, definition, instance); err != nil {
+		return errors.Join(failure, err)
+	}
+
+	return failure
+}
+
+// compensate compensates the steps before instance.Step in reverse order,
+// persisting its progress after each, so it resumes where it left off if it
+// is interrupted.
+func (o *Orchestrator) compensate(ctx context.Context, definition *Definition, instance *Instance) error {
+	for instance.Step > 0 {
+		step := definition.Steps[instance.Step-1]
+
+		instance.StepName = step.Name
+		instance.Deadline = time.Now().UTC().Add(step.timeout())
+		if err := o.Store.Update(ctx, instance); err != nil {
+			return err
+		}
+
+		if step.Compensate != nil {
+			if err := step.Compensate(ctx, instance); err != nil {
+				instance.Status = StatusFailed
+				instance.Error = fmt.Sprintf("%s; compensating step '%s' failed: %v", instance.Error, step.Name, err)
+				instance.Deadline = time.Time{}
+				if updateErr := o.Store.Update(ctx, instance); updateErr != nil {
+					return errors.Join(err, updateErr)
+				}
+
+				return fmt.Errorf(
+					"failed to compensate step '%s' of saga '%s': %w",
+					step.Name,
+					definition.Name,
+					err,
+				)
+			}
+		}
+
+		instance.Step--
+	}
+
+	instance.Status = StatusCompensated
+	instance.Deadline = time.Time{}
+	if err := o.Store.Update(ctx, instance); err != nil {
+		return err
+	}
+
+	log.Printf("saga '%s' for '%s' compensated\n", definition.Name, instance.Key)
+
+	return nil
+}
+
+// onEvent completes or fails the step the triggers of payload are for, of
+// the instances the payload belongs to.
+func (o *Orchestrator) onEvent(ctx context.Context, definition *Definition, triggers []*trigger, payload any) error {
+	var errs []error
+
+	for _, t := range triggers {
+		key := t.keyOf(payload)
+
+		err := o.transition(ctx, definition.Name, key, func(instance *Instance) error {
+			// the event is for another step, or was handled already.
+			if instance.Status != StatusRunning || instance.Step != t.step {
+				return nil
+			}
+
+			if !t.completes {
+				step := definition.Steps[instance.Step]
+				failure := fmt.Errorf("%w: step '%s' of saga '%s': event '%s'", ErrStepFailed, step.Name, definition.Name, t.EventName)
+
+				// the failure of the step is handled by compensating it, unlike
+				// a failure to compensate.
+				if err := o.fail(ctx, definition, instance, failure); err != failure {
+					return err
+				}
+				return nil
+			}
+
+			instance.Step++
+			err := o.run(ctx, definition, instance)
+			if errors.Is(err, ErrStepFailed) {
+				return nil // the instance compensated.
+			}
+			return err
+		})
+		errs = append(errs, err)
+	}
+
+	return errors.Join(errs...)
+}
+
+// transition calls fn with the latest state of the instance of the saga
+// sagaName for key, while no other transition of the instance runs.
+// Instances that do not exist, or were moved on by another orchestrator
+// meanwhile, are skipped.
+func (o *Orchestrator) transition(ctx context.Context, sagaName string, key string, fn func(instance *Instance) error) error {
+	unlock := o.locks.lock(sagaName, key)
+	defer unlock()
+
+	instance, err := o.Store.GetByKey(ctx, sagaName, key)
+	if err != nil {
+		if errors.Is(err, ErrInstanceNotFound) {
+			return nil
+		}
+		return err
+	}
+
+	if err := fn(instance); err != nil && !errors.Is(err, ErrVersionConflict) {
+		return err
+	}
+
+	return nil
+}
+
+// checkTimeouts fails the instances whose step timed out, and resumes
+// compensating the instances that were interrupted while compensating, until
+// the server shuts down.
+func (o *Orchestrator) checkTimeouts() {
+	defer o.InternalSrvWG.Done()
+
+	ticker := time.NewTicker(o.CheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-o.DoneCh:
+			return
+
+		case <-ticker.C:
+			ctx, cancel := context.WithTimeout(context.Background(), (30 * time.Second))
+			o.checkDue(ctx)
+			cancel()
+		}
+	}
+}
+
+func (o *Orchestrator) checkDue(ctx context.Context) {
+	instances, err := o.Store.ListDue(ctx, time.Now().UTC())
+	if err != nil {
+		log.Printf("failed to list due saga instances: %v\n", err)
+		return
+	}
+
+	for _, due := range instances {
+		definition, err := o.definition(due.SagaName)
+		if err != nil {
+			continue // registered by another server.
+		}
+
+		err = o.transition(ctx, due.SagaName, due.Key, func(instance *Instance) error {
+			if instance.Status.Settled() || instance.Deadline.IsZero() || instance.Deadline.After(time.Now().UTC()) {
+				return nil
+			}
+
+			if instance.Status == StatusCompensating {
+				return o.compensate(ctx, definition, instance)
+			}
+
+			failure := fmt.Errorf("%w: step '%s' of saga '%s'", ErrStepTimedOut, instance.StepName, definition.Name)
+			if err := o.fail(ctx, definition, instance, failure); err != failure {
+				return err
+			}
+			return nil
+		})
+		if err != nil {
+			log.Printf("failed to move on due saga '%s' for '%s': %v\n", due.SagaName, due.Key, err)
+		}
+	}
+}
+
+// keyedMutex is a mutex per saga instance.
+type keyedMutex struct {
+	mu    sync.Mutex
+	locks map[string]*keyLock
+}
+
+type keyLock struct {
+	sync.Mutex
+	waiting int
+}
+
+// lock locks the instance of the saga sagaName for key, and returns the func
+// that unlocks it.
+func (m *keyedMutex) lock(sagaName string, key string) func() {
+	lockKey := sagaName + "/" + key
+
+	m.mu.Lock()
+	if m.locks == nil {
+		m.locks = make(map[string]*keyLock)
+	}
+	l, ok := m.locks[lockKey]
+	if !ok {
+		l = &keyLock{}
+		m.locks[lockKey] = l
+	}
+	l.waiting++
+	m.mu.Unlock()
+
+	l.Lock()
+
+	return func() {
+		l.Unlock()
+
+		m.mu.Lock()
+		l.waiting--
+		if l.waiting == 0 {
+			delete(m.locks, lockKey)
+		}
+		m.mu.Unlock()
+	}
+}
@@ -0,0 +1,228 @@
+package saga
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/eng-by-sjb/yellow-pines-e-commerce-backend/internal/eventengine"
+	"github.com/eng-by-sjb/yellow-pines-e-commerce-backend/internal/eventengine/event"
+)
+
+type testPayload struct {
+	Key string
+}
+
+func Test_Orchestrator(t *testing.T) {
+	const sagaName = "test.saga"
+	var completedEventName event.EventName = "test.saga.step.completed"
+	var failedEventName event.EventName = "test.saga.step.failed"
+
+	doneCh := make(chan struct{})
+	InternalSrvWG := sync.WaitGroup{}
+
+	engine := eventengine.NewEventEngine(
+		&eventengine.EventEngineConfig{
+			DoneCh:        doneCh,
+			InternalSrvWG: &InternalSrvWG,
+		},
+	)
+	engine.RegisterEvents(completedEventName, failedEventName)
+
+	orchestrator := NewOrchestrator(
+		&OrchestratorConfig{
+			DoneCh:        doneCh,
+			InternalSrvWG: &InternalSrvWG,
+			EventEngine:   engine,
+			CheckInterval: 10 * time.Millisecond,
+		},
+	)
+
+	var mu sync.Mutex
+	compensated := make(map[string]bool)
+
+	keyOf := func(payload *testPayload) string { return payload.Key }
+
+	err := orchestrator.Register(
+		&Definition{
+			Name: sagaName,
+			Steps: []*Step{
+				{
+					Name: "first",
+					Action: func(ctx context.Context, instance *Instance) error {
+						var data testPayload
+						if err := instance.Decode(&data); err != nil {
+							return err
+						}
+
+						if data.Key == "action-fails" {
+							return errors.New("test action failed")
+						}
+						return nil
+					},
+					Compensate: func(ctx context.Context, instance *Instance) error {
+						mu.Lock()
+						defer mu.Unlock()
+
+						compensated[instance.Key] = true
+						return nil
+					},
+				},
+				{
+					Name:        "second",
+					CompletedBy: On(completedEventName, keyOf),
+					FailedBy:    On(failedEventName, keyOf),
+					Timeout:     200 * time.Millisecond,
+				},
+			},
+		},
+	)
+	if err != nil {
+		t.Fatalf("expected to register saga, got: %v", err)
+	}
+
+	start := func(key string) *Instance {
+		instance, err := orchestrator.Start(context.Background(), sagaName, key, &testPayload{Key: key})
+		if err != nil {
+			t.Fatalf("expected to start saga for '%s', got: %v", key, err)
+		}
+
+		if instance.Status != StatusRunning || instance.StepName != "second" {
+			t.Fatalf("expected saga for '%s' to wait at step 'second', got: %s at '%s'", key, instance.Status, instance.StepName)
+		}
+
+		return instance
+	}
+
+	publish := func(eventName event.EventName, key string) {
+		err := engine.Publish(event.New(context.Background(), "test_producer", eventName, &testPayload{Key: key}))
+		if err != nil {
+			t.Fatalf("expected to publish, got: %v", err)
+		}
+	}
+
+	wait := func(key string, want Status) *Instance {
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		defer cancel()
+
+		instance, err := orchestrator.Wait(ctx, sagaName, key)
+		if err != nil {
+			t.Fatalf("expected to wait for saga for '%s', got: %v", key, err)
+		}
+
+		if instance.Status != want {
+			t.Fatalf("expected saga for '%s' to be %s, got: %s (%s)", key, want, instance.Status, instance.Error)
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		if compensated[key] != (want == StatusCompensated) {
+			t.Fatalf("expected first step of saga for '%s' to be compensated only if the saga was", key)
+		}
+
+		return instance
+	}
+
+	start("completes")
+	publish(completedEventName, "completes")
+	wait("completes", StatusCompleted)
+
+	start("fails")
+	publish(failedEventName, "fails")
+	wait("fails", StatusCompensated)
+
+	start("times-out")
+	if instance := wait("times-out", StatusCompensated); !strings.Contains(instance.Error, ErrStepTimedOut.Error()) {
+		t.Fatalf("expected saga to fail by timing out, got: %s", instance.Error)
+	}
+
+	_, err = orchestrator.Start(context.Background(), sagaName, "action-fails", &testPayload{Key: "action-fails"})
+	if !errors.Is(err, ErrStepFailed) {
+		t.Fatalf("expected '%v', got: %v", ErrStepFailed, err)
+	}
+	if instance, _ := orchestrator.Get(context.Background(), sagaName, "action-fails"); instance.Status != StatusCompensated {
+		t.Fatalf("expected saga whose first step failed to be compensated, got: %s", instance.Status)
+	}
+
+	_, err = orchestrator.Start(context.Background(), sagaName, "completes", &testPayload{Key: "completes"})
+	if !errors.Is(err, ErrAlreadyStarted) {
+		t.Fatalf("expected '%v', got: %v", ErrAlreadyStarted, err)
+	}
+
+	close(doneCh)
+	InternalSrvWG.Wait()
+}
+
+func Test_Orchestrator_compensationFails(t *testing.T) {
+	const sagaName = "test.saga.compensation"
+	var completedEventName event.EventName = "test.saga.compensation.step.completed"
+	var failedEventName event.EventName = "test.saga.compensation.step.failed"
+
+	doneCh := make(chan struct{})
+	InternalSrvWG := sync.WaitGroup{}
+	defer func() {
+		close(doneCh)
+		InternalSrvWG.Wait()
+	}()
+
+	engine := eventengine.NewEventEngine(
+		&eventengine.EventEngineConfig{
+			DoneCh:        doneCh,
+			InternalSrvWG: &InternalSrvWG,
+		},
+	)
+	engine.RegisterEvents(completedEventName, failedEventName)
+
+	orchestrator := NewOrchestrator(
+		&OrchestratorConfig{
+			DoneCh:        doneCh,
+			InternalSrvWG: &InternalSrvWG,
+			EventEngine:   engine,
+		},
+	)
+
+	keyOf := func(payload *testPayload) string { return payload.Key }
+	compensateErr := errors.New("test compensation failed")
+	failedBy := On(failedEventName, keyOf)
+	definition := &Definition{
+		Name: sagaName,
+		Steps: []*Step{
+			{
+				Name:       "first",
+				Action:     func(ctx context.Context, instance *Instance) error { return nil },
+				Compensate: func(ctx context.Context, instance *Instance) error { return compensateErr },
+			},
+			{
+				Name:        "second",
+				CompletedBy: On(completedEventName, keyOf),
+				FailedBy:    failedBy,
+			},
+		},
+	}
+	if err := orchestrator.Register(definition); err != nil {
+		t.Fatalf("expected to register saga, got: %v", err)
+	}
+
+	if _, err := orchestrator.Start(context.Background(), sagaName, "key", &testPayload{Key: "key"}); err != nil {
+		t.Fatalf("expected to start saga, got: %v", err)
+	}
+
+	// the handler of the failed event reports the failed compensation, so
+	// the event engine retries or dead letters the event.
+	err := orchestrator.onEvent(
+		context.Background(),
+		definition,
+		[]*trigger{{Trigger: failedBy, step: 1}},
+		&testPayload{Key: "key"},
+	)
+	if !errors.Is(err, compensateErr) {
+		t.Fatalf("expected the error of the compensation, got: %v", err)
+	}
+
+	if instance, _ := orchestrator.Get(context.Background(), sagaName, "key"); instance.Status != StatusFailed {
+		t.Fatalf("expected saga whose compensation failed to be failed, got: %s", instance.Status)
+	}
+}
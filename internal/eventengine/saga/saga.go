@@ -0,0 +1,148 @@
+// Package saga orchestrates workflows that span several features, e.g.
+// creating a product and then its inventory. A saga declares its steps and
+// how to compensate them, and the [Orchestrator] runs an instance of it step
+// by step, persisting its state, until every step completed or a step failed
+// or timed out and the completed steps were compensated.
+package saga
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/eng-by-sjb/yellow-pines-e-commerce-backend/internal/eventengine/event"
+	"github.com/google/uuid"
+)
+
+var (
+	ErrInstanceNotFound = errors.New("saga instance not found")
+	ErrAlreadyStarted   = errors.New("saga instance already started")
+	ErrVersionConflict  = errors.New("saga instance was changed concurrently")
+	ErrUnknownSaga      = errors.New("saga is not registered")
+	ErrStepTimedOut     = errors.New("saga step timed out")
+	ErrStepFailed       = errors.New("saga step failed")
+)
+
+// DefaultStepTimeout is how long a step may take when it does not set a
+// Timeout.
+const DefaultStepTimeout = 30 * time.Second
+
+type Status string
+
+const (
+	StatusRunning      Status = "running"      // a step is in progress.
+	StatusCompleted    Status = "completed"    // every step completed.
+	StatusCompensating Status = "compensating" // a step failed, the completed steps are being compensated.
+	StatusCompensated  Status = "compensated"  // a step failed and the completed steps were compensated.
+	StatusFailed       Status = "failed"       // a step failed and compensating the completed steps failed too.
+)
+
+// Settled reports whether an instance with status s is done, either way.
+func (s Status) Settled() bool {
+	return s == StatusCompleted || s == StatusCompensated || s == StatusFailed
+}
+
+// Definition declares a saga.
+type Definition struct {
+	Name  string
+	Steps []*Step
+}
+
+// Step is a step of a saga.
+//
+// A step runs its Action, if any. If it has a CompletedBy trigger, it then
+// waits for that event and completes once it arrives, or fails once its
+// FailedBy event arrives or its Timeout passes. Otherwise it completes once
+// its Action returns.
+type Step struct {
+	Name   string
+	Action func(ctx context.Context, instance *Instance) error // Optional.
+
+	CompletedBy *Trigger // Optional event that completes the step.
+	FailedBy    *Trigger // Optional event that fails the step.
+
+	// Compensate undoes the step once a later step failed. It can be called
+	// more than once, e.g. after a restart, so it should be idempotent.
+	// Optional.
+	Compensate func(ctx context.Context, instance *Instance) error
+
+	Timeout time.Duration // How long the step may take. Defaults to DefaultStepTimeout.
+}
+
+func (s *Step) timeout() time.Duration {
+	if s.Timeout <= 0 {
+		return DefaultStepTimeout
+	}
+
+	return s.Timeout
+}
+
+// Trigger is an event that completes or fails a step of the saga instance
+// the event belongs to.
+type Trigger struct {
+	EventName   event.EventName
+	payloadType reflect.Type
+	keyOf       func(payload any) string
+}
+
+// On returns a [Trigger] for the event eventName with payloads of type T.
+// keyOf returns the key of the saga instance an event belongs to, e.g. the
+// id of the product the event is about.
+func On[T any](eventName event.EventName, keyOf func(payload T) string) *Trigger {
+	return &Trigger{
+		EventName:   eventName,
+		payloadType: reflect.TypeFor[T](),
+		keyOf: func(payload any) string {
+			return keyOf(payload.(T))
+		},
+	}
+}
+
+// Instance is a run of a saga for a key, e.g. the provisioning of one product.
+type Instance struct {
+	SagaID   uuid.UUID `json:"sagaID"`
+	SagaName string    `json:"sagaName"`
+	Key      string    `json:"key"`
+	Status   Status    `json:"status"`
+	// Step is the index of the step in progress. While compensating, the
+	// steps before Step are the ones left to compensate.
+	Step      int             `json:"step"`
+	StepName  string          `json:"stepName"`
+	Data      json.RawMessage `json:"-"`                  // What the instance was started with, see Decode.
+	Error     string          `json:"error,omitempty"`    // Why the instance failed, if it did.
+	Deadline  time.Time       `json:"deadline,omitempty"` // When the step in progress times out. Zero once settled.
+	Version   int             `json:"-"`                  // Incremented on every update, to detect concurrent updates.
+	StartedAt time.Time       `json:"startedAt"`
+	UpdatedAt time.Time       `json:"updatedAt"`
+}
+
+// Decode unmarshals the data the instance was started with into v.
+func (i *Instance) Decode(v any) error {
+	if err := json.Unmarshal(i.Data, v); err != nil {
+		return fmt.Errorf(
+			"failed to decode data of saga instance '%s': %w",
+			i.SagaID,
+			err,
+		)
+	}
+
+	return nil
+}
+
+// Store persists saga instances.
+type Store interface {
+	// Create adds instance, or returns ErrAlreadyStarted if an instance of the
+	// same saga and key exists.
+	Create(ctx context.Context, instance *Instance) error
+	// Update saves instance if it was not updated since it was read, and
+	// increments its Version. Otherwise it returns ErrVersionConflict.
+	Update(ctx context.Context, instance *Instance) error
+	Get(ctx context.Context, sagaID uuid.UUID) (*Instance, error)
+	GetByKey(ctx context.Context, sagaName string, key string) (*Instance, error)
+	// ListDue returns the instances that are not settled and whose deadline
+	// passed before now.
+	ListDue(ctx context.Context, now time.Time) ([]*Instance, error)
+}
@@ -0,0 +1,300 @@
+package saga
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+const (
+	instanceFields = "saga_id, saga_name, saga_key, status, step, step_name, data, error, deadline, version, started_at, updated_at"
+)
+
+type memoryStore struct {
+	mu        sync.RWMutex
+	instances map[uuid.UUID]*Instance
+}
+
+// NewMemoryStore returns a [Store] that keeps saga instances in memory. They
+// are lost when the server restarts, so it is meant for tests.
+func NewMemoryStore() Store {
+	return &memoryStore{
+		instances: make(map[uuid.UUID]*Instance),
+	}
+}
+
+func (s *memoryStore) Create(ctx context.Context, instance *Instance) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, existing := range s.instances {
+		if existing.SagaName == instance.SagaName && existing.Key == instance.Key {
+			return ErrAlreadyStarted
+		}
+	}
+
+	instance.Version = 1
+	instance.UpdatedAt = time.Now().UTC()
+
+	stored := *instance
+	s.instances[instance.SagaID] = &stored
+
+	return nil
+}
+
+func (s *memoryStore) Update(ctx context.Context, instance *Instance) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored, ok := s.instances[instance.SagaID]
+	if !ok {
+		return ErrInstanceNotFound
+	}
+
+	if stored.Version != instance.Version {
+		return ErrVersionConflict
+	}
+
+	instance.Version++
+	instance.UpdatedAt = time.Now().UTC()
+
+	updated := *instance
+	s.instances[instance.SagaID] = &updated
+
+	return nil
+}
+
+func (s *memoryStore) Get(ctx context.Context, sagaID uuid.UUID) (*Instance, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	stored, ok := s.instances[sagaID]
+	if !ok {
+		return nil, ErrInstanceNotFound
+	}
+
+	instance := *stored
+	return &instance, nil
+}
+
+func (s *memoryStore) GetByKey(ctx context.Context, sagaName string, key string) (*Instance, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, stored := range s.instances {
+		if stored.SagaName == sagaName && stored.Key == key {
+			instance := *stored
+			return &instance, nil
+		}
+	}
+
+	return nil, ErrInstanceNotFound
+}
+
+func (s *memoryStore) ListDue(ctx context.Context, now time.Time) ([]*Instance, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	instances := []*Instance{}
+	for _, stored := range s.instances {
+		if !stored.Status.Settled() && !stored.Deadline.IsZero() && stored.Deadline.Before(now) {
+			instance := *stored
+			instances = append(instances, &instance)
+		}
+	}
+
+	return instances, nil
+}
+
+type postgresStore struct {
+	db *sql.DB
+}
+
+// NewPostgresStore returns a [Store] backed by the sagas table.
+func NewPostgresStore(db *sql.DB) Store {
+	return &postgresStore{
+		db: db,
+	}
+}
+
+func (s *postgresStore) Create(ctx context.Context, instance *Instance) error {
+	err := s.db.QueryRowContext(
+		ctx,
+		fmt.Sprintf(
+			"INSERT INTO sagas(%s) VALUES($1, $2, $3, $4, $5, $6, $7, $8, $9, 1, $10, NOW()) RETURNING version, updated_at",
+			instanceFields,
+		),
+		instance.SagaID,
+		instance.SagaName,
+		instance.Key,
+		instance.Status,
+		instance.Step,
+		instance.StepName,
+		[]byte(instance.Data),
+		instance.Error,
+		nullTime(instance.Deadline),
+		instance.StartedAt,
+	).Scan(
+		&instance.Version,
+		&instance.UpdatedAt,
+	)
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code.Name() == "unique_violation" {
+			return ErrAlreadyStarted
+		}
+
+		return fmt.Errorf(
+			"failed to insert saga instance in saga store: %w",
+			err,
+		)
+	}
+
+	return nil
+}
+
+func (s *postgresStore) Update(ctx context.Context, instance *Instance) error {
+	err := s.db.QueryRowContext(
+		ctx,
+		`UPDATE sagas
+		SET status = $3, step = $4, step_name = $5, error = $6, deadline = $7, version = version + 1, updated_at = NOW()
+		WHERE saga_id = $1 AND version = $2
+		RETURNING version, updated_at`,
+		instance.SagaID,
+		instance.Version,
+		instance.Status,
+		instance.Step,
+		instance.StepName,
+		instance.Error,
+		nullTime(instance.Deadline),
+	).Scan(
+		&instance.Version,
+		&instance.UpdatedAt,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrVersionConflict
+		}
+
+		return fmt.Errorf(
+			"failed to update saga instance in saga store: %w",
+			err,
+		)
+	}
+
+	return nil
+}
+
+func (s *postgresStore) Get(ctx context.Context, sagaID uuid.UUID) (*Instance, error) {
+	return s.getOne(
+		ctx,
+		fmt.Sprintf("SELECT %s FROM sagas WHERE saga_id = $1", instanceFields),
+		sagaID,
+	)
+}
+
+func (s *postgresStore) GetByKey(ctx context.Context, sagaName string, key string) (*Instance, error) {
+	return s.getOne(
+		ctx,
+		fmt.Sprintf("SELECT %s FROM sagas WHERE saga_name = $1 AND saga_key = $2", instanceFields),
+		sagaName,
+		key,
+	)
+}
+
+func (s *postgresStore) getOne(ctx context.Context, query string, args ...any) (*Instance, error) {
+	instance, err := scanRowIntoInstance(
+		s.db.QueryRowContext(ctx, query, args...),
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrInstanceNotFound
+		}
+		return nil, fmt.Errorf(
+			"failed to get saga instance in saga store: %w",
+			err,
+		)
+	}
+
+	return instance, nil
+}
+
+func (s *postgresStore) ListDue(ctx context.Context, now time.Time) ([]*Instance, error) {
+	rows, err := s.db.QueryContext(
+		ctx,
+		fmt.Sprintf(
+			"SELECT %s FROM sagas WHERE status IN ($1, $2) AND deadline < $3 ORDER BY deadline",
+			instanceFields,
+		),
+		StatusRunning,
+		StatusCompensating,
+		now,
+	)
+	if err != nil {
+		return nil, fmt.Errorf(
+			"failed to query due saga instances in saga store: %w",
+			err,
+		)
+	}
+	defer rows.Close()
+
+	instances := []*Instance{}
+	for rows.Next() {
+		instance, err := scanRowIntoInstance(rows)
+		if err != nil {
+			return nil, fmt.Errorf(
+				"failed to scan row into saga instance in saga store: %w",
+				err,
+			)
+		}
+		instances = append(instances, instance)
+	}
+
+	return instances, rows.Err()
+}
+
+// rowScanner is implemented by both [sql.Row] and [sql.Rows].
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanRowIntoInstance(row rowScanner) (*Instance, error) {
+	instance := new(Instance)
+	var data []byte
+	var deadline sql.NullTime
+
+	err := row.Scan(
+		&instance.SagaID,
+		&instance.SagaName,
+		&instance.Key,
+		&instance.Status,
+		&instance.Step,
+		&instance.StepName,
+		&data,
+		&instance.Error,
+		&deadline,
+		&instance.Version,
+		&instance.StartedAt,
+		&instance.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	instance.Data = data
+	instance.Deadline = deadline.Time
+
+	return instance, nil
+}
+
+func nullTime(t time.Time) sql.NullTime {
+	return sql.NullTime{
+		Time:  t,
+		Valid: !t.IsZero(),
+	}
+}
@@ -0,0 +1,341 @@
+package eventengine
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/eng-by-sjb/yellow-pines-e-commerce-backend/internal/eventengine/event"
+	"github.com/google/uuid"
+)
+
+// ErrScheduledEventNotFound is returned when cancelling an event that is not
+// scheduled, e.g. because it was published already.
+var ErrScheduledEventNotFound = errors.New("scheduled event not found")
+
+const (
+	scheduleTick         = 100 * time.Millisecond // how often the timer wheel of scheduled events advances.
+	scheduleSlots        = 600                    // slots of the timer wheel, so it goes round once a minute.
+	schedulePollInterval = 10 * time.Second       // how often events due within a minute are read from the schedule store.
+	scheduleClaimTTL     = 30 * time.Second       // how long a due event is claimed by the event engine publishing it.
+	scheduleBatchSize    = 100
+)
+
+// scheduleHorizon is how far ahead the scheduled events are read from the
+// schedule store.
+const scheduleHorizon = scheduleTick * scheduleSlots
+
+// Scheduler publishes events at a later time.
+type Scheduler interface {
+	// PublishAt publishes newEvent at t, or right away if t passed.
+	PublishAt(t time.Time, newEvent *event.Event) error
+	// PublishAfter publishes newEvent once delay passed.
+	PublishAfter(delay time.Duration, newEvent *event.Event) error
+	// CancelScheduled cancels the scheduled event with eventID.
+	CancelScheduled(ctx context.Context, eventID uuid.UUID) error
+}
+
+// ScheduledEvent is an event waiting in the schedule store to be published.
+type ScheduledEvent struct {
+	EventID       uuid.UUID       `json:"eventID"`
+	EventName     event.EventName `json:"eventName"`
+	EventVersion  uint16          `json:"eventVersion"`
+	OccurredAt    time.Time       `json:"occurredAt"`
+	CorrelationID string          `json:"correlationID"`
+	CausationID   uuid.UUID       `json:"causationID"`
+	Producer      string          `json:"producer"`
+	Payload       json.RawMessage `json:"payload"`
+	PublishAt     time.Time       `json:"publishAt"`
+	CreatedAt     time.Time       `json:"createdAt"`
+}
+
+// event rebuilds the envelope of the scheduled event around payload.
+func (s *ScheduledEvent) event(payload any) *event.Event {
+	return &event.Event{
+		ID:            s.EventID,
+		Name:          s.EventName,
+		Version:       s.EventVersion,
+		OccurredAt:    s.OccurredAt,
+		CorrelationID: s.CorrelationID,
+		CausationID:   s.CausationID,
+		Producer:      s.Producer,
+		Payload:       payload,
+	}
+}
+
+// ScheduleStore is where scheduled events wait until they are due, so they
+// survive restarts and can be published by any instance of the server.
+type ScheduleStore interface {
+	// Add schedules scheduledEvent and sets its CreatedAt. Adding an event that
+	// is already scheduled reschedules it.
+	Add(ctx context.Context, scheduledEvent *ScheduledEvent) error
+	// Upcoming returns up to limit events due before until, ordered by
+	// PublishAt.
+	Upcoming(ctx context.Context, until time.Time, limit int) ([]*ScheduledEvent, error)
+	// Claim returns up to limit events due at now that are not claimed,
+	// ordered by PublishAt, and claims them for claimTTL, so no other event
+	// engine publishes them meanwhile. An event that is not deleted before its
+	// claim expires, e.g. because publishing it failed, can be claimed again.
+	Claim(ctx context.Context, now time.Time, claimTTL time.Duration, limit int) ([]*ScheduledEvent, error)
+	// Delete removes the scheduled event with eventID, or returns
+	// ErrScheduledEventNotFound.
+	Delete(ctx context.Context, eventID uuid.UUID) error
+}
+
+type memoryScheduleStore struct {
+	mu           sync.Mutex
+	scheduled    map[uuid.UUID]*ScheduledEvent
+	claimedUntil map[uuid.UUID]time.Time
+}
+
+// NewMemoryScheduleStore returns a [ScheduleStore] that keeps scheduled
+// events in memory. They are lost when the server restarts, so it is meant
+// for tests.
+func NewMemoryScheduleStore() ScheduleStore {
+	return &memoryScheduleStore{
+		scheduled:    make(map[uuid.UUID]*ScheduledEvent),
+		claimedUntil: make(map[uuid.UUID]time.Time),
+	}
+}
+
+func (s *memoryScheduleStore) Add(ctx context.Context, scheduledEvent *ScheduledEvent) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	scheduledEvent.CreatedAt = time.Now().UTC()
+
+	stored := *scheduledEvent
+	s.scheduled[scheduledEvent.EventID] = &stored
+	delete(s.claimedUntil, scheduledEvent.EventID)
+
+	return nil
+}
+
+func (s *memoryScheduleStore) Upcoming(ctx context.Context, until time.Time, limit int) ([]*ScheduledEvent, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.collect(limit, func(scheduledEvent *ScheduledEvent) bool {
+		return scheduledEvent.PublishAt.Before(until)
+	}), nil
+}
+
+func (s *memoryScheduleStore) Claim(ctx context.Context, now time.Time, claimTTL time.Duration, limit int) ([]*ScheduledEvent, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	claimed := s.collect(limit, func(scheduledEvent *ScheduledEvent) bool {
+		return !scheduledEvent.PublishAt.After(now) && !s.claimedUntil[scheduledEvent.EventID].After(now)
+	})
+
+	for _, scheduledEvent := range claimed {
+		s.claimedUntil[scheduledEvent.EventID] = now.Add(claimTTL)
+	}
+
+	return claimed, nil
+}
+
+// collect returns copies of up to limit scheduled events include accepts,
+// ordered by PublishAt. It must be called with s.mu held.
+func (s *memoryScheduleStore) collect(limit int, include func(scheduledEvent *ScheduledEvent) bool) []*ScheduledEvent {
+	collected := []*ScheduledEvent{}
+	for _, stored := range s.scheduled {
+		if include(stored) {
+			scheduledEvent := *stored
+			collected = append(collected, &scheduledEvent)
+		}
+	}
+
+	sort.Slice(collected, func(i, j int) bool {
+		return collected[i].PublishAt.Before(collected[j].PublishAt)
+	})
+
+	return collected[:min(limit, len(collected))]
+}
+
+func (s *memoryScheduleStore) Delete(ctx context.Context, eventID uuid.UUID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.scheduled[eventID]; !ok {
+		return ErrScheduledEventNotFound
+	}
+
+	delete(s.scheduled, eventID)
+	delete(s.claimedUntil, eventID)
+
+	return nil
+}
+
+// PublishAt stores newEvent in the schedule store, and publishes it once t
+// comes, with the envelope it has now. Cancel it with CancelScheduled and the
+// id of newEvent.
+//
+// Like Publish, it fails if newEvent can not be published. An event that was
+// due while no instance of the server was running is published once one
+// starts.
+func (e *eventEngine) PublishAt(t time.Time, newEvent *event.Event) error {
+	if err := e.prepare(newEvent); err != nil {
+		return err
+	}
+
+	rawPayload, err := json.Marshal(newEvent.Payload)
+	if err != nil {
+		return fmt.Errorf(
+			"failed to marshal payload of event %s for the schedule store: %w",
+			newEvent,
+			err,
+		)
+	}
+
+	scheduledEvent := &ScheduledEvent{
+		EventID:       newEvent.ID,
+		EventName:     newEvent.Name,
+		EventVersion:  newEvent.Version,
+		OccurredAt:    newEvent.OccurredAt,
+		CorrelationID: newEvent.CorrelationID,
+		CausationID:   newEvent.CausationID,
+		Producer:      newEvent.Producer,
+		Payload:       rawPayload,
+		PublishAt:     t.UTC(),
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), (10 * time.Second))
+	defer cancel()
+
+	if err := e.ScheduleStore.Add(ctx, scheduledEvent); err != nil {
+		return fmt.Errorf(
+			"failed to schedule event %s: %w",
+			newEvent,
+			err,
+		)
+	}
+
+	e.wheel.add(newEvent.ID, time.Until(t))
+	log.Printf("scheduled event %s for %s\n", newEvent, scheduledEvent.PublishAt.Format(time.RFC3339))
+
+	return nil
+}
+
+// PublishAfter is PublishAt(time.Now().Add(delay), newEvent).
+func (e *eventEngine) PublishAfter(delay time.Duration, newEvent *event.Event) error {
+	return e.PublishAt(time.Now().Add(delay), newEvent)
+}
+
+// CancelScheduled removes the scheduled event with eventID from the schedule
+// store, so it is not published. It returns ErrScheduledEventNotFound if the
+// event is not scheduled, e.g. because it was published already.
+func (e *eventEngine) CancelScheduled(ctx context.Context, eventID uuid.UUID) error {
+	if err := e.ScheduleStore.Delete(ctx, eventID); err != nil {
+		return err
+	}
+
+	e.wheel.remove(eventID)
+
+	return nil
+}
+
+// dispatchScheduled publishes the scheduled events once they are due, until
+// the event engine shuts down. The timer wheel wakes it up when an event is
+// due, and the schedule store is read every schedulePollInterval for the
+// events due soon that the wheel does not know about, i.e. those that were
+// due while the server was down and those scheduled by other instances.
+//
+// The schedule store is first read one schedulePollInterval after the event
+// engine started rather than right away, so the events missed while the
+// server was down are published once the features subscribed again.
+func (e *eventEngine) dispatchScheduled() {
+	defer close(e.dispatcherDoneCh)
+
+	tick := time.NewTicker(scheduleTick)
+	defer tick.Stop()
+
+	poll := time.NewTicker(schedulePollInterval)
+	defer poll.Stop()
+
+	for {
+		select {
+		case <-e.DoneCh:
+			return
+
+		case <-poll.C:
+			e.loadUpcoming()
+
+		case <-tick.C:
+			if due := e.wheel.advance(); len(due) > 0 {
+				e.publishDue()
+			}
+		}
+	}
+}
+
+// loadUpcoming adds the events due within scheduleHorizon to the timer wheel.
+func (e *eventEngine) loadUpcoming() {
+	ctx, cancel := context.WithTimeout(e.ctx, (10 * time.Second))
+	defer cancel()
+
+	upcoming, err := e.ScheduleStore.Upcoming(ctx, time.Now().Add(scheduleHorizon), 1000)
+	if err != nil {
+		log.Printf("failed to read upcoming scheduled events: %v\n", err)
+		return
+	}
+
+	for _, scheduledEvent := range upcoming {
+		if !e.wheel.has(scheduledEvent.EventID) {
+			e.wheel.add(scheduledEvent.EventID, time.Until(scheduledEvent.PublishAt))
+		}
+	}
+}
+
+// publishDue claims the events that are due and publishes them.
+func (e *eventEngine) publishDue() {
+	ctx, cancel := context.WithTimeout(e.ctx, (30 * time.Second))
+	defer cancel()
+
+	for {
+		due, err := e.ScheduleStore.Claim(ctx, time.Now().UTC(), scheduleClaimTTL, scheduleBatchSize)
+		if err != nil {
+			log.Printf("failed to claim due scheduled events: %v\n", err)
+			return
+		}
+
+		for _, scheduledEvent := range due {
+			e.publishScheduled(ctx, scheduledEvent)
+		}
+
+		if len(due) < scheduleBatchSize {
+			return
+		}
+	}
+}
+
+// publishScheduled publishes scheduledEvent and removes it from the schedule
+// store. If publishing fails, it is published again once its claim expired.
+func (e *eventEngine) publishScheduled(ctx context.Context, scheduledEvent *ScheduledEvent) {
+	e.mu.RLock()
+	boundType := e.payloadTypes[scheduledEvent.EventName]
+	e.mu.RUnlock()
+
+	payload, version, err := e.decodePayload(scheduledEvent.EventName, scheduledEvent.EventVersion, scheduledEvent.Payload, boundType)
+	if err != nil {
+		log.Printf("failed to decode payload of scheduled event '%s': %v\n", scheduledEvent.EventID, err)
+		return
+	}
+
+	newEvent := scheduledEvent.event(payload)
+	newEvent.Version = version
+	if err := e.Publish(newEvent); err != nil {
+		log.Printf("failed to publish scheduled event %s, retrying in %v: %v\n", newEvent, scheduleClaimTTL, err)
+		return
+	}
+
+	err = e.ScheduleStore.Delete(ctx, scheduledEvent.EventID)
+	if err != nil && !errors.Is(err, ErrScheduledEventNotFound) {
+		log.Printf("failed to remove published scheduled event %s: %v\n", newEvent, err)
+	}
+}
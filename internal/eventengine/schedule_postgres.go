@@ -0,0 +1,171 @@
+package eventengine
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	scheduledEventFields = "event_id, event_name, event_version, occurred_at, correlation_id, causation_id, producer, payload, publish_at, created_at"
+)
+
+type postgresScheduleStore struct {
+	db *sql.DB
+}
+
+// NewPostgresScheduleStore returns a [ScheduleStore] backed by the
+// scheduled_events table, so scheduled events survive restarts and are
+// published by whichever instance of the server claims them first.
+func NewPostgresScheduleStore(db *sql.DB) ScheduleStore {
+	return &postgresScheduleStore{
+		db: db,
+	}
+}
+
+func (s *postgresScheduleStore) Add(ctx context.Context, scheduledEvent *ScheduledEvent) error {
+	err := s.db.QueryRowContext(
+		ctx,
+		`INSERT INTO scheduled_events(event_id, event_name, event_version, occurred_at, correlation_id, causation_id, producer, payload, publish_at)
+		VALUES($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		ON CONFLICT (event_id) DO UPDATE SET payload = EXCLUDED.payload, publish_at = EXCLUDED.publish_at, claimed_until = NULL
+		RETURNING created_at`,
+		scheduledEvent.EventID,
+		scheduledEvent.EventName,
+		scheduledEvent.EventVersion,
+		scheduledEvent.OccurredAt,
+		scheduledEvent.CorrelationID,
+		scheduledEvent.CausationID,
+		scheduledEvent.Producer,
+		[]byte(scheduledEvent.Payload),
+		scheduledEvent.PublishAt,
+	).Scan(
+		&scheduledEvent.CreatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf(
+			"failed to insert scheduled event in schedule store: %w",
+			err,
+		)
+	}
+
+	return nil
+}
+
+func (s *postgresScheduleStore) Upcoming(ctx context.Context, until time.Time, limit int) ([]*ScheduledEvent, error) {
+	return s.query(
+		ctx,
+		fmt.Sprintf(
+			"SELECT %s FROM scheduled_events WHERE publish_at < $1 ORDER BY publish_at LIMIT $2",
+			scheduledEventFields,
+		),
+		until,
+		limit,
+	)
+}
+
+func (s *postgresScheduleStore) Claim(ctx context.Context, now time.Time, claimTTL time.Duration, limit int) ([]*ScheduledEvent, error) {
+	// SKIP LOCKED lets several instances claim due events at the same time
+	// without claiming the same ones.
+	claimed, err := s.query(
+		ctx,
+		fmt.Sprintf(
+			`UPDATE scheduled_events SET claimed_until = $2
+			WHERE event_id IN (
+				SELECT event_id FROM scheduled_events
+				WHERE publish_at <= $1 AND (claimed_until IS NULL OR claimed_until <= $1)
+				ORDER BY publish_at
+				LIMIT $3
+				FOR UPDATE SKIP LOCKED
+			)
+			RETURNING %s`,
+			scheduledEventFields,
+		),
+		now,
+		now.Add(claimTTL),
+		limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	// RETURNING does not keep the order of the sub query.
+	sort.Slice(claimed, func(i, j int) bool {
+		return claimed[i].PublishAt.Before(claimed[j].PublishAt)
+	})
+
+	return claimed, nil
+}
+
+func (s *postgresScheduleStore) query(ctx context.Context, query string, args ...any) ([]*ScheduledEvent, error) {
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf(
+			"failed to query scheduled events in schedule store: %w",
+			err,
+		)
+	}
+	defer rows.Close()
+
+	scheduledEvents := []*ScheduledEvent{}
+	for rows.Next() {
+		scheduledEvent := new(ScheduledEvent)
+		var payload []byte
+
+		err := rows.Scan(
+			&scheduledEvent.EventID,
+			&scheduledEvent.EventName,
+			&scheduledEvent.EventVersion,
+			&scheduledEvent.OccurredAt,
+			&scheduledEvent.CorrelationID,
+			&scheduledEvent.CausationID,
+			&scheduledEvent.Producer,
+			&payload,
+			&scheduledEvent.PublishAt,
+			&scheduledEvent.CreatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf(
+				"failed to scan row into scheduled event in schedule store: %w",
+				err,
+			)
+		}
+		scheduledEvent.Payload = payload
+
+		scheduledEvents = append(scheduledEvents, scheduledEvent)
+	}
+
+	return scheduledEvents, rows.Err()
+}
+
+func (s *postgresScheduleStore) Delete(ctx context.Context, eventID uuid.UUID) error {
+	result, err := s.db.ExecContext(
+		ctx,
+		"DELETE FROM scheduled_events WHERE event_id = $1",
+		eventID,
+	)
+	if err != nil {
+		return fmt.Errorf(
+			"failed to delete scheduled event in schedule store: %w",
+			err,
+		)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf(
+			"failed to delete scheduled event in schedule store: %w",
+			err,
+		)
+	}
+
+	if rowsAffected == 0 {
+		return ErrScheduledEventNotFound
+	}
+
+	return nil
+}
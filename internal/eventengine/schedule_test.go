@@ -0,0 +1,145 @@
+package eventengine
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/eng-by-sjb/yellow-pines-e-commerce-backend/internal/eventengine/event"
+	"github.com/google/uuid"
+)
+
+func Test_timerWheel(t *testing.T) {
+	const slotCount = 4
+	wheel := newTimerWheel(time.Second, slotCount)
+
+	now, soon, later, nextRound, cancelled := uuid.New(), uuid.New(), uuid.New(), uuid.New(), uuid.New()
+	wheel.add(now, -time.Second)
+	wheel.add(soon, 1500*time.Millisecond)
+	wheel.add(later, 3*time.Second)
+	wheel.add(nextRound, (slotCount+2)*time.Second)
+	wheel.add(cancelled, 3*time.Second)
+	wheel.remove(cancelled)
+
+	expected := map[int][]uuid.UUID{
+		1:             {now},
+		2:             {soon},
+		3:             {later},
+		slotCount + 2: {nextRound},
+	}
+
+	for tick := 1; tick <= 2*slotCount; tick++ {
+		due := wheel.advance()
+		if len(due) != len(expected[tick]) || (len(due) == 1 && due[0] != expected[tick][0]) {
+			t.Fatalf("expected %v to be due on tick %d, got: %v", expected[tick], tick, due)
+		}
+	}
+
+	if wheel.has(cancelled) {
+		t.Fatal("expected the removed timer not to be pending")
+	}
+}
+
+func Test_PublishAt(t *testing.T) {
+	type testPayload struct {
+		Value int
+	}
+
+	doneCh := make(chan struct{})
+	InternalSrvWG := sync.WaitGroup{}
+	scheduleStore := NewMemoryScheduleStore()
+
+	engine := NewEventEngine(
+		&EventEngineConfig{
+			DoneCh:        doneCh,
+			InternalSrvWG: &InternalSrvWG,
+			ScheduleStore: scheduleStore,
+		},
+	)
+
+	var eventName event.EventName = "test.event.engine.scheduled"
+	engine.RegisterEvents(eventName)
+
+	handledCh := make(chan int, 10)
+	_, err := Subscribe(
+		engine,
+		eventName,
+		&SubscriptionConfig{SubscriberName: "test_subscriber_name"},
+		func(_ context.Context, payload *testPayload) error {
+			handledCh <- payload.Value
+			return nil
+		},
+	)
+	if err != nil {
+		t.Fatalf("expected to subscribe, got: %v", err)
+	}
+
+	newEvent := func(value int) *event.Event {
+		return event.New(context.Background(), "test_producer", eventName, &testPayload{Value: value})
+	}
+
+	published := time.Now()
+	if err := engine.PublishAfter(300*time.Millisecond, newEvent(1)); err != nil {
+		t.Fatalf("expected to schedule, got: %v", err)
+	}
+
+	cancelled := newEvent(2)
+	if err := engine.PublishAfter(200*time.Millisecond, cancelled); err != nil {
+		t.Fatalf("expected to schedule, got: %v", err)
+	}
+	if err := engine.CancelScheduled(context.Background(), cancelled.ID); err != nil {
+		t.Fatalf("expected to cancel, got: %v", err)
+	}
+
+	select {
+	case value := <-handledCh:
+		if value != 1 {
+			t.Fatalf("expected the cancelled event not to be published, got: %d", value)
+		}
+		if elapsed := time.Since(published); elapsed < 300*time.Millisecond {
+			t.Fatalf("expected the event to be published after 300ms, got: %v", elapsed)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected the scheduled event to be published")
+	}
+
+	if err := engine.CancelScheduled(context.Background(), cancelled.ID); !errors.Is(err, ErrScheduledEventNotFound) {
+		t.Fatalf("expected '%v' cancelling twice, got: %v", ErrScheduledEventNotFound, err)
+	}
+
+	// an event that was due while no event engine was running, e.g. before a
+	// restart, is published once the schedule store is read.
+	missed := newEvent(3)
+	err = scheduleStore.Add(context.Background(), &ScheduledEvent{
+		EventID:   missed.ID,
+		EventName: missed.Name,
+		Payload:   []byte(`{"Value":3}`),
+		PublishAt: time.Now().Add(-time.Hour),
+	})
+	if err != nil {
+		t.Fatalf("expected to add to the schedule store, got: %v", err)
+	}
+	engine.(*eventEngine).loadUpcoming()
+
+	select {
+	case value := <-handledCh:
+		if value != 3 {
+			t.Fatalf("expected the missed event to be published, got: %d", value)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected the missed event to be published")
+	}
+
+	if upcoming, _ := scheduleStore.Upcoming(context.Background(), time.Now().Add(time.Hour), 10); len(upcoming) != 0 {
+		t.Fatalf("expected published events to be removed from the schedule store, got: %d", len(upcoming))
+	}
+
+	close(doneCh)
+	InternalSrvWG.Wait()
+
+	if len(handledCh) != 0 {
+		t.Fatal("expected no other event to be published")
+	}
+}
@@ -0,0 +1,66 @@
+package eventengine
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+
+	"github.com/eng-by-sjb/yellow-pines-e-commerce-backend/internal/eventengine/event"
+)
+
+// schemaOf returns the current version of the schema of eventName and its
+// payload type. It reports false if the event engine has no Schemas or they
+// do not know eventName.
+func (e *eventEngine) schemaOf(eventName event.EventName) (uint16, reflect.Type, bool) {
+	if e.Schemas == nil {
+		return 0, nil, false
+	}
+
+	return e.Schemas.Current(eventName)
+}
+
+// decodePayload decodes raw, the JSON of a payload of version of eventName,
+// e.g. read from a store or received from another instance.
+//
+// If the Schemas of the event engine know eventName, raw is upcast to the
+// current version of its schema first, and decoded into the payload type of
+// that version, which must be assignable to payloadType. Otherwise it is
+// decoded into payloadType, or left as JSON if payloadType is nil.
+//
+// It returns the payload together with its version.
+func (e *eventEngine) decodePayload(
+	eventName event.EventName,
+	version uint16,
+	raw json.RawMessage,
+	payloadType reflect.Type,
+) (any, uint16, error) {
+	if _, _, ok := e.schemaOf(eventName); ok {
+		payload, current, err := e.Schemas.Decode(eventName, version, raw)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		if payloadType != nil && !reflect.TypeOf(payload).AssignableTo(payloadType) {
+			return nil, 0, fmt.Errorf(
+				"%w: the schema of event '%v' decodes into '%T' but '%v' was expected",
+				ErrPayloadTypeMismatch,
+				eventName,
+				payload,
+				payloadType,
+			)
+		}
+
+		return payload, current, nil
+	}
+
+	if payloadType == nil {
+		return raw, version, nil
+	}
+
+	payload, err := event.UnmarshalPayload(raw, payloadType)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return payload, version, nil
+}
@@ -0,0 +1,119 @@
+package eventengine
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/eng-by-sjb/yellow-pines-e-commerce-backend/internal/eventengine/event"
+	"github.com/google/uuid"
+)
+
+func Test_schemas(t *testing.T) {
+	type testPayload struct {
+		Value    int    `json:"value"`
+		Currency string `json:"currency"`
+	}
+
+	var eventName event.EventName = "test.event.engine.schema"
+
+	schemas := event.NewSchemaRegistry()
+	if err := event.RegisterSchema[*testPayload](schemas, eventName, 2); err != nil {
+		t.Fatalf("expected to register the schema, got: %v", err)
+	}
+	err := schemas.RegisterUpcaster(eventName, 1, func(raw json.RawMessage) (json.RawMessage, error) {
+		var fields map[string]any
+		if err := json.Unmarshal(raw, &fields); err != nil {
+			return nil, err
+		}
+		fields["currency"] = "USD"
+		return json.Marshal(fields)
+	})
+	if err != nil {
+		t.Fatalf("expected to register the upcaster, got: %v", err)
+	}
+
+	// an event recorded by an older version of the server.
+	store := NewMemoryEventStore()
+	err = store.Append(context.Background(), &EventRecord{
+		EventID:      uuid.New(),
+		EventName:    eventName,
+		EventVersion: 1,
+		OccurredAt:   time.Now().UTC(),
+		Payload:      json.RawMessage(`{"value":1}`),
+	})
+	if err != nil {
+		t.Fatalf("expected to append the record, got: %v", err)
+	}
+
+	doneCh := make(chan struct{})
+	InternalSrvWG := sync.WaitGroup{}
+
+	engine := NewEventEngine(
+		&EventEngineConfig{
+			DoneCh:        doneCh,
+			InternalSrvWG: &InternalSrvWG,
+			EventStore:    store,
+			Schemas:       schemas,
+		},
+	)
+	defer func() {
+		close(doneCh)
+		InternalSrvWG.Wait()
+	}()
+	engine.RegisterEvents(eventName)
+
+	_, err = Subscribe(
+		engine,
+		eventName,
+		&SubscriptionConfig{SubscriberName: "test_subscriber_name.schema.mismatch"},
+		func(context.Context, *struct{}) error { return nil },
+	)
+	if !errors.Is(err, ErrPayloadTypeMismatch) {
+		t.Fatalf("expected subscribing with another payload type than the schema to fail with '%v', got: %v", ErrPayloadTypeMismatch, err)
+	}
+
+	type handled struct {
+		version uint16
+		payload *testPayload
+	}
+	handledCh := make(chan handled, 2)
+	_, err = Subscribe(
+		engine,
+		eventName,
+		&SubscriptionConfig{
+			SubscriberName: "test_subscriber_name.schema",
+			Durable:        true,
+		},
+		func(ctx context.Context, payload *testPayload) error {
+			handledEvent, _ := event.FromContext(ctx)
+			handledCh <- handled{version: handledEvent.Version, payload: payload}
+			return nil
+		},
+	)
+	if err != nil {
+		t.Fatalf("expected to subscribe, got: %v", err)
+	}
+
+	if err := engine.Publish(event.New(context.Background(), "test_producer", eventName, &testPayload{Value: 2, Currency: "EUR"})); err != nil {
+		t.Fatalf("expected to publish, got: %v", err)
+	}
+
+	expected := []handled{
+		{version: 2, payload: &testPayload{Value: 1, Currency: "USD"}}, // replayed and upcast.
+		{version: 2, payload: &testPayload{Value: 2, Currency: "EUR"}},
+	}
+	for _, want := range expected {
+		select {
+		case got := <-handledCh:
+			if got.version != want.version || *got.payload != *want.payload {
+				t.Fatalf("expected to handle v%d %+v, got: v%d %+v", want.version, want.payload, got.version, got.payload)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("expected to handle %+v", want.payload)
+		}
+	}
+}
@@ -0,0 +1,195 @@
+package eventengine
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+
+	"github.com/eng-by-sjb/yellow-pines-e-commerce-backend/internal/eventengine/event"
+)
+
+// ErrSubscriptionNotFound is returned when unsubscribing a subscriber that is
+// not subscribed to the event, e.g. because it already unsubscribed.
+var ErrSubscriptionNotFound = errors.New("subscription not found")
+
+// CancelFunc unsubscribes the subscription it was returned for. It returns
+// once the deliveries to the subscriber that were in flight finished and its
+// addressCh was closed.
+//
+// Calling it more than once, or after the event engine shut down, does
+// nothing.
+type CancelFunc func()
+
+// addressChRef counts the subscriptions an addressCh is used by, as the same
+// addressCh can subscribe to several events. The addressCh is closed once the
+// last of them is torn down.
+type addressChRef struct {
+	subscriptions int
+	teardowns     sync.WaitGroup // subscriptions removed from the event engine but still finishing their deliveries.
+}
+
+// removedSubscriber is a subscriber removed from the event engine whose
+// addressCh still has to be torn down.
+type removedSubscriber struct {
+	*subscriber
+	addressChRef  *addressChRef
+	lastReference bool
+}
+
+// addSubscriber adds newSubscriber to the subscribers of toEventName in
+// subscriptions. The caller must hold e.mu.
+func (e *eventEngine) addSubscriber(
+	subscriptions map[event.EventName]*subscribers,
+	toEventName event.EventName,
+	newSubscriber *subscriber,
+) {
+	if e.addressChs == nil {
+		e.addressChs = make(map[chan *event.Event]*addressChRef)
+	}
+
+	ref, ok := e.addressChs[newSubscriber.addressCh]
+	if !ok {
+		ref = &addressChRef{}
+		e.addressChs[newSubscriber.addressCh] = ref
+	}
+	ref.subscriptions++
+
+	subscriptions[toEventName].list = append(
+		subscriptions[toEventName].list,
+		newSubscriber,
+	)
+}
+
+// cancelFunc returns the [CancelFunc] of the subscription of s to
+// toEventName.
+func (e *eventEngine) cancelFunc(toEventName event.EventName, s *subscriber) CancelFunc {
+	var once sync.Once
+
+	return func() {
+		once.Do(func() {
+			removed := e.removeSubscribers(func(subscribedTo event.EventName, other *subscriber) bool {
+				return subscribedTo == toEventName && other == s
+			})
+
+			e.teardown(removed)
+		})
+	}
+}
+
+// Unsubscribe removes every subscription of subscriberName to toEventName,
+// which can be an event name or a pattern. Like a [CancelFunc], it returns
+// once the deliveries to the subscriber that were in flight finished and its
+// addressCh was closed.
+//
+// A raw subscriber (see Subscribe) must keep reading its addressCh until it is
+// closed, otherwise a delivery that is in flight can not finish.
+func (e *eventEngine) Unsubscribe(toEventName event.EventName, subscriberName event.SubscriberName) error {
+	removed := e.removeSubscribers(func(subscribedTo event.EventName, s *subscriber) bool {
+		return subscribedTo == toEventName && s.name == subscriberName
+	})
+	if len(removed) == 0 {
+		return fmt.Errorf(
+			"%w: subscriber '%v' is not subscribed to event '%v'",
+			ErrSubscriptionNotFound,
+			subscriberName,
+			toEventName,
+		)
+	}
+
+	e.teardown(removed)
+
+	log.Printf("%s unsubscribed from %s\n", subscriberName, toEventName)
+
+	return nil
+}
+
+// removeSubscribers removes the subscribers of every event and pattern
+// shouldRemove reports true for, together with their handlers, so they do not
+// receive any more events.
+func (e *eventEngine) removeSubscribers(
+	shouldRemove func(toEventName event.EventName, s *subscriber) bool,
+) []*removedSubscriber {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	var removed []*removedSubscriber
+	for _, subscriptions := range []map[event.EventName]*subscribers{e.events, e.patterns} {
+		for toEventName, subscribers := range subscriptions {
+			kept := subscribers.list[:0:0]
+
+			for _, s := range subscribers.list {
+				if !shouldRemove(toEventName, s) {
+					kept = append(kept, s)
+					continue
+				}
+
+				removed = append(removed, e.releaseAddressCh(s))
+				e.removeHandler(toEventName, s)
+			}
+
+			// the broadcaster may still be delivering to a previous list, so
+			// it is replaced rather than modified in place.
+			subscribers.list = kept
+		}
+	}
+
+	return removed
+}
+
+// releaseAddressCh drops the reference s holds to its addressCh. The caller
+// must hold e.mu.
+func (e *eventEngine) releaseAddressCh(s *subscriber) *removedSubscriber {
+	ref := e.addressChs[s.addressCh]
+	ref.subscriptions--
+	ref.teardowns.Add(1)
+
+	lastReference := ref.subscriptions == 0
+	if lastReference {
+		delete(e.addressChs, s.addressCh)
+	}
+
+	return &removedSubscriber{
+		subscriber:    s,
+		addressChRef:  ref,
+		lastReference: lastReference,
+	}
+}
+
+// removeHandler removes the handler of s for toEventName, if s is a handler,
+// and unbinds the payload type of toEventName once no handler is left for it.
+// The caller must hold e.mu.
+func (e *eventEngine) removeHandler(toEventName event.EventName, s *subscriber) {
+	key := handlerKey{
+		eventName:      toEventName,
+		subscriberName: s.name,
+	}
+	if _, ok := e.handlers[key]; !ok {
+		return
+	}
+	delete(e.handlers, key)
+
+	for key := range e.handlers {
+		if key.eventName == toEventName {
+			return
+		}
+	}
+	delete(e.payloadTypes, toEventName)
+}
+
+// teardown waits for the deliveries in flight to the removed subscribers and
+// closes their addressChs once no subscription uses them anymore.
+func (e *eventEngine) teardown(removed []*removedSubscriber) {
+	for _, s := range removed {
+		s.inFlight.Wait()
+		s.close()
+		s.addressChRef.teardowns.Done()
+
+		if s.lastReference {
+			// other subscriptions of the same addressCh may still be
+			// finishing their deliveries.
+			s.addressChRef.teardowns.Wait()
+			close(s.addressCh)
+		}
+	}
+}
@@ -0,0 +1,211 @@
+package eventengine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/eng-by-sjb/yellow-pines-e-commerce-backend/internal/eventengine/event"
+)
+
+func Test_Unsubscribe(t *testing.T) {
+	type testPayload struct {
+		Value int
+	}
+
+	doneCh := make(chan struct{})
+	InternalSrvWG := sync.WaitGroup{}
+
+	engine := NewEventEngine(
+		&EventEngineConfig{
+			DoneCh:        doneCh,
+			InternalSrvWG: &InternalSrvWG,
+		},
+	)
+
+	var eventName event.EventName = "test.event.engine.unsubscribe"
+	engine.RegisterEvents(eventName)
+
+	handledCh := make(chan int, 10)
+	cancel, err := Subscribe(
+		engine,
+		eventName,
+		&SubscriptionConfig{SubscriberName: "test_subscriber_name.handler"},
+		func(_ context.Context, payload *testPayload) error {
+			handledCh <- payload.Value
+			return nil
+		},
+	)
+	if err != nil {
+		t.Fatalf("expected to subscribe, got: %v", err)
+	}
+
+	rawAddressCh := make(chan *event.Event, 10)
+	_, err = engine.Subscribe(
+		eventName,
+		&event.Subscriber{
+			Name:      "test_subscriber_name.raw",
+			AddressCh: rawAddressCh,
+		},
+	)
+	if err != nil {
+		t.Fatalf("expected to subscribe, got: %v", err)
+	}
+
+	if err := engine.Publish(event.New(context.Background(), "test_producer", eventName, &testPayload{Value: 1})); err != nil {
+		t.Fatalf("expected to publish, got: %v", err)
+	}
+
+	select {
+	case value := <-handledCh:
+		if value != 1 {
+			t.Fatalf("expected to handle 1, got: %d", value)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected the handler to handle the event")
+	}
+
+	cancel()
+	cancel() // calling it again does nothing.
+
+	err = engine.Unsubscribe(eventName, "test_subscriber_name.raw")
+	if err != nil {
+		t.Fatalf("expected to unsubscribe, got: %v", err)
+	}
+
+	// the raw addressCh is closed after the event delivered before
+	// unsubscribing.
+	var received int
+	for range rawAddressCh {
+		received++
+	}
+	if received != 1 {
+		t.Fatalf("expected the raw subscriber to receive 1 event before its addressCh was closed, got: %d", received)
+	}
+
+	err = engine.Unsubscribe(eventName, "test_subscriber_name.raw")
+	if !errors.Is(err, ErrSubscriptionNotFound) {
+		t.Fatalf("expected '%v', got: %v", ErrSubscriptionNotFound, err)
+	}
+
+	if err := engine.Publish(event.New(context.Background(), "test_producer", eventName, &testPayload{Value: 2})); err != nil {
+		t.Fatalf("expected to publish, got: %v", err)
+	}
+
+	select {
+	case value := <-handledCh:
+		t.Fatalf("expected no event after unsubscribing, got: %d", value)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	close(doneCh)
+	InternalSrvWG.Wait()
+
+	_, err = engine.Subscribe(
+		eventName,
+		&event.Subscriber{
+			Name:      "test_subscriber_name.late",
+			AddressCh: make(chan *event.Event),
+		},
+	)
+	if err == nil {
+		t.Fatal("expected subscribing after shutdown to fail")
+	}
+}
+
+// Test_concurrentSubscriptions is meant to be run with -race. It registers,
+// subscribes, publishes and unsubscribes from several go routines while the
+// event engine is running.
+func Test_concurrentSubscriptions(t *testing.T) {
+	doneCh := make(chan struct{})
+	InternalSrvWG := sync.WaitGroup{}
+
+	engine := NewEventEngine(
+		&EventEngineConfig{
+			DoneCh:        doneCh,
+			InternalSrvWG: &InternalSrvWG,
+		},
+	)
+
+	var eventName event.EventName = "test.event.engine.concurrent"
+	engine.RegisterEvents(eventName)
+
+	var publishersWG sync.WaitGroup
+	stopCh := make(chan struct{})
+
+	for i := range 2 {
+		publishersWG.Add(1)
+		go func() {
+			defer publishersWG.Done()
+
+			for {
+				select {
+				case <-stopCh:
+					return
+				default:
+				}
+
+				err := engine.Publish(
+					event.New(context.Background(), "test_producer", eventName, fmt.Sprintf("publisher %d", i)),
+				)
+				if err != nil {
+					t.Errorf("expected to publish, got: %v", err)
+					return
+				}
+			}
+		}()
+	}
+
+	var subscribersWG sync.WaitGroup
+	for i := range 8 {
+		subscribersWG.Add(1)
+		go func() {
+			defer subscribersWG.Done()
+
+			engine.RegisterEvents(event.EventName(fmt.Sprintf("test.event.engine.concurrent.%d", i)))
+
+			for j := range 20 {
+				addressCh := make(chan *event.Event, 1)
+				toEventName := eventName
+				if j%2 == 1 {
+					toEventName = "test.event.engine.>"
+				}
+
+				cancel, err := engine.Subscribe(
+					toEventName,
+					&event.Subscriber{
+						Name:      event.SubscriberName(fmt.Sprintf("test_subscriber_name.%d.%d", i, j)),
+						AddressCh: addressCh,
+					},
+				)
+				if err != nil {
+					t.Errorf("expected to subscribe, got: %v", err)
+					return
+				}
+
+				// read until the addressCh is closed, so deliveries in flight
+				// can finish.
+				readDoneCh := make(chan struct{})
+				go func() {
+					defer close(readDoneCh)
+					for range addressCh {
+					}
+				}()
+
+				engine.SubscriberStats()
+				cancel()
+				<-readDoneCh
+			}
+		}()
+	}
+
+	subscribersWG.Wait()
+	close(stopCh)
+	publishersWG.Wait()
+
+	close(doneCh)
+	InternalSrvWG.Wait()
+}
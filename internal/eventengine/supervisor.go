@@ -0,0 +1,299 @@
+package eventengine
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"github.com/eng-by-sjb/yellow-pines-e-commerce-backend/internal/eventengine/event"
+	"github.com/google/uuid"
+)
+
+// ErrSubscriberFailed is the error the events of a subscriber are dead
+// lettered with once its handler failed, see [HealthFailed].
+var ErrSubscriberFailed = errors.New("subscriber failed after restarting too often")
+
+// HealthStatus is whether the handler of a subscriber is handling events.
+type HealthStatus string
+
+const (
+	HealthRunning    HealthStatus = "running"    // the handler is handling events.
+	HealthRestarting HealthStatus = "restarting" // the handler panicked and waits to be restarted.
+	HealthFailed     HealthStatus = "failed"     // the handler panicked more often than its RestartPolicy allows, and its events are dead lettered.
+)
+
+// RestartPolicy is how often a handler that panicked is restarted. The
+// backoff before a restart grows exponentially from InitialBackoff, and never
+// exceeds MaxBackoff. Once a handler panicked MaxRestarts times within Window
+// it is not restarted anymore.
+type RestartPolicy struct {
+	MaxRestarts    uint16
+	Window         time.Duration
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+}
+
+// DefaultRestartPolicy is used by subscriptions that do not set a
+// RestartPolicy.
+var DefaultRestartPolicy = RestartPolicy{
+	MaxRestarts:    5,
+	Window:         time.Minute,
+	InitialBackoff: 100 * time.Millisecond,
+	MaxBackoff:     10 * time.Second,
+}
+
+// withDefaults returns a copy of p with its zero fields set to the values of
+// [DefaultRestartPolicy].
+func (p *RestartPolicy) withDefaults() *RestartPolicy {
+	if p == nil {
+		policy := DefaultRestartPolicy
+		return &policy
+	}
+
+	policy := *p
+	if policy.MaxRestarts == 0 {
+		policy.MaxRestarts = DefaultRestartPolicy.MaxRestarts
+	}
+
+	if policy.Window <= 0 {
+		policy.Window = DefaultRestartPolicy.Window
+	}
+
+	if policy.InitialBackoff <= 0 {
+		policy.InitialBackoff = DefaultRestartPolicy.InitialBackoff
+	}
+
+	if policy.MaxBackoff < policy.InitialBackoff {
+		policy.MaxBackoff = max(DefaultRestartPolicy.MaxBackoff, policy.InitialBackoff)
+	}
+
+	return &policy
+}
+
+// HandlerPanic is a panic recovered from a handler, together with the event
+// it panicked on.
+type HandlerPanic struct {
+	EventID   uuid.UUID       `json:"eventID"`
+	EventName event.EventName `json:"eventName"`
+	Value     string          `json:"value"`
+	Stack     string          `json:"stack"`
+	At        time.Time       `json:"at"`
+
+	event *event.Event // nil if the panic did not happen while handling an event.
+}
+
+func (p *HandlerPanic) Error() string {
+	return fmt.Sprintf("handler panicked on event '%s' (%s): %s", p.EventID, p.EventName, p.Value)
+}
+
+// recoverHandlerPanic turns a panic of the handler of newEvent into a
+// [HandlerPanic] and panics with it again, so the supervisor knows which
+// event the handler panicked on. It must be deferred.
+func recoverHandlerPanic(newEvent *event.Event) {
+	value := recover()
+	if value == nil {
+		return
+	}
+
+	if _, ok := value.(*HandlerPanic); ok {
+		panic(value)
+	}
+
+	panic(&HandlerPanic{
+		EventID:   newEvent.ID,
+		EventName: newEvent.Name,
+		Value:     fmt.Sprint(value),
+		Stack:     string(debug.Stack()), // still the stack of the panic, as it has not unwound yet.
+		At:        time.Now().UTC(),
+		event:     newEvent,
+	})
+}
+
+// SubscriberHealth is a snapshot of whether the handler of a subscriber of an
+// event is handling events.
+type SubscriberHealth struct {
+	EventName      event.EventName      `json:"eventName"`
+	SubscriberName event.SubscriberName `json:"subscriberName"`
+	Status         HealthStatus         `json:"status"`
+	Restarts       uint64               `json:"restarts"`
+	LastPanic      *HandlerPanic        `json:"lastPanic,omitempty"`
+}
+
+// supervisor keeps the health of a handler, which is shared by its workers.
+type supervisor struct {
+	mu        sync.Mutex
+	policy    *RestartPolicy
+	status    HealthStatus
+	restarts  uint64
+	recent    []time.Time // when the handler panicked within the last policy.Window.
+	lastPanic *HandlerPanic
+}
+
+func newSupervisor(policy *RestartPolicy) *supervisor {
+	return &supervisor{
+		policy: policy,
+		status: HealthRunning,
+	}
+}
+
+// failed reports whether the handler is not restarted anymore.
+func (s *supervisor) failed() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.status == HealthFailed
+}
+
+// panicked records handlerPanic, and returns how long to wait before
+// restarting the handler, or false if it panicked too often to be restarted.
+func (s *supervisor) panicked(handlerPanic *HandlerPanic) (time.Duration, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.lastPanic = handlerPanic
+
+	if s.status == HealthFailed {
+		return 0, false
+	}
+
+	kept := s.recent[:0]
+	for _, at := range s.recent {
+		if handlerPanic.At.Sub(at) < s.policy.Window {
+			kept = append(kept, at)
+		}
+	}
+	s.recent = append(kept, handlerPanic.At)
+
+	if len(s.recent) > int(s.policy.MaxRestarts) {
+		s.status = HealthFailed
+		return 0, false
+	}
+
+	backoff := s.policy.InitialBackoff
+	for range len(s.recent) - 1 {
+		backoff = min(2*backoff, s.policy.MaxBackoff)
+	}
+
+	s.status = HealthRestarting
+	s.restarts++
+
+	return backoff, true
+}
+
+// restarted marks the handler as running again, unless it failed meanwhile.
+func (s *supervisor) restarted() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.status == HealthRestarting {
+		s.status = HealthRunning
+	}
+}
+
+// supervise calls loop until it returns without panicking, restarting it
+// with a backoff after a panic, see [RestartPolicy]. The event the handler
+// panicked on is dead lettered, and the checkpoint of a durable handler moved
+// past it, so it is not handled again after the restart.
+//
+// It returns once loop returned, or the handler failed, after which loop is
+// not called anymore.
+func (e *eventEngine) supervise(toEventName event.EventName, handler *Handler, loop func()) {
+	for {
+		if handler.supervisor.failed() {
+			return
+		}
+
+		handlerPanic := runRecovered(loop)
+		if handlerPanic == nil {
+			return
+		}
+
+		log.Printf(
+			"\033[31m subscriber '%s' of %s panicked: %v\n%s\033[0m\n",
+			handler.SubscriberName,
+			toEventName,
+			handlerPanic,
+			handlerPanic.Stack,
+		)
+
+		if handlerPanic.event != nil {
+			e.deadLetter(handler, handlerPanic.event, 1, handlerPanic)
+			e.acks.done(handlerPanic.event.ID, handler.subscriber, handlerPanic)
+
+			if handler.Durable && handlerPanic.event.Sequence != 0 {
+				e.saveCheckpoint(toEventName, handler, handlerPanic.event.Sequence)
+			}
+		}
+
+		backoff, restart := handler.supervisor.panicked(handlerPanic)
+		if !restart {
+			log.Printf(
+				"\033[31m subscriber '%s' of %s failed, its events are dead lettered from now on\033[0m\n",
+				handler.SubscriberName,
+				toEventName,
+			)
+			return
+		}
+
+		log.Printf("restarting subscriber '%s' of %s in %v\n", handler.SubscriberName, toEventName, backoff)
+
+		// once the event engine shuts down, the handler is restarted right
+		// away to handle the events left.
+		select {
+		case <-e.DoneCh:
+		case <-time.After(backoff):
+		}
+
+		handler.supervisor.restarted()
+	}
+}
+
+// runRecovered calls loop and returns the panic it recovered from loop, if
+// any.
+func runRecovered(loop func()) (handlerPanic *HandlerPanic) {
+	defer func() {
+		value := recover()
+		if value == nil {
+			return
+		}
+
+		var ok bool
+		if handlerPanic, ok = value.(*HandlerPanic); !ok {
+			// the panic did not happen while handling an event.
+			handlerPanic = &HandlerPanic{
+				Value: fmt.Sprint(value),
+				Stack: string(debug.Stack()),
+				At:    time.Now().UTC(),
+			}
+		}
+	}()
+
+	loop()
+
+	return nil
+}
+
+// SubscriberHealth returns the health of the handler of every subscriber that
+// subscribed with SubscribeHandler.
+func (e *eventEngine) SubscriberHealth() []SubscriberHealth {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	health := make([]SubscriberHealth, 0, len(e.handlers))
+	for key, handler := range e.handlers {
+		handler.supervisor.mu.Lock()
+		health = append(health, SubscriberHealth{
+			EventName:      key.eventName,
+			SubscriberName: key.subscriberName,
+			Status:         handler.supervisor.status,
+			Restarts:       handler.supervisor.restarts,
+			LastPanic:      handler.supervisor.lastPanic,
+		})
+		handler.supervisor.mu.Unlock()
+	}
+
+	return health
+}
@@ -0,0 +1,136 @@
+package eventengine
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/eng-by-sjb/yellow-pines-e-commerce-backend/internal/eventengine/event"
+)
+
+func Test_supervise(t *testing.T) {
+	type testPayload struct {
+		Value int
+	}
+
+	doneCh := make(chan struct{})
+	InternalSrvWG := sync.WaitGroup{}
+
+	engine := NewEventEngine(
+		&EventEngineConfig{
+			DoneCh:        doneCh,
+			InternalSrvWG: &InternalSrvWG,
+		},
+	)
+	defer func() {
+		close(doneCh)
+		InternalSrvWG.Wait()
+	}()
+
+	var eventName event.EventName = "test.event.engine.supervised"
+	engine.RegisterEvents(eventName)
+
+	handledCh := make(chan int, 10)
+	_, err := Subscribe(
+		engine,
+		eventName,
+		&SubscriptionConfig{
+			SubscriberName: "test_subscriber_name",
+			Restart: &RestartPolicy{
+				MaxRestarts:    1,
+				InitialBackoff: 10 * time.Millisecond,
+			},
+		},
+		func(_ context.Context, payload *testPayload) error {
+			if payload.Value < 0 {
+				var nilPayload *testPayload
+				_ = nilPayload.Value // panics with a nil dereference.
+			}
+
+			handledCh <- payload.Value
+			return nil
+		},
+	)
+	if err != nil {
+		t.Fatalf("expected to subscribe, got: %v", err)
+	}
+
+	publish := func(value int) {
+		t.Helper()
+
+		if err := engine.Publish(event.New(context.Background(), "test_producer", eventName, &testPayload{Value: value})); err != nil {
+			t.Fatalf("expected to publish, got: %v", err)
+		}
+	}
+
+	health := func() SubscriberHealth {
+		t.Helper()
+
+		health := engine.SubscriberHealth()
+		if len(health) != 1 {
+			t.Fatalf("expected the health of 1 subscriber, got: %d", len(health))
+		}
+		return health[0]
+	}
+
+	awaitHandled := func(expected int) {
+		t.Helper()
+
+		select {
+		case value := <-handledCh:
+			if value != expected {
+				t.Fatalf("expected %d to be handled, got: %d", expected, value)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("expected %d to be handled", expected)
+		}
+	}
+
+	awaitDeadLetters := func(count int) []*DeadLetter {
+		t.Helper()
+
+		for deadline := time.Now().Add(2 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+			deadLetters, _ := engine.ListDeadLetters(context.Background())
+			if len(deadLetters) == count {
+				return deadLetters
+			}
+		}
+
+		t.Fatalf("expected %d dead letters", count)
+		return nil
+	}
+
+	// the handler is restarted after a panic, and handles the next events.
+	publish(-1)
+	publish(1)
+	awaitHandled(1)
+
+	deadLetters := awaitDeadLetters(1)
+	if !strings.Contains(deadLetters[0].Error, "nil pointer dereference") {
+		t.Fatalf("expected the panic to be dead lettered, got: %s", deadLetters[0].Error)
+	}
+
+	if health := health(); health.Status != HealthRunning || health.Restarts != 1 || health.LastPanic == nil {
+		t.Fatalf("expected the subscriber to run again after 1 restart, got: %+v", health)
+	}
+
+	// once it panicked more often than its RestartPolicy allows, its events
+	// are dead lettered.
+	publish(-2)
+	publish(2)
+
+	deadLetters = awaitDeadLetters(3)
+	if deadLetters[2].Error != ErrSubscriberFailed.Error() {
+		t.Fatalf("expected the event after the failure to be dead lettered with '%v', got: %s", ErrSubscriberFailed, deadLetters[2].Error)
+	}
+
+	if health := health(); health.Status != HealthFailed {
+		t.Fatalf("expected the subscriber to fail, got: %+v", health)
+	}
+
+	if len(handledCh) != 0 {
+		t.Fatal("expected the failed subscriber not to handle events")
+	}
+}
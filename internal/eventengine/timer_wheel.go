@@ -0,0 +1,105 @@
+package eventengine
+
+import (
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// timerWheel is a hashed timer wheel: a ring of slots the wheel advances
+// through one per tick. A timer due in n ticks goes into the slot n ticks
+// ahead of the current one, and is due once the wheel reached that slot for
+// the n/len(slots)th time, so adding, cancelling and advancing are cheap
+// however many timers there are.
+type timerWheel struct {
+	mu      sync.Mutex
+	tick    time.Duration
+	slots   []map[uuid.UUID]*wheelTimer
+	current int
+	timers  map[uuid.UUID]*wheelTimer
+}
+
+type wheelTimer struct {
+	id     uuid.UUID
+	slot   int
+	rounds int // how many more times the wheel passes the slot before the timer is due.
+}
+
+func newTimerWheel(tick time.Duration, slotCount int) *timerWheel {
+	slots := make([]map[uuid.UUID]*wheelTimer, slotCount)
+	for i := range slots {
+		slots[i] = make(map[uuid.UUID]*wheelTimer)
+	}
+
+	return &timerWheel{
+		tick:   tick,
+		slots:  slots,
+		timers: make(map[uuid.UUID]*wheelTimer),
+	}
+}
+
+// add adds a timer with id that is due after delay, or on the next tick if
+// delay is not positive. It replaces the timer with the same id, if any.
+func (w *timerWheel) add(id uuid.UUID, delay time.Duration) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.removeLocked(id)
+
+	ticks := max(int((delay+w.tick-1)/w.tick), 1)
+	timer := &wheelTimer{
+		id:     id,
+		slot:   (w.current + ticks) % len(w.slots),
+		rounds: (ticks - 1) / len(w.slots),
+	}
+
+	w.slots[timer.slot][id] = timer
+	w.timers[id] = timer
+}
+
+// has reports whether a timer with id is pending.
+func (w *timerWheel) has(id uuid.UUID) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	_, ok := w.timers[id]
+	return ok
+}
+
+func (w *timerWheel) remove(id uuid.UUID) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.removeLocked(id)
+}
+
+func (w *timerWheel) removeLocked(id uuid.UUID) {
+	if timer, ok := w.timers[id]; ok {
+		delete(w.slots[timer.slot], id)
+		delete(w.timers, id)
+	}
+}
+
+// advance moves the wheel on by one tick and returns the ids of the timers
+// that became due, which are removed from the wheel.
+func (w *timerWheel) advance() []uuid.UUID {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.current = (w.current + 1) % len(w.slots)
+
+	var due []uuid.UUID
+	for id, timer := range w.slots[w.current] {
+		if timer.rounds > 0 {
+			timer.rounds--
+			continue
+		}
+
+		due = append(due, id)
+		delete(w.slots[w.current], id)
+		delete(w.timers, id)
+	}
+
+	return due
+}
@@ -0,0 +1,98 @@
+package eventengine
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+
+	"github.com/eng-by-sjb/yellow-pines-e-commerce-backend/internal/eventengine/event"
+)
+
+// Transport carries the events published to an event engine to every event
+// engine sharing the transport, which broadcast them to their subscribers.
+//
+// The default [NewChannelTransport] only reaches the event engine it belongs
+// to. [NewPostgresTransport] also reaches the event engines of the other
+// instances of the server.
+type Transport interface {
+	// Send sends newEvent to every event engine sharing the transport,
+	// including the one it was published to. It gives up once ctx is done.
+	Send(ctx context.Context, newEvent *event.Event) error
+	// SendBatch sends every event of newEvents like Send, or none of them. It
+	// only gives up on ctx before it started sending.
+	SendBatch(ctx context.Context, newEvents []*event.Event) error
+	// Events returns the channel the event engine receives the events to
+	// broadcast from.
+	Events() <-chan *event.Event
+	// Close stops receiving events. The channel Events returns is closed
+	// once it holds every event received before.
+	Close() error
+}
+
+type channelTransport struct {
+	eventsCh chan *event.Event
+}
+
+// NewChannelTransport returns an in-process [Transport] whose events channel
+// has a buffer of bufferSize.
+func NewChannelTransport(bufferSize uint16) Transport {
+	return &channelTransport{
+		eventsCh: make(chan *event.Event, bufferSize),
+	}
+}
+
+func (t *channelTransport) Send(ctx context.Context, newEvent *event.Event) error {
+	select {
+	case t.eventsCh <- newEvent:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("failed to send event %s: %w", newEvent, ctx.Err())
+	}
+}
+
+func (t *channelTransport) SendBatch(ctx context.Context, newEvents []*event.Event) error {
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("failed to send a batch of %d events: %w", len(newEvents), err)
+	}
+
+	// the event engine keeps reading the events channel until every publish
+	// in flight finished, so these sends do not block forever.
+	for _, newEvent := range newEvents {
+		t.eventsCh <- newEvent
+	}
+
+	return nil
+}
+
+func (t *channelTransport) Events() <-chan *event.Event {
+	return t.eventsCh
+}
+
+func (t *channelTransport) Close() error {
+	close(t.eventsCh)
+	return nil
+}
+
+// decodeReceivedPayload decodes the payload of an event received from another
+// instance, which arrives as JSON, see eventEngine.decodePayload. Payloads of
+// event names that are neither bound nor have a schema are left as JSON.
+func (e *eventEngine) decodeReceivedPayload(receivedEvent *event.Event) {
+	rawPayload, ok := receivedEvent.Payload.(json.RawMessage)
+	if !ok {
+		return
+	}
+
+	e.mu.RLock()
+	boundType := e.payloadTypes[receivedEvent.Name]
+	e.mu.RUnlock()
+
+	payload, version, err := e.decodePayload(receivedEvent.Name, receivedEvent.Version, rawPayload, boundType)
+	if err != nil {
+		log.Printf("failed to decode payload of received event %s: %v\n", receivedEvent, err)
+		return
+	}
+
+	receivedEvent.Payload = payload
+	receivedEvent.Version = version
+}
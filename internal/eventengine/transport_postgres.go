@@ -0,0 +1,307 @@
+package eventengine
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/eng-by-sjb/yellow-pines-e-commerce-backend/internal/eventengine/event"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// maxNotifyPayloadSize is the largest payload postgres accepts in a NOTIFY.
+const maxNotifyPayloadSize = 8000
+
+// ErrEventTooLarge is returned when the JSON envelope of an event does not fit
+// in a postgres NOTIFY.
+var ErrEventTooLarge = errors.New("event is too large for the transport")
+
+type PostgresTransportConfig struct {
+	ConnStr    string  // Used to open the connection the transport listens on.
+	DB         *sql.DB // Used to notify the other instances.
+	Channel    string  // Name of the postgres channel. Defaults to "event_engine".
+	BufferSize uint16  // Buffer size of the events channel. Defaults to 20.
+}
+
+type postgresTransport struct {
+	*PostgresTransportConfig
+	local        *channelTransport
+	instanceID   uuid.UUID // Id of this instance, so it ignores the notifications it sent itself.
+	listener     *pq.Listener
+	closeCh      chan struct{}
+	listenDoneCh chan struct{}
+}
+
+// envelope is the JSON an event is sent as to the other instances.
+type envelope struct {
+	InstanceID    uuid.UUID       `json:"instanceID"`
+	ID            uuid.UUID       `json:"id"`
+	Name          event.EventName `json:"name"`
+	Version       uint16          `json:"version"`
+	OccurredAt    time.Time       `json:"occurredAt"`
+	CorrelationID string          `json:"correlationID"`
+	CausationID   uuid.UUID       `json:"causationID"`
+	Producer      string          `json:"producer"`
+	Sequence      uint64          `json:"sequence"`
+	Payload       json.RawMessage `json:"payload"`
+}
+
+// NewPostgresTransport returns a [Transport] that shares events between the
+// instances of the server with postgres LISTEN/NOTIFY, so a subscriber on
+// one instance receives the events published on any of them.
+//
+// Events published on this instance are delivered to it directly. Events
+// received from other instances carry their payload as JSON, which the event
+// engine decodes into the payload type their event name is bound to.
+//
+// Postgres does not queue notifications for a listener that is not
+// connected, so events published while this instance reconnects are missed.
+// Durable subscriptions catch up on them from the event log.
+func NewPostgresTransport(cfg *PostgresTransportConfig) (Transport, error) {
+	if cfg == nil {
+		log.Fatalln("'PostgresTransportConfig' can not be nil")
+	}
+
+	if cfg.DB == nil || cfg.ConnStr == "" {
+		return nil, errors.New("either DB or ConnStr is not set in 'PostgresTransportConfig'")
+	}
+
+	if cfg.Channel == "" {
+		cfg.Channel = "event_engine"
+	}
+
+	if cfg.BufferSize == 0 {
+		cfg.BufferSize = 20
+	}
+
+	t := &postgresTransport{
+		PostgresTransportConfig: cfg,
+		local: &channelTransport{
+			eventsCh: make(chan *event.Event, cfg.BufferSize),
+		},
+		instanceID:   uuid.New(),
+		closeCh:      make(chan struct{}),
+		listenDoneCh: make(chan struct{}),
+	}
+
+	t.listener = pq.NewListener(
+		cfg.ConnStr,
+		(10 * time.Second),
+		time.Minute,
+		func(listenerEvent pq.ListenerEventType, err error) {
+			if err != nil {
+				log.Printf("event engine transport listener on channel '%s': %v\n", cfg.Channel, err)
+			}
+		},
+	)
+
+	if err := t.listener.Listen(cfg.Channel); err != nil {
+		t.listener.Close()
+		return nil, fmt.Errorf(
+			"failed to listen on channel '%s' in postgres transport: %w",
+			cfg.Channel,
+			err,
+		)
+	}
+
+	go t.listen()
+
+	return t, nil
+}
+
+func (t *postgresTransport) Send(ctx context.Context, newEvent *event.Event) error {
+	notification, err := t.marshalEnvelope(newEvent)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, (10 * time.Second))
+	defer cancel()
+
+	_, err = t.DB.ExecContext(ctx, "SELECT pg_notify($1, $2)", t.Channel, string(notification))
+	if err != nil {
+		return fmt.Errorf(
+			"failed to notify event %s in postgres transport: %w",
+			newEvent,
+			err,
+		)
+	}
+
+	// the other instances were notified, so it is delivered here too.
+	return t.local.Send(context.WithoutCancel(ctx), newEvent)
+}
+
+// SendBatch notifies the other instances of newEvents in one transaction,
+// as postgres only delivers the notifications of a transaction once it
+// commits.
+func (t *postgresTransport) SendBatch(ctx context.Context, newEvents []*event.Event) error {
+	notifications := make([]string, 0, len(newEvents))
+	for _, newEvent := range newEvents {
+		notification, err := t.marshalEnvelope(newEvent)
+		if err != nil {
+			return err
+		}
+		notifications = append(notifications, string(notification))
+	}
+
+	notifyCtx, cancel := context.WithTimeout(ctx, (10 * time.Second))
+	defer cancel()
+
+	tx, err := t.DB.BeginTx(notifyCtx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction in postgres transport: %w", err)
+	}
+	defer tx.Rollback()
+
+	for i, notification := range notifications {
+		if _, err := tx.ExecContext(notifyCtx, "SELECT pg_notify($1, $2)", t.Channel, notification); err != nil {
+			return fmt.Errorf(
+				"failed to notify event %s in postgres transport: %w",
+				newEvents[i],
+				err,
+			)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit notifications in postgres transport: %w", err)
+	}
+
+	return t.local.SendBatch(context.WithoutCancel(ctx), newEvents)
+}
+
+func (t *postgresTransport) Events() <-chan *event.Event {
+	return t.local.Events()
+}
+
+func (t *postgresTransport) Close() error {
+	close(t.closeCh)
+	<-t.listenDoneCh
+
+	err := t.listener.Close()
+	t.local.Close()
+
+	return err
+}
+
+// listen forwards the events other instances notify about to the local
+// events channel until the transport is closed.
+func (t *postgresTransport) listen() {
+	defer close(t.listenDoneCh)
+
+	// the listener only notices a broken connection when it is used, so it is
+	// pinged when nothing was received for a while.
+	const pingInterval = 90 * time.Second
+	pingTicker := time.NewTicker(pingInterval)
+	defer pingTicker.Stop()
+
+	for {
+		select {
+		case <-t.closeCh:
+			return
+
+		case notification := <-t.listener.Notify:
+			pingTicker.Reset(pingInterval)
+
+			if notification == nil {
+				log.Printf(
+					"event engine transport reconnected to channel '%s', events other instances published meanwhile were missed\n",
+					t.Channel,
+				)
+				continue
+			}
+
+			receivedEvent, instanceID, err := unmarshalEnvelope([]byte(notification.Extra))
+			if err != nil {
+				log.Printf("failed to unmarshal event received on channel '%s': %v\n", t.Channel, err)
+				continue
+			}
+
+			if instanceID == t.instanceID {
+				continue // delivered directly when it was sent.
+			}
+
+			// the event engine stops reading the events channel once it
+			// closes the transport.
+			select {
+			case t.local.eventsCh <- receivedEvent:
+			case <-t.closeCh:
+				return
+			}
+
+		case <-pingTicker.C:
+			go t.listener.Ping()
+		}
+	}
+}
+
+func (t *postgresTransport) marshalEnvelope(newEvent *event.Event) ([]byte, error) {
+	rawPayload, err := json.Marshal(newEvent.Payload)
+	if err != nil {
+		return nil, fmt.Errorf(
+			"failed to marshal payload of event %s for the postgres transport: %w",
+			newEvent,
+			err,
+		)
+	}
+
+	notification, err := json.Marshal(
+		&envelope{
+			InstanceID:    t.instanceID,
+			ID:            newEvent.ID,
+			Name:          newEvent.Name,
+			Version:       newEvent.Version,
+			OccurredAt:    newEvent.OccurredAt,
+			CorrelationID: newEvent.CorrelationID,
+			CausationID:   newEvent.CausationID,
+			Producer:      newEvent.Producer,
+			Sequence:      newEvent.Sequence,
+			Payload:       rawPayload,
+		},
+	)
+	if err != nil {
+		return nil, fmt.Errorf(
+			"failed to marshal event %s for the postgres transport: %w",
+			newEvent,
+			err,
+		)
+	}
+
+	if len(notification) >= maxNotifyPayloadSize {
+		return nil, fmt.Errorf(
+			"%w: event %s is %d bytes, postgres notifications must be under %d bytes",
+			ErrEventTooLarge,
+			newEvent,
+			len(notification),
+			maxNotifyPayloadSize,
+		)
+	}
+
+	return notification, nil
+}
+
+// unmarshalEnvelope returns the event in notification, whose payload is left
+// as [json.RawMessage], and the id of the instance that sent it.
+func unmarshalEnvelope(notification []byte) (*event.Event, uuid.UUID, error) {
+	var receivedEnvelope envelope
+	if err := json.Unmarshal(notification, &receivedEnvelope); err != nil {
+		return nil, uuid.Nil, err
+	}
+
+	return &event.Event{
+		ID:            receivedEnvelope.ID,
+		Name:          receivedEnvelope.Name,
+		Version:       receivedEnvelope.Version,
+		OccurredAt:    receivedEnvelope.OccurredAt,
+		CorrelationID: receivedEnvelope.CorrelationID,
+		CausationID:   receivedEnvelope.CausationID,
+		Producer:      receivedEnvelope.Producer,
+		Sequence:      receivedEnvelope.Sequence,
+		Payload:       receivedEnvelope.Payload,
+	}, receivedEnvelope.InstanceID, nil
+}
@@ -0,0 +1,111 @@
+package eventengine
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/eng-by-sjb/yellow-pines-e-commerce-backend/internal/eventengine/event"
+	"github.com/google/uuid"
+)
+
+func Test_envelopeJSON(t *testing.T) {
+	type testPayload struct {
+		Value int `json:"value"`
+	}
+
+	transport := &postgresTransport{instanceID: uuid.New()}
+	sentEvent := event.New(context.Background(), "test_producer", "test.event.transport", &testPayload{Value: 1})
+	sentEvent.Sequence = 7
+
+	notification, err := transport.marshalEnvelope(sentEvent)
+	if err != nil {
+		t.Fatalf("expected to marshal envelope, got: %v", err)
+	}
+
+	receivedEvent, instanceID, err := unmarshalEnvelope(notification)
+	if err != nil {
+		t.Fatalf("expected to unmarshal envelope, got: %v", err)
+	}
+
+	if instanceID != transport.instanceID {
+		t.Fatalf("expected instance id %s, got: %s", transport.instanceID, instanceID)
+	}
+
+	if receivedEvent.ID != sentEvent.ID ||
+		receivedEvent.Name != sentEvent.Name ||
+		receivedEvent.Sequence != 7 ||
+		!receivedEvent.OccurredAt.Equal(sentEvent.OccurredAt) ||
+		receivedEvent.CorrelationID != sentEvent.CorrelationID {
+		t.Fatalf("expected envelope %s, got: %s", sentEvent, receivedEvent)
+	}
+
+	if rawPayload, ok := receivedEvent.Payload.(json.RawMessage); !ok || string(rawPayload) != `{"value":1}` {
+		t.Fatalf("expected payload as JSON, got: %#v", receivedEvent.Payload)
+	}
+
+	largeEvent := event.New(context.Background(), "test_producer", "test.event.transport", strings.Repeat("a", maxNotifyPayloadSize))
+	if _, err := transport.marshalEnvelope(largeEvent); !errors.Is(err, ErrEventTooLarge) {
+		t.Fatalf("expected '%v', got: %v", ErrEventTooLarge, err)
+	}
+}
+
+func Test_receivedPayloadIsDecoded(t *testing.T) {
+	type testPayload struct {
+		Value int `json:"value"`
+	}
+
+	doneCh := make(chan struct{})
+	InternalSrvWG := sync.WaitGroup{}
+	transport := NewChannelTransport(1)
+
+	engine := NewEventEngine(
+		&EventEngineConfig{
+			DoneCh:        doneCh,
+			InternalSrvWG: &InternalSrvWG,
+			Transport:     transport,
+		},
+	)
+
+	var eventName event.EventName = "test.event.transport.received"
+	handledCh := make(chan int, 1)
+
+	_, err := Subscribe(
+		engine,
+		eventName,
+		&SubscriptionConfig{SubscriberName: "test_subscriber_name"},
+		func(_ context.Context, payload *testPayload) error {
+			handledCh <- payload.Value
+			return nil
+		},
+	)
+	if err != nil {
+		t.Fatalf("expected to subscribe, got: %v", err)
+	}
+
+	// as if received from another instance.
+	transport.Send(
+		context.Background(),
+		&event.Event{
+			ID:      uuid.New(),
+			Name:    eventName,
+			Payload: json.RawMessage(`{"value":2}`),
+		},
+	)
+
+	select {
+	case value := <-handledCh:
+		if value != 2 {
+			t.Fatalf("expected to handle 2, got: %d", value)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected the handler to handle the received event")
+	}
+
+	close(doneCh)
+	InternalSrvWG.Wait()
+}
@@ -1,29 +1,44 @@
 package admin
 
 import (
+	"bytes"
 	"context"
 	"errors"
+	"io"
 	"net/http"
 	"time"
 
+	"github.com/eng-by-sjb/yellow-pines-e-commerce-backend/internal/eventengine"
 	"github.com/eng-by-sjb/yellow-pines-e-commerce-backend/internal/handlerutils"
 	"github.com/eng-by-sjb/yellow-pines-e-commerce-backend/internal/servererrors"
 	"github.com/go-chi/chi"
+	"github.com/google/uuid"
 )
 
 type servicer interface {
 	// registerAdmin(ctx context.Context, payload *RegisterAdminRequest) error
 	loginAdmin(ctx context.Context, payload *LoginAdminRequest) (*LoginAdminCookiesResponse, error)
 	logoutAdmin(ctx context.Context, refreshToken string) error
+	listDeadLetters(ctx context.Context) ([]*eventengine.DeadLetter, error)
+	getDeadLetter(ctx context.Context, deadLetterID uuid.UUID) (*eventengine.DeadLetter, error)
+	redriveDeadLetter(ctx context.Context, deadLetterID uuid.UUID) error
+	inspectEvents() *eventengine.EventsSnapshot
+	writeEventMetrics(w io.Writer) error
+}
+
+type middleware interface {
+	AuthWithContext(h handlerutils.APIHandler, authEntityType string) handlerutils.APIHandler
 }
 
 type handler struct {
-	service servicer
+	service    servicer
+	middleware middleware
 }
 
-func NewHandler(service servicer) *handler {
+func NewHandler(service servicer, middleware middleware) *handler {
 	return &handler{
-		service: service,
+		service:    service,
+		middleware: middleware,
 	}
 }
 
@@ -36,6 +51,53 @@ func (h *handler) RegisterRoutes(router *chi.Mux) {
 		"/admin/logout",
 		handlerutils.MakeHandler(h.logoutUserHandler),
 	)
+
+	// protected routes
+	router.Get(
+		"/admin/events",
+		handlerutils.MakeHandler(
+			h.middleware.AuthWithContext(
+				h.inspectEventsHandler,
+				"admin",
+			),
+		),
+	)
+	router.Get(
+		"/admin/events/metrics",
+		handlerutils.MakeHandler(
+			h.middleware.AuthWithContext(
+				h.eventMetricsHandler,
+				"admin",
+			),
+		),
+	)
+	router.Get(
+		"/admin/events/dead-letters",
+		handlerutils.MakeHandler(
+			h.middleware.AuthWithContext(
+				h.listDeadLettersHandler,
+				"admin",
+			),
+		),
+	)
+	router.Get(
+		"/admin/events/dead-letters/{deadLetterID}",
+		handlerutils.MakeHandler(
+			h.middleware.AuthWithContext(
+				h.getDeadLetterHandler,
+				"admin",
+			),
+		),
+	)
+	router.Post(
+		"/admin/events/dead-letters/{deadLetterID}/redrive",
+		handlerutils.MakeHandler(
+			h.middleware.AuthWithContext(
+				h.redriveDeadLetterHandler,
+				"admin",
+			),
+		),
+	)
 }
 
 func (h *handler) loginUserHandler(w http.ResponseWriter, r *http.Request) error {
@@ -149,3 +211,124 @@ func (h *handler) logoutUserHandler(w http.ResponseWriter, r *http.Request) erro
 		nil,
 	)
 }
+
+func (h *handler) inspectEventsHandler(w http.ResponseWriter, r *http.Request) error {
+	return handlerutils.WriteSuccessJSON(
+		w,
+		http.StatusOK,
+		"events retrieved",
+		h.service.inspectEvents(),
+	)
+}
+
+// eventMetricsHandler writes the metrics of the event engine in the
+// Prometheus text format, for Prometheus to scrape with the access token of
+// an admin.
+func (h *handler) eventMetricsHandler(w http.ResponseWriter, r *http.Request) error {
+	// the metrics are buffered so a failure can still be reported as such.
+	var metrics bytes.Buffer
+	if err := h.service.writeEventMetrics(&metrics); err != nil {
+		return err
+	}
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	_, err := metrics.WriteTo(w)
+	return err
+}
+
+func (h *handler) listDeadLettersHandler(w http.ResponseWriter, r *http.Request) error {
+	ctx, cancel := context.WithTimeout(
+		r.Context(),
+		(30 * time.Second),
+	)
+	defer cancel()
+
+	deadLetters, err := h.service.listDeadLetters(ctx)
+	if err != nil {
+		return err
+	}
+
+	return handlerutils.WriteSuccessJSON(
+		w,
+		http.StatusOK,
+		"dead letters retrieved",
+		deadLetters,
+	)
+}
+
+func (h *handler) getDeadLetterHandler(w http.ResponseWriter, r *http.Request) error {
+	ctx, cancel := context.WithTimeout(
+		r.Context(),
+		(30 * time.Second),
+	)
+	defer cancel()
+
+	deadLetterID, err := uuid.Parse(chi.URLParam(r, "deadLetterID"))
+	if err != nil {
+		return servererrors.New(
+			http.StatusBadRequest,
+			servererrors.ErrInvalidID.Error(),
+			nil,
+		)
+	}
+
+	deadLetter, err := h.service.getDeadLetter(ctx, deadLetterID)
+	if err != nil {
+		switch {
+		case errors.Is(err, servererrors.ErrDeadLetterNotFound):
+			return servererrors.New(
+				http.StatusNotFound,
+				servererrors.ErrDeadLetterNotFound.Error(),
+				nil,
+			)
+		default:
+			return err
+		}
+	}
+
+	return handlerutils.WriteSuccessJSON(
+		w,
+		http.StatusOK,
+		"dead letter found",
+		deadLetter,
+	)
+}
+
+func (h *handler) redriveDeadLetterHandler(w http.ResponseWriter, r *http.Request) error {
+	ctx, cancel := context.WithTimeout(
+		r.Context(),
+		(30 * time.Second),
+	)
+	defer cancel()
+
+	deadLetterID, err := uuid.Parse(chi.URLParam(r, "deadLetterID"))
+	if err != nil {
+		return servererrors.New(
+			http.StatusBadRequest,
+			servererrors.ErrInvalidID.Error(),
+			nil,
+		)
+	}
+
+	err = h.service.redriveDeadLetter(ctx, deadLetterID)
+	if err != nil {
+		switch {
+		case errors.Is(err, servererrors.ErrDeadLetterNotFound):
+			return servererrors.New(
+				http.StatusNotFound,
+				servererrors.ErrDeadLetterNotFound.Error(),
+				nil,
+			)
+		default:
+			return err
+		}
+	}
+
+	return handlerutils.WriteSuccessJSON(
+		w,
+		http.StatusOK,
+		"dead letter redriven",
+		nil,
+	)
+}
@@ -2,7 +2,10 @@ package admin
 
 import (
 	"context"
+	"errors"
+	"io"
 
+	"github.com/eng-by-sjb/yellow-pines-e-commerce-backend/internal/eventengine"
 	"github.com/eng-by-sjb/yellow-pines-e-commerce-backend/internal/interfaces"
 	"github.com/eng-by-sjb/yellow-pines-e-commerce-backend/internal/servererrors"
 	"github.com/google/uuid"
@@ -18,15 +21,26 @@ type sessionServicer interface {
 	LogoutEntity(ctx context.Context, refreshToken string) error
 }
 
+// eventsManager is the part of the event engine admins manage.
+type eventsManager interface {
+	ListDeadLetters(ctx context.Context) ([]*eventengine.DeadLetter, error)
+	GetDeadLetter(ctx context.Context, deadLetterID uuid.UUID) (*eventengine.DeadLetter, error)
+	RedriveDeadLetter(ctx context.Context, deadLetterID uuid.UUID) error
+	Snapshot() *eventengine.EventsSnapshot
+	WriteMetrics(w io.Writer) error
+}
+
 type service struct {
 	sessionService sessionServicer
 	adminStore     adminStorer
+	eventsManager  eventsManager
 }
 
-func NewService(adminStore adminStorer, sessionService sessionServicer) *service {
+func NewService(adminStore adminStorer, sessionService sessionServicer, eventsManager eventsManager) *service {
 	return &service{
 		adminStore:     adminStore,
 		sessionService: sessionService,
+		eventsManager:  eventsManager,
 	}
 }
 
@@ -70,3 +84,39 @@ func (s *service) loginAdmin(ctx context.Context, payload *LoginAdminRequest) (*
 func (s *service) logoutAdmin(ctx context.Context, refreshToken string) error {
 	return s.sessionService.LogoutEntity(ctx, refreshToken)
 }
+
+func (s *service) listDeadLetters(ctx context.Context) ([]*eventengine.DeadLetter, error) {
+	return s.eventsManager.ListDeadLetters(ctx)
+}
+
+func (s *service) getDeadLetter(ctx context.Context, deadLetterID uuid.UUID) (*eventengine.DeadLetter, error) {
+	deadLetter, err := s.eventsManager.GetDeadLetter(ctx, deadLetterID)
+	if err != nil {
+		if errors.Is(err, eventengine.ErrDeadLetterNotFound) {
+			return nil, servererrors.ErrDeadLetterNotFound
+		}
+		return nil, err
+	}
+
+	return deadLetter, nil
+}
+
+func (s *service) redriveDeadLetter(ctx context.Context, deadLetterID uuid.UUID) error {
+	err := s.eventsManager.RedriveDeadLetter(ctx, deadLetterID)
+	if err != nil {
+		if errors.Is(err, eventengine.ErrDeadLetterNotFound) {
+			return servererrors.ErrDeadLetterNotFound
+		}
+		return err
+	}
+
+	return nil
+}
+
+func (s *service) inspectEvents() *eventengine.EventsSnapshot {
+	return s.eventsManager.Snapshot()
+}
+
+func (s *service) writeEventMetrics(w io.Writer) error {
+	return s.eventsManager.WriteMetrics(w)
+}
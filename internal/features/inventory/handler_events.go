@@ -2,11 +2,14 @@ package inventory
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"log"
-	"sync"
 
 	"github.com/eng-by-sjb/yellow-pines-e-commerce-backend/internal/eventengine"
 	"github.com/eng-by-sjb/yellow-pines-e-commerce-backend/internal/eventengine/event"
+	"github.com/eng-by-sjb/yellow-pines-e-commerce-backend/internal/eventengine/idempotency"
+	"github.com/eng-by-sjb/yellow-pines-e-commerce-backend/internal/servererrors"
 
 	"github.com/google/uuid"
 )
@@ -14,139 +17,232 @@ import (
 // subscriberName is the name of this event handler.
 const subscriberName event.SubscriberName = "handler_event.inventory"
 
+// producerName is the producer of the events the inventory feature publishes.
+const producerName = "inventory"
+
 type servicer interface {
 	createInventory(ctx context.Context, pdID uuid.UUID, stkQty uint) error
+	updateStockQuantity(ctx context.Context, pdID uuid.UUID, stkQty uint) error
+	deleteInventory(ctx context.Context, pdID uuid.UUID) error
 }
 
 type HandlerEventsConfig struct {
-	DoneCh        <-chan struct{}
-	InternalSrvWG *sync.WaitGroup
-	EventEngine   eventengine.SubscribeRegisterPublisher
+	EventEngine   eventengine.Publisher
 	Service       servicer
 	AddressChSize uint16
+	Concurrency   uint16 // How many products have their inventory created at the same time.
 }
 
 type handlerEvent struct {
 	*HandlerEventsConfig
-	addressCh chan any
 }
 
-func NewEventHandler(
+// NewEventsModule returns the events the inventory feature publishes and the
+// handlers of the events it handles, to run with [eventengine.RunModule].
+func NewEventsModule(
 	cfg *HandlerEventsConfig,
-) *handlerEvent {
+) *eventengine.Module {
 	if cfg.AddressChSize == 0 {
 		cfg.AddressChSize = 10
 	}
 
-	if cfg.DoneCh == nil || cfg.InternalSrvWG == nil || cfg.EventEngine == nil || cfg.Service == nil {
+	if cfg.EventEngine == nil || cfg.Service == nil {
 		log.Fatalf(
-			"either 'DoneCh', 'EventEngine', 'InternalSrvWG' or 'Service' is nil in '%s'",
+			"either 'EventEngine' or 'Service' is nil in '%s'",
 			subscriberName,
 		)
 	}
 
 	he := &handlerEvent{
 		HandlerEventsConfig: cfg,
-		addressCh:           make(chan any, cfg.AddressChSize),
 	}
 
-	// Register eventsNames the product service will emit
-	he.registerServiceEvents()
-
-	he.InternalSrvWG.Add(1)
-	go he.listen() // todo: use an errCh to send error to here from listen()
-	// if he.errCh != nil {
-	// 	log.Panic("error listening to events in", subscriberName)
-	// }
-
-	return he
+	return &eventengine.Module{
+		Name:          subscriberName,
+		AddressChSize: cfg.AddressChSize,
+		Emits: []event.EventName{
+			event.InventoryCreatedEventName,
+			event.InventoryCreationFailedEventName,
+		},
+		// If you want to add more subscriptions, add the handler of the new
+		// event here.
+		Handlers: map[event.EventName]*eventengine.Handler{
+			event.ProductCreatedEventName: eventengine.NewHandler(
+				&eventengine.SubscriptionConfig{
+					Concurrency: cfg.Concurrency,
+					OrderingKey: eventengine.OrderBy(func(payload *event.ProductCreatedEvent) string {
+						return payload.ProductID.String()
+					}),
+					OnDeadLetter: he.productCreatedDeadLetterHandler,
+				},
+				he.productCreatedEventHandler,
+			),
+			// the quantity of a product is updated in the order the admins
+			// updated it.
+			event.ProductUpdatedQuantityEventName: eventengine.NewHandler(
+				&eventengine.SubscriptionConfig{
+					Concurrency: cfg.Concurrency,
+					OrderingKey: eventengine.OrderBy(func(payload *event.ProductQuantityUpdatedEvent) string {
+						return payload.ProductID.String()
+					}),
+				},
+				he.productQuantityUpdatedEventHandler,
+			),
+			event.ProductDeletedEventName: eventengine.NewHandler(
+				&eventengine.SubscriptionConfig{
+					Concurrency: cfg.Concurrency,
+					OrderingKey: eventengine.OrderBy(func(payload *event.ProductDeletedEvent) string {
+						return payload.ProductID.String()
+					}),
+				},
+				he.productDeletedEventHandler,
+			),
+		},
+	}
 }
 
-func (h *handlerEvent) listen() {
-	defer h.InternalSrvWG.Done()
-
-	// subscribe to events
-	h.addSubscriptions()
-
-	log.Printf("%s is listening...\n", subscriberName)
+func (h *handlerEvent) productCreatedEventHandler(
+	ctx context.Context,
+	newEvent *event.ProductCreatedEvent,
+) error {
+	err := h.Service.createInventory(
+		ctx,
+		newEvent.ProductID,
+		newEvent.StockQuantity,
+	)
+	switch {
+	case errors.Is(err, idempotency.ErrAlreadyProcessed):
+		// a redelivery of an event whose inventory was created already. The
+		// inventory created event is published again, in case publishing it
+		// failed the first time.
+		log.Printf("skipping creating inventory for product '%s': %v\n", newEvent.ProductID, err)
+
+	case errors.Is(err, servererrors.ErrProductNotFound):
+		// the product was deleted, e.g. by the compensation of its
+		// provisioning saga, before its product.created event was handled.
+		log.Printf("skipping creating inventory for deleted product '%s'\n", newEvent.ProductID)
+		return nil
+
+	case err != nil:
+		return fmt.Errorf(
+			"error creating inventory for product '%s': %w",
+			newEvent.ProductID,
+			err,
+		)
+	}
 
-	for newEvent := range h.addressCh {
-		switch ne := newEvent.(type) {
-		case *event.ProductCreatedEvent:
-			h.productCreatedEventHandler(ne)
+	createdEvent := &event.InventoryCreatedEvent{
+		ProductID: newEvent.ProductID,
+	}
 
-		default:
-			log.Printf(
-				"received unknown event type: %T\n",
-				ne,
-			)
-		}
+	// completes the inventory step of the product provisioning saga.
+	err = h.EventEngine.PublishContext(
+		ctx,
+		event.New(
+			ctx,
+			producerName,
+			createdEvent.GetEventName(),
+			createdEvent,
+		),
+	)
+	if err != nil {
+		log.Printf(
+			"error publishing %s for product '%s': %v\n",
+			createdEvent.GetEventName(),
+			newEvent.ProductID,
+			err,
+		)
 	}
 
-	log.Printf("shutting down %s\n", subscriberName)
+	return nil
 }
 
-func (h *handlerEvent) productCreatedEventHandler(newEvent *event.ProductCreatedEvent) {
-	ctx := context.TODO() // todo: get a proper context
-
-	err := h.Service.createInventory(
+// productQuantityUpdatedEventHandler sets the stock quantity of the product
+// an admin updated the quantity of.
+func (h *handlerEvent) productQuantityUpdatedEventHandler(
+	ctx context.Context,
+	newEvent *event.ProductQuantityUpdatedEvent,
+) error {
+	err := h.Service.updateStockQuantity(
 		ctx,
 		newEvent.ProductID,
 		newEvent.StockQuantity,
 	)
-
-	if err != nil {
-		failedEvent := &event.InventoryCreationFailedEvent{
-			ProductID: newEvent.ProductID,
-		}
-
-		h.EventEngine.Publish(
-			&event.Event{
-				Name:    failedEvent.GetEventName(),
-				Payload: failedEvent,
-			},
+	switch {
+	case errors.Is(err, idempotency.ErrAlreadyProcessed):
+		log.Printf("skipping updating stock quantity of product '%s': %v\n", newEvent.ProductID, err)
+
+	case err != nil:
+		return fmt.Errorf(
+			"error updating stock quantity of product '%s': %w",
+			newEvent.ProductID,
+			err,
 		)
 	}
+
+	return nil
 }
 
-// registerServiceEvents registers eventsNames that this service will be
-// emitting/publishing to for other services to subscribe to.
-func (h *handlerEvent) registerServiceEvents() {
-	// Register eventsNames the product service will emit
-	h.EventEngine.RegisterEvents(
-		event.InventoryCreationFailedEventName,
+// productDeletedEventHandler deletes the inventory of a product once it is
+// purged from the trash. A product moved to the trash keeps its inventory, so
+// it is back in stock when restored.
+func (h *handlerEvent) productDeletedEventHandler(
+	ctx context.Context,
+	newEvent *event.ProductDeletedEvent,
+) error {
+	if !newEvent.Permanently {
+		return nil
+	}
+
+	err := h.Service.deleteInventory(
+		ctx,
+		newEvent.ProductID,
 	)
+	switch {
+	case errors.Is(err, idempotency.ErrAlreadyProcessed):
+		log.Printf("skipping deleting inventory of product '%s': %v\n", newEvent.ProductID, err)
+
+	case err != nil:
+		return fmt.Errorf(
+			"error deleting inventory of product '%s': %w",
+			newEvent.ProductID,
+			err,
+		)
+	}
+
+	return nil
 }
 
-// addSubscription iterates over subscribeToEventNames array and subscribes to
-// various events with addressCh.
-func (h *handlerEvent) addSubscriptions() {
-	// subscribeToEventNames is an array of all events this subscriber is
-	// wants to Subscribe to.
-	subscribeToEventNames := [1]event.EventName{
-		event.ProductCreatedEventName,
+// productCreatedDeadLetterHandler publishes an inventory creation failed event
+// once productCreatedEventHandler exhausted its retries, so the product
+// service can remove the product that has no inventory.
+func (h *handlerEvent) productCreatedDeadLetterHandler(
+	ctx context.Context,
+	payload any,
+	err error,
+) error {
+	newEvent, ok := payload.(*event.ProductCreatedEvent)
+	if !ok {
+		return fmt.Errorf(
+			"expected dead lettered payload to be '%T', got '%T'",
+			newEvent,
+			payload,
+		)
 	}
 
-	// Subscribe to events from the [subscriptions] array. If you want to add
-	//  more subscriptions, add it to the [subscribeToEventNames] array.
-	var err error
-	for _, v := range subscribeToEventNames {
-		err = h.EventEngine.Subscribe(
-			v,
-			&event.Subscriber{
-				Name:      subscriberName,
-				AddressCh: h.addressCh,
-			},
-		)
-		if err != nil {
-			log.Fatalf(
-				"error in Subscriber: '%s' \nerror subscribing to events: %v\n",
-				subscriberName,
-				err,
-			)
-			// h.errCh <- err
-			// return
-		}
+	failedEvent := &event.InventoryCreationFailedEvent{
+		ProductID: newEvent.ProductID,
 	}
 
+	// ctx carries the dead lettered product created event, so the failed
+	// event is recorded as caused by it.
+	return h.EventEngine.PublishContext(
+		ctx,
+		event.New(
+			ctx,
+			producerName,
+			failedEvent.GetEventName(),
+			failedEvent,
+		),
+	)
 }
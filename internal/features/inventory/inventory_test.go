@@ -0,0 +1,279 @@
+package inventory
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/eng-by-sjb/yellow-pines-e-commerce-backend/internal/eventengine"
+	"github.com/eng-by-sjb/yellow-pines-e-commerce-backend/internal/eventengine/event"
+	"github.com/eng-by-sjb/yellow-pines-e-commerce-backend/internal/eventengine/eventenginetest"
+	"github.com/eng-by-sjb/yellow-pines-e-commerce-backend/internal/eventengine/idempotency"
+	"github.com/eng-by-sjb/yellow-pines-e-commerce-backend/internal/servererrors"
+	"github.com/google/uuid"
+)
+
+type fakeService struct {
+	err     error
+	created map[uuid.UUID]uint
+	updated map[uuid.UUID]uint
+	deleted map[uuid.UUID]bool
+}
+
+func (s *fakeService) createInventory(ctx context.Context, pdID uuid.UUID, stkQty uint) error {
+	if s.err != nil {
+		return s.err
+	}
+
+	s.created[pdID] = stkQty
+	return nil
+}
+
+func (s *fakeService) updateStockQuantity(ctx context.Context, pdID uuid.UUID, stkQty uint) error {
+	if s.err != nil {
+		return s.err
+	}
+
+	s.updated[pdID] = stkQty
+	return nil
+}
+
+func (s *fakeService) deleteInventory(ctx context.Context, pdID uuid.UUID) error {
+	if s.err != nil {
+		return s.err
+	}
+
+	s.deleted[pdID] = true
+	return nil
+}
+
+func Test_productCreatedEventHandler(t *testing.T) {
+	testCases := []struct {
+		name             string
+		serviceErr       error
+		expectedCreated  bool
+		expectedFailure  bool
+		expectedEvent    event.EventName
+		unexpectedEvents []event.EventName
+	}{
+		{
+			name:             "creates the inventory of the product",
+			expectedCreated:  true,
+			expectedEvent:    event.InventoryCreatedEventName,
+			unexpectedEvents: []event.EventName{event.InventoryCreationFailedEventName},
+		},
+		{
+			name:             "skips a redelivered event",
+			serviceErr:       idempotency.ErrAlreadyProcessed,
+			expectedEvent:    event.InventoryCreatedEventName,
+			unexpectedEvents: []event.EventName{event.InventoryCreationFailedEventName},
+		},
+		{
+			name:             "skips a product deleted before its inventory was created",
+			serviceErr:       servererrors.ErrProductNotFound,
+			unexpectedEvents: []event.EventName{event.InventoryCreatedEventName, event.InventoryCreationFailedEventName},
+		},
+		{
+			name:             "publishes inventory creation failed once it fails",
+			serviceErr:       errors.New("test store failed"),
+			expectedFailure:  true,
+			expectedEvent:    event.InventoryCreationFailedEventName,
+			unexpectedEvents: []event.EventName{event.InventoryCreatedEventName},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			engine := eventenginetest.NewSynchronous(t)
+			engine.RegisterEvents(event.ProductCreatedEventName)
+
+			service := &fakeService{
+				err:     tc.serviceErr,
+				created: make(map[uuid.UUID]uint),
+			}
+			_, err := eventengine.RunModule(
+				engine,
+				NewEventsModule(
+					&HandlerEventsConfig{
+						EventEngine: engine,
+						Service:     service,
+					},
+				),
+			)
+			if err != nil {
+				t.Fatalf("expected to subscribe, got: %v", err)
+			}
+
+			productID := uuid.New()
+			productCreated := &event.ProductCreatedEvent{
+				ProductPayload: event.ProductPayload{
+					ProductID:     productID,
+					StockQuantity: 5,
+				},
+			}
+			err = engine.Publish(event.New(context.Background(), "test_product", productCreated.GetEventName(), productCreated))
+			if err != nil {
+				t.Fatalf("expected to publish, got: %v", err)
+			}
+
+			if failures := engine.Failures(); (len(failures) != 0) != tc.expectedFailure {
+				t.Fatalf("expected the handler to fail only if creating the inventory failed, got: %d failures", len(failures))
+			}
+
+			if quantity, ok := service.created[productID]; ok != tc.expectedCreated || (ok && quantity != 5) {
+				t.Fatalf("expected inventory created to be %v with quantity 5, got: %v with quantity %d", tc.expectedCreated, ok, quantity)
+			}
+
+			if tc.expectedEvent != "" {
+				published := engine.AssertPublished(t, tc.expectedEvent, nil)
+				if published.Producer != producerName {
+					t.Fatalf("expected producer '%s', got: '%s'", producerName, published.Producer)
+				}
+			}
+			for _, unexpectedEvent := range tc.unexpectedEvents {
+				engine.AssertNotPublished(t, unexpectedEvent, nil)
+			}
+		})
+	}
+}
+
+func Test_productQuantityUpdatedEventHandler(t *testing.T) {
+	testCases := []struct {
+		name            string
+		serviceErr      error
+		expectedUpdated bool
+		expectedFailure bool
+	}{
+		{
+			name:            "updates the stock quantity of the product",
+			expectedUpdated: true,
+		},
+		{
+			name:       "skips a redelivered event",
+			serviceErr: idempotency.ErrAlreadyProcessed,
+		},
+		{
+			name:            "fails once updating the stock quantity fails",
+			serviceErr:      errors.New("test store failed"),
+			expectedFailure: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			engine := eventenginetest.NewSynchronous(t)
+			engine.RegisterEvents(event.ProductUpdatedQuantityEventName)
+
+			service := &fakeService{
+				err:     tc.serviceErr,
+				updated: make(map[uuid.UUID]uint),
+			}
+			_, err := eventengine.RunModule(
+				engine,
+				NewEventsModule(
+					&HandlerEventsConfig{
+						EventEngine: engine,
+						Service:     service,
+					},
+				),
+			)
+			if err != nil {
+				t.Fatalf("expected to subscribe, got: %v", err)
+			}
+
+			productID := uuid.New()
+			quantityUpdated := &event.ProductQuantityUpdatedEvent{
+				Name: event.ProductUpdatedQuantityEventName,
+				ProductPayload: event.ProductPayload{
+					ProductID:     productID,
+					StockQuantity: 7,
+				},
+			}
+			err = engine.Publish(event.New(context.Background(), "test_product", quantityUpdated.EventName(), quantityUpdated))
+			if err != nil {
+				t.Fatalf("expected to publish, got: %v", err)
+			}
+
+			if failures := engine.Failures(); (len(failures) != 0) != tc.expectedFailure {
+				t.Fatalf("expected the handler to fail only if updating the stock quantity failed, got: %d failures", len(failures))
+			}
+
+			if quantity, ok := service.updated[productID]; ok != tc.expectedUpdated || (ok && quantity != 7) {
+				t.Fatalf("expected stock quantity updated to be %v with quantity 7, got: %v with quantity %d", tc.expectedUpdated, ok, quantity)
+			}
+		})
+	}
+}
+
+func Test_productDeletedEventHandler(t *testing.T) {
+	testCases := []struct {
+		name            string
+		permanently     bool
+		serviceErr      error
+		expectedDeleted bool
+		expectedFailure bool
+	}{
+		{
+			name: "keeps the inventory of a product moved to the trash",
+		},
+		{
+			name:            "deletes the inventory of a purged product",
+			permanently:     true,
+			expectedDeleted: true,
+		},
+		{
+			name:        "skips a redelivered event",
+			permanently: true,
+			serviceErr:  idempotency.ErrAlreadyProcessed,
+		},
+		{
+			name:            "fails once deleting the inventory fails",
+			permanently:     true,
+			serviceErr:      errors.New("test store failed"),
+			expectedFailure: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			engine := eventenginetest.NewSynchronous(t)
+			engine.RegisterEvents(event.ProductDeletedEventName)
+
+			service := &fakeService{
+				err:     tc.serviceErr,
+				deleted: make(map[uuid.UUID]bool),
+			}
+			_, err := eventengine.RunModule(
+				engine,
+				NewEventsModule(
+					&HandlerEventsConfig{
+						EventEngine: engine,
+						Service:     service,
+					},
+				),
+			)
+			if err != nil {
+				t.Fatalf("expected to subscribe, got: %v", err)
+			}
+
+			productID := uuid.New()
+			deleted := &event.ProductDeletedEvent{
+				Name:        event.ProductDeletedEventName,
+				ProductID:   productID,
+				Permanently: tc.permanently,
+			}
+			err = engine.Publish(event.New(context.Background(), "test_product", deleted.EventName(), deleted))
+			if err != nil {
+				t.Fatalf("expected to publish, got: %v", err)
+			}
+
+			if failures := engine.Failures(); (len(failures) != 0) != tc.expectedFailure {
+				t.Fatalf("expected the handler to fail only if deleting the inventory failed, got: %d failures", len(failures))
+			}
+
+			if service.deleted[productID] != tc.expectedDeleted {
+				t.Fatalf("expected inventory deleted to be %v, got: %v", tc.expectedDeleted, service.deleted[productID])
+			}
+		})
+	}
+}
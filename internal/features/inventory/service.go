@@ -8,6 +8,8 @@ import (
 
 type storer interface {
 	createOne(ctx context.Context, pdID uuid.UUID, stkQty uint) error
+	updateStockQuantity(ctx context.Context, pdID uuid.UUID, stkQty uint) error
+	deleteOne(ctx context.Context, pdID uuid.UUID) error
 }
 
 type service struct {
@@ -23,3 +25,11 @@ func NewService(inventoryStore storer) *service {
 func (s *service) createInventory(ctx context.Context, pdID uuid.UUID, stkQty uint) error {
 	return s.store.createOne(ctx, pdID, stkQty)
 }
+
+func (s *service) updateStockQuantity(ctx context.Context, pdID uuid.UUID, stkQty uint) error {
+	return s.store.updateStockQuantity(ctx, pdID, stkQty)
+}
+
+func (s *service) deleteInventory(ctx context.Context, pdID uuid.UUID) error {
+	return s.store.deleteOne(ctx, pdID)
+}
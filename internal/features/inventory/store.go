@@ -5,6 +5,8 @@ import (
 	"database/sql"
 	"fmt"
 
+	"github.com/eng-by-sjb/yellow-pines-e-commerce-backend/internal/eventengine/idempotency"
+	"github.com/eng-by-sjb/yellow-pines-e-commerce-backend/internal/servererrors"
 	"github.com/google/uuid"
 )
 
@@ -18,10 +20,29 @@ func NewStore(db *sql.DB) *store {
 	}
 }
 
+// createOne inserts the inventory of a product. If ctx carries the event
+// the inventory is created for, it fails with
+// [idempotency.ErrAlreadyProcessed] when the inventory was already created
+// for that event. It fails with [servererrors.ErrProductNotFound] if the
+// product was deleted meanwhile.
 func (s *store) createOne(ctx context.Context, pdID uuid.UUID, stkQty uint) error {
-	inventoryQuery := `INSERT INTO inventory(product_id, stock_quantity) VALUES($1, $2)`
+	inventoryQuery := `INSERT INTO inventory(product_id, stock_quantity)
+	SELECT $1, $2 WHERE EXISTS (SELECT 1 FROM products WHERE product_id = $1)`
 
-	_, err := s.db.ExecContext(
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf(
+			"failed to begin transaction in inventory store: %w",
+			err,
+		)
+	}
+	defer tx.Rollback()
+
+	if err := idempotency.MarkProcessed(ctx, tx, subscriberName); err != nil {
+		return err
+	}
+
+	result, err := tx.ExecContext(
 		ctx,
 		inventoryQuery,
 		pdID,
@@ -33,5 +54,102 @@ func (s *store) createOne(ctx context.Context, pdID uuid.UUID, stkQty uint) erro
 			err,
 		)
 	}
+
+	if created, err := result.RowsAffected(); err == nil && created == 0 {
+		return servererrors.ErrProductNotFound
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf(
+			"failed to commit new inventory in inventory store: %w",
+			err,
+		)
+	}
+
+	return nil
+}
+
+// updateStockQuantity sets the stock quantity of the inventory of a product.
+// Like createOne, it fails with [idempotency.ErrAlreadyProcessed] when ctx
+// carries an event the stock quantity was already updated for.
+func (s *store) updateStockQuantity(ctx context.Context, pdID uuid.UUID, stkQty uint) error {
+	query := `UPDATE inventory SET stock_quantity = $2, updated_at = NOW() WHERE product_id = $1`
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf(
+			"failed to begin transaction in inventory store: %w",
+			err,
+		)
+	}
+	defer tx.Rollback()
+
+	if err := idempotency.MarkProcessed(ctx, tx, subscriberName); err != nil {
+		return err
+	}
+
+	result, err := tx.ExecContext(
+		ctx,
+		query,
+		pdID,
+		stkQty,
+	)
+	if err != nil {
+		return fmt.Errorf(
+			"failed to update stock quantity in inventory store: %w",
+			err,
+		)
+	}
+
+	if updated, err := result.RowsAffected(); err == nil && updated == 0 {
+		return fmt.Errorf(
+			"no inventory of product '%s' in inventory store",
+			pdID,
+		)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf(
+			"failed to commit stock quantity in inventory store: %w",
+			err,
+		)
+	}
+
+	return nil
+}
+
+// deleteOne deletes the inventory of a product. Like createOne, it fails with
+// [idempotency.ErrAlreadyProcessed] when ctx carries an event the inventory
+// was already deleted for. A product without inventory is not an error.
+func (s *store) deleteOne(ctx context.Context, pdID uuid.UUID) error {
+	query := `DELETE FROM inventory WHERE product_id = $1`
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf(
+			"failed to begin transaction in inventory store: %w",
+			err,
+		)
+	}
+	defer tx.Rollback()
+
+	if err := idempotency.MarkProcessed(ctx, tx, subscriberName); err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, query, pdID); err != nil {
+		return fmt.Errorf(
+			"failed to delete inventory from inventory store: %w",
+			err,
+		)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf(
+			"failed to commit deleted inventory in inventory store: %w",
+			err,
+		)
+	}
+
 	return nil
 }
@@ -0,0 +1,121 @@
+package product
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"strconv"
+	"time"
+
+	"github.com/eng-by-sjb/yellow-pines-e-commerce-backend/internal/servererrors"
+	"github.com/google/uuid"
+)
+
+type cursorDirection string
+
+const (
+	cursorNext cursorDirection = "next"
+	cursorPrev cursorDirection = "prev"
+)
+
+// cursorTimeLayout is how a created_at sort value is kept in a cursor, without
+// a time zone like the column it is compared with.
+const cursorTimeLayout = "2006-01-02 15:04:05.999999"
+
+// productCursor is the position of a product in a listing, from which the
+// products after or before it are listed. Products are ordered by their sort
+// value, then by their product id, so products with the same sort value are
+// neither listed twice nor skipped.
+//
+// A cursor is only valid for the listing it was made for, i.e. with the same
+// sort and filters. A cursor without a ProductID is the start of a listing.
+type productCursor struct {
+	SortBy    string          `json:"s"`
+	SortOpt   string          `json:"o"`
+	Search    string          `json:"q,omitempty"`
+	Category  string          `json:"c,omitempty"`
+	PriceMin  float64         `json:"min,omitempty"`
+	PriceMax  float64         `json:"max,omitempty"`
+	Value     string          `json:"v"` // the sort value of the product, as text.
+	ProductID uuid.UUID       `json:"id"`
+	Direction cursorDirection `json:"d"`
+}
+
+// newCursor returns the cursor of product in the listing of queryItems, to
+// list the products in direction from it.
+func newCursor(
+	queryItems *GetAllProductsRequestQuery,
+	product *ProductAndInventoryDTO,
+	direction cursorDirection,
+) *productCursor {
+	var value string
+	switch queryItems.SortOpts.SortBy {
+	case "name":
+		value = product.Name
+	case "category":
+		value = product.Category
+	case "price":
+		value = strconv.FormatFloat(product.Price, 'g', -1, 64)
+	case "relevance":
+		value = strconv.FormatFloat(product.Relevance, 'g', -1, 64)
+	default:
+		value = product.CreatedAt.Format(cursorTimeLayout)
+	}
+
+	return &productCursor{
+		SortBy:    queryItems.SortOpts.SortBy,
+		SortOpt:   queryItems.SortOpts.SortOpt,
+		Search:    queryItems.FilterOpts.Search,
+		Category:  queryItems.FilterOpts.Category,
+		PriceMin:  queryItems.FilterOpts.PriceMin,
+		PriceMax:  queryItems.FilterOpts.PriceMax,
+		Value:     value,
+		ProductID: product.ProductID,
+		Direction: direction,
+	}
+}
+
+// encode returns the cursor as an opaque string to hand to clients.
+func (c *productCursor) encode() string {
+	raw, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+// decodeCursor decodes a cursor from encode. It returns
+// [servererrors.ErrInvalidCursor] if encoded is not a cursor of the listing
+// of queryItems, e.g. because the listing is sorted or filtered otherwise now.
+func decodeCursor(encoded string, queryItems *GetAllProductsRequestQuery) (*productCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, servererrors.ErrInvalidCursor
+	}
+
+	cursor := new(productCursor)
+	if err := json.Unmarshal(raw, cursor); err != nil {
+		return nil, servererrors.ErrInvalidCursor
+	}
+
+	if cursor.SortBy != queryItems.SortOpts.SortBy ||
+		cursor.SortOpt != queryItems.SortOpts.SortOpt ||
+		cursor.Search != queryItems.FilterOpts.Search ||
+		cursor.Category != queryItems.FilterOpts.Category ||
+		cursor.PriceMin != queryItems.FilterOpts.PriceMin ||
+		cursor.PriceMax != queryItems.FilterOpts.PriceMax ||
+		cursor.ProductID == uuid.Nil ||
+		(cursor.Direction != cursorNext && cursor.Direction != cursorPrev) {
+		return nil, servererrors.ErrInvalidCursor
+	}
+
+	if cursor.SortBy == "created_at" {
+		if _, err := time.Parse(cursorTimeLayout, cursor.Value); err != nil {
+			return nil, servererrors.ErrInvalidCursor
+		}
+	}
+
+	if cursor.SortBy == "price" || cursor.SortBy == "relevance" {
+		if _, err := strconv.ParseFloat(cursor.Value, 64); err != nil {
+			return nil, servererrors.ErrInvalidCursor
+		}
+	}
+
+	return cursor, nil
+}
@@ -0,0 +1,175 @@
+package product
+
+import (
+	"encoding/base64"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/eng-by-sjb/yellow-pines-e-commerce-backend/internal/servererrors"
+	"github.com/google/uuid"
+)
+
+func Test_newCursor(t *testing.T) {
+	product := &ProductAndInventoryDTO{
+		Product: Product{
+			ProductID: uuid.New(),
+			Name:      "Pine Cone Jam",
+			Category:  "food",
+			Price:     4.25,
+			CreatedAt: time.Date(2026, 10, 18, 9, 30, 15, 123456000, time.UTC),
+		},
+		Relevance: 0.0607927,
+	}
+
+	testCases := []struct {
+		sortBy        string
+		expectedValue string
+	}{
+		{sortBy: "name", expectedValue: "Pine Cone Jam"},
+		{sortBy: "category", expectedValue: "food"},
+		{sortBy: "price", expectedValue: "4.25"},
+		{sortBy: "relevance", expectedValue: "0.0607927"},
+		{sortBy: "created_at", expectedValue: "2026-10-18 09:30:15.123456"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.sortBy, func(t *testing.T) {
+			queryItems := &GetAllProductsRequestQuery{
+				FilterOpts: FilterOpts{Search: "jam", Category: "food", PriceMin: 1, PriceMax: 10},
+				SortOpts:   SortOpts{SortBy: tc.sortBy, SortOpt: "asc"},
+			}
+
+			cursor := newCursor(queryItems, product, cursorPrev)
+			if cursor.Value != tc.expectedValue {
+				t.Fatalf("expected value '%s', got: '%s'", tc.expectedValue, cursor.Value)
+			}
+
+			decoded, err := decodeCursor(cursor.encode(), queryItems)
+			if err != nil {
+				t.Fatalf("expected to decode the cursor, got: %v", err)
+			}
+			if *decoded != *cursor {
+				t.Fatalf("expected cursor %+v, got: %+v", cursor, decoded)
+			}
+		})
+	}
+}
+
+func Test_decodeCursor(t *testing.T) {
+	newQueryItems := func() *GetAllProductsRequestQuery {
+		return &GetAllProductsRequestQuery{
+			FilterOpts: FilterOpts{Search: "jam", Category: "food", PriceMin: 1, PriceMax: 10},
+			SortOpts:   SortOpts{SortBy: "price", SortOpt: "desc"},
+		}
+	}
+
+	validCursor := func() *productCursor {
+		return &productCursor{
+			SortBy:    "price",
+			SortOpt:   "desc",
+			Search:    "jam",
+			Category:  "food",
+			PriceMin:  1,
+			PriceMax:  10,
+			Value:     "4.25",
+			ProductID: uuid.New(),
+			Direction: cursorNext,
+		}
+	}
+
+	testCases := []struct {
+		name       string
+		encoded    func() string
+		queryItems func(queryItems *GetAllProductsRequestQuery)
+	}{
+		{
+			name:    "not base64",
+			encoded: func() string { return "not a cursor!" },
+		},
+		{
+			name:    "not json",
+			encoded: func() string { return base64.RawURLEncoding.EncodeToString([]byte("not json")) },
+		},
+		{
+			name:       "sorted by another column",
+			queryItems: func(q *GetAllProductsRequestQuery) { q.SortOpts.SortBy = "name" },
+		},
+		{
+			name:       "sorted the other way",
+			queryItems: func(q *GetAllProductsRequestQuery) { q.SortOpts.SortOpt = "asc" },
+		},
+		{
+			name:       "another search",
+			queryItems: func(q *GetAllProductsRequestQuery) { q.FilterOpts.Search = "tea" },
+		},
+		{
+			name:       "another category",
+			queryItems: func(q *GetAllProductsRequestQuery) { q.FilterOpts.Category = "decor" },
+		},
+		{
+			name:       "another minimum price",
+			queryItems: func(q *GetAllProductsRequestQuery) { q.FilterOpts.PriceMin = 2 },
+		},
+		{
+			name:       "another maximum price",
+			queryItems: func(q *GetAllProductsRequestQuery) { q.FilterOpts.PriceMax = 0 },
+		},
+		{
+			name: "no product id",
+			encoded: func() string {
+				cursor := validCursor()
+				cursor.ProductID = uuid.Nil
+				return cursor.encode()
+			},
+		},
+		{
+			name: "unknown direction",
+			encoded: func() string {
+				cursor := validCursor()
+				cursor.Direction = "sideways"
+				return cursor.encode()
+			},
+		},
+		{
+			name: "price that is not a number",
+			encoded: func() string {
+				cursor := validCursor()
+				cursor.Value = "cheap"
+				return cursor.encode()
+			},
+		},
+		{
+			name: "creation time that is not a time",
+			encoded: func() string {
+				cursor := validCursor()
+				cursor.SortBy = "created_at"
+				cursor.Value = "yesterday"
+				return cursor.encode()
+			},
+			queryItems: func(q *GetAllProductsRequestQuery) { q.SortOpts.SortBy = "created_at" },
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			encoded := validCursor().encode()
+			if tc.encoded != nil {
+				encoded = tc.encoded()
+			}
+
+			queryItems := newQueryItems()
+			if tc.queryItems != nil {
+				tc.queryItems(queryItems)
+			}
+
+			if _, err := decodeCursor(encoded, queryItems); !errors.Is(err, servererrors.ErrInvalidCursor) {
+				t.Fatalf("expected error '%v', got: %v", servererrors.ErrInvalidCursor, err)
+			}
+		})
+	}
+
+	if _, err := decodeCursor(validCursor().encode(), newQueryItems()); err != nil {
+		t.Fatalf("expected a cursor of the listing to be valid, got: %v", err)
+	}
+}
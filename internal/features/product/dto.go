@@ -1,6 +1,8 @@
 package product
 
 import (
+	"time"
+
 	"github.com/google/uuid"
 )
 
@@ -17,16 +19,31 @@ type CreateProductRequest struct {
 	Quantity    uint    `json:"quantity" validate:"required"`
 }
 
+// UpdateProductRequest is a partial update of a product. Only the fields that
+// are set are updated, and they are validated like in CreateProductRequest.
+// "required" passes for any field that is set, even to an empty value, so it
+// is checked with "min" and "gt" instead.
 type UpdateProductRequest struct {
 	AdminID     uuid.UUID
-	ProductID   uuid.UUID `json:"productID" validate:"required,uuid"`
-	Name        *string   `json:"name"`
-	Description *string   `json:"description"`
-	ImageURL    *string   `json:"imageURL"`
-	Price       *float64  `json:"price"`
-	Category    *string   `json:"category"`
+	ProductID   uuid.UUID `json:"-" validate:"required,uuid"`
+	Name        *string   `json:"name" validate:"omitnil,min=10,max=30,noAllRepeatingChars"`
+	Description *string   `json:"description" validate:"omitnil,min=15,max=350,noAllRepeatingChars"`
+	ImageURL    *string   `json:"imageURL" validate:"omitnil,url"`
+	Price       *float64  `json:"price" validate:"omitnil,gt=0"`
+	Category    *string   `json:"category" validate:"omitnil,min=1"`
 	IsActive    *bool     `json:"isActive"`
-	Quantity    *uint     `json:"quantity" validate:"required"`
+	Quantity    *uint     `json:"quantity" validate:"omitnil"` // Updated by the inventory feature, see event.ProductQuantityUpdatedEvent.
+}
+
+type CreateSynonymRequest struct {
+	AdminID uuid.UUID
+	Term    string `json:"term" validate:"required,min=2,max=50"`
+	Synonym string `json:"synonym" validate:"required,min=2,max=50,nefield=Term"`
+}
+
+type SuggestRequestQuery struct {
+	Q     string `json:"q" validate:"required,min=2,max=100"`
+	Limit uint64 `json:"limit" validate:"min=1,max=20"`
 }
 
 type FilterOpts struct {
@@ -34,16 +51,25 @@ type FilterOpts struct {
 	PriceMin float64 `json:"priceMin" validate:"min=0"`
 	PriceMax float64 `json:"priceMax" validate:"min=0"`
 	Search   string  `json:"search"`
+	// SearchSynonyms are alternatives of Search with words replaced by their
+	// synonyms, which are searched for too.
+	SearchSynonyms []string `json:"-"`
 }
 
 type SortOpts struct {
-	SortBy  string `json:"sortBy" validate:"oneof=name price category created_at"`
+	SortBy  string `json:"sortBy" validate:"oneof=name price category created_at relevance"` // relevance sorts by how well a product matches the search.
 	SortOpt string `json:"sortOpt" validate:"oneof=desc asc"`
 }
 
+// PageOpts are either a Page, for tables of admins that jump between pages,
+// or a Cursor from a previous listing, which lists stably while products are
+// created and stays fast on deep pages. Products are listed by cursor if Mode
+// is "cursor", which it is whenever there is a Cursor.
 type PageOpts struct {
-	Page  uint64 `json:"page" validate:"min=0"`
-	Limit uint64 `json:"limit" validate:"min=0"`
+	Page   uint64 `json:"page" validate:"min=1"`
+	Limit  uint64 `json:"limit" validate:"min=1"`
+	Cursor string `json:"cursor"`
+	Mode   string `json:"mode" validate:"omitempty,oneof=page cursor"`
 }
 
 type GetAllProductsRequestQuery struct {
@@ -54,9 +80,59 @@ type GetAllProductsRequestQuery struct {
 
 // Responses
 
+// ProvisioningStatusDTO is how far the provisioning of a product got.
+type ProvisioningStatusDTO struct {
+	ProductID   uuid.UUID `json:"productID"`
+	Provisioned bool      `json:"provisioned"` // whether the product and its inventory were created.
+	Status      string    `json:"status"`
+	Step        string    `json:"step"`
+	Error       string    `json:"error,omitempty"`
+	UpdatedAt   time.Time `json:"updatedAt"`
+}
+
 type ProductAndInventoryDTO struct {
 	Product
-	StockQuantity uint `json:"stockQuantity"`
+	StockQuantity uint                 `json:"stockQuantity"`
+	Relevance     float64              `json:"relevance,omitempty"`  // how well the product matches the search.
+	Highlights    *SearchHighlightsDTO `json:"highlights,omitempty"` // set if the product was found by a search.
+}
+
+// SearchHighlightsDTO are the name and snippets of the description of a
+// product found by a search, with the matches wrapped in <mark> tags. They are
+// HTML escaped otherwise, so they can be rendered as HTML.
+type SearchHighlightsDTO struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+// TrashedProductDTO is a product in the trash, which is purged for good at
+// PurgeAt unless it is restored before.
+type TrashedProductDTO struct {
+	Product
+	PurgeAt time.Time `json:"purgeAt"`
+}
+
+// SuggestionDTO is a product name or category that matches what a shopper
+// typed, scored from 0 to 1 by how similar it is.
+type SuggestionDTO struct {
+	Suggestion string  `json:"suggestion"`
+	Kind       string  `json:"kind"` // either "product" or "category".
+	Score      float64 `json:"score"`
+}
+
+// GetProductsPageResponse is a page of products listed by cursor. The page
+// next to it is listed with ?cursor=<nextCursor> or ?cursor=<prevCursor>,
+// which are empty if there is no such page.
+type GetProductsPageResponse struct {
+	RetrievedItemsCount int                       `json:"retrievedItemsCount"`
+	NextCursor          string                    `json:"nextCursor,omitempty"`
+	PrevCursor          string                    `json:"prevCursor,omitempty"`
+	Products            []*ProductAndInventoryDTO `json:"products"`
+}
+
+type GetTrashResponse struct {
+	AllProductsCount int                  `json:"allProductsCount"`
+	Products         []*TrashedProductDTO `json:"products"`
 }
 
 type GetAllProductsResponse struct {
@@ -7,16 +7,27 @@ import (
 )
 
 type Product struct {
-	ProductID   uuid.UUID `json:"productID"`
-	AdminID     uuid.UUID `json:"-"`
-	Name        string    `json:"name"`
-	Description string    `json:"description"`
-	ImageURL    string    `json:"imageURL"`
-	Price       float64   `json:"price"`
-	Category    string    `json:"category"`
-	IsActive    bool      `json:"isActive"`
-	CreatedAt   time.Time `json:"createdAt"`
-	UpdatedAt   time.Time `json:"updatedAt"`
+	ProductID   uuid.UUID  `json:"productID"`
+	AdminID     uuid.UUID  `json:"-"`
+	Name        string     `json:"name"`
+	Description string     `json:"description"`
+	ImageURL    string     `json:"imageURL"`
+	Price       float64    `json:"price"`
+	Category    string     `json:"category"`
+	IsActive    bool       `json:"isActive"`
+	CreatedAt   time.Time  `json:"createdAt"`
+	UpdatedAt   time.Time  `json:"updatedAt"`
+	DeletedAt   *time.Time `json:"deletedAt,omitempty"` // set while the product is in the trash.
+}
+
+// Synonym is a pair of words a search for either of them also finds products
+// by the other, e.g. "couch" and "sofa". Term is the lexically smaller one.
+type Synonym struct {
+	SynonymID uuid.UUID `json:"synonymID"`
+	Term      string    `json:"term"`
+	Synonym   string    `json:"synonym"`
+	AdminID   uuid.UUID `json:"-"`
+	CreatedAt time.Time `json:"createdAt"`
 }
 
 type Inventory struct {
@@ -3,12 +3,14 @@ package product
 import (
 	"context"
 	"errors"
+	"fmt"
 	"net/http"
 	"net/url"
 	"strconv"
 	"strings"
 	"time"
 
+	"github.com/eng-by-sjb/yellow-pines-e-commerce-backend/internal/eventengine/saga"
 	"github.com/eng-by-sjb/yellow-pines-e-commerce-backend/internal/handlerutils"
 	"github.com/eng-by-sjb/yellow-pines-e-commerce-backend/internal/middlewares"
 	"github.com/eng-by-sjb/yellow-pines-e-commerce-backend/internal/servererrors"
@@ -18,10 +20,19 @@ import (
 )
 
 type servicer interface {
-	createProduct(ctx context.Context, newProduct *CreateProductRequest) error
+	createProduct(ctx context.Context, newProduct *CreateProductRequest) (*ProvisioningStatusDTO, error)
+	getProvisioningStatus(ctx context.Context, productID uuid.UUID) (*ProvisioningStatusDTO, error)
 	getAllProducts(ctx context.Context, query *GetAllProductsRequestQuery) ([]*ProductAndInventoryDTO, int, error)
+	getProductsPage(ctx context.Context, query *GetAllProductsRequestQuery) ([]*ProductAndInventoryDTO, string, string, error)
 	getProduct(ctx context.Context, productID uuid.UUID) (*ProductAndInventoryDTO, error)
+	updateProduct(ctx context.Context, update *UpdateProductRequest) (*Product, error)
 	deleteProduct(ctx context.Context, productID uuid.UUID) error
+	restoreProduct(ctx context.Context, productID uuid.UUID) (*Product, error)
+	getTrash(ctx context.Context, pageOpts *PageOpts) ([]*TrashedProductDTO, int, error)
+	suggest(ctx context.Context, q string, limit int) ([]*SuggestionDTO, error)
+	getSynonyms(ctx context.Context) ([]*Synonym, error)
+	createSynonym(ctx context.Context, newSynonym *CreateSynonymRequest) (*Synonym, error)
+	deleteSynonym(ctx context.Context, synonymID uuid.UUID) error
 }
 
 type middleware interface {
@@ -55,6 +66,13 @@ func (h *handler) RegisterRoutes(router *chi.Mux) {
 		),
 	)
 
+	router.Get(
+		"/products/suggest",
+		handlerutils.MakeHandler(
+			h.suggestHandler,
+		),
+	)
+
 	// protected routes
 	router.Post(
 		"/products",
@@ -66,6 +84,85 @@ func (h *handler) RegisterRoutes(router *chi.Mux) {
 		),
 	)
 
+	router.Patch(
+		"/products/{productID}",
+		handlerutils.MakeHandler(
+			h.middleware.AuthWithContext(
+				h.updateProductHandler,
+				"admin",
+			),
+		),
+	)
+
+	router.Get(
+		"/products/{productID}/provisioning",
+		handlerutils.MakeHandler(
+			h.middleware.AuthWithContext(
+				h.getProvisioningStatusHandler,
+				"admin",
+			),
+		),
+	)
+
+	router.Delete(
+		"/products/{productID}",
+		handlerutils.MakeHandler(
+			h.middleware.AuthWithContext(
+				h.deleteProductHandler,
+				"admin",
+			),
+		),
+	)
+
+	router.Get(
+		"/products/trash",
+		handlerutils.MakeHandler(
+			h.middleware.AuthWithContext(
+				h.getTrashHandler,
+				"admin",
+			),
+		),
+	)
+
+	router.Post(
+		"/products/{productID}/restore",
+		handlerutils.MakeHandler(
+			h.middleware.AuthWithContext(
+				h.restoreProductHandler,
+				"admin",
+			),
+		),
+	)
+
+	router.Get(
+		"/products/synonyms",
+		handlerutils.MakeHandler(
+			h.middleware.AuthWithContext(
+				h.getSynonymsHandler,
+				"admin",
+			),
+		),
+	)
+
+	router.Post(
+		"/products/synonyms",
+		handlerutils.MakeHandler(
+			h.middleware.AuthWithContext(
+				h.createSynonymHandler,
+				"admin",
+			),
+		),
+	)
+
+	router.Delete(
+		"/products/synonyms/{synonymID}",
+		handlerutils.MakeHandler(
+			h.middleware.AuthWithContext(
+				h.deleteSynonymHandler,
+				"admin",
+			),
+		),
+	)
 }
 
 func (h *handler) createProductHandler(w http.ResponseWriter, r *http.Request) error {
@@ -97,7 +194,7 @@ func (h *handler) createProductHandler(w http.ResponseWriter, r *http.Request) e
 		)
 	}
 
-	err = h.service.createProduct(
+	status, err := h.service.createProduct(
 		ctx,
 		payload,
 	)
@@ -115,11 +212,137 @@ func (h *handler) createProductHandler(w http.ResponseWriter, r *http.Request) e
 		}
 	}
 
+	switch status.Status {
+	case string(saga.StatusCompleted):
+		return handlerutils.WriteSuccessJSON(
+			w,
+			http.StatusOK,
+			"product created",
+			status,
+		)
+
+	case string(saga.StatusRunning):
+		// the product was created but its inventory is still being created.
+		return handlerutils.WriteSuccessJSON(
+			w,
+			http.StatusAccepted,
+			"product is being provisioned",
+			status,
+		)
+
+	default:
+		return fmt.Errorf(
+			"error provisioning product '%s': %s",
+			status.ProductID,
+			status.Error,
+		)
+	}
+}
+
+// updateProductHandler updates the fields of a product set in the request.
+// The stock quantity is updated by the inventory feature shortly after.
+func (h *handler) updateProductHandler(w http.ResponseWriter, r *http.Request) error {
+	ctx, cancel := context.WithTimeout(
+		r.Context(),
+		(30 * time.Second),
+	)
+	defer cancel()
+
+	productID, err := uuid.Parse(chi.URLParam(r, "productID"))
+	if err != nil {
+		return servererrors.New(
+			http.StatusBadRequest,
+			servererrors.ErrInvalidID.Error(),
+			nil,
+		)
+	}
+
+	var payload *UpdateProductRequest
+	defer r.Body.Close()
+
+	if err = handlerutils.ParseJSON(r, &payload); err != nil || payload == nil {
+		return servererrors.New(
+			http.StatusBadRequest,
+			servererrors.ErrInvalidRequestPayload.Error(),
+			nil,
+		)
+	}
+
+	payload.ProductID = productID
+	payload.AdminID = middlewares.GetEntityIDFromContextKey(ctx)
+
+	if err = validate.StructFields(payload); err != nil {
+		return servererrors.New(
+			http.StatusUnprocessableEntity,
+			servererrors.ErrValidationFailed.Error(),
+			err,
+		)
+	}
+
+	product, err := h.service.updateProduct(ctx, payload)
+	if err != nil {
+		switch {
+		case errors.Is(err, servererrors.ErrNoFieldsToUpdate):
+			return servererrors.New(
+				http.StatusBadRequest,
+				servererrors.ErrNoFieldsToUpdate.Error(),
+				nil,
+			)
+
+		case errors.Is(err, servererrors.ErrProductNotFound):
+			return servererrors.New(
+				http.StatusNotFound,
+				servererrors.ErrProductNotFound.Error(),
+				nil,
+			)
+
+		case errors.Is(err, servererrors.ErrProductAlreadyExists):
+			return servererrors.New(
+				http.StatusConflict,
+				servererrors.ErrProductAlreadyExists.Error(),
+				nil,
+			)
+
+		default:
+			return err
+		}
+	}
+
 	return handlerutils.WriteSuccessJSON(
 		w,
 		http.StatusOK,
-		"product created",
-		nil,
+		"product updated",
+		product,
+	)
+}
+
+func (h *handler) getProvisioningStatusHandler(w http.ResponseWriter, r *http.Request) error {
+	productID, err := uuid.Parse(chi.URLParam(r, "productID"))
+	if err != nil {
+		return servererrors.New(
+			http.StatusBadRequest,
+			servererrors.ErrInvalidRequestPayload.Error(),
+			nil,
+		)
+	}
+
+	status, err := h.service.getProvisioningStatus(r.Context(), productID)
+	if err != nil {
+		if errors.Is(err, servererrors.ErrProductNotFound) {
+			return servererrors.New(
+				http.StatusNotFound,
+				servererrors.ErrProductNotFound.Error(),
+				nil,
+			)
+		}
+		return err
+	}
+
+	return handlerutils.WriteSuccessJSON(
+		w,
+		http.StatusOK,
+		"product provisioning status retrieved",
+		status,
 	)
 }
 
@@ -149,12 +372,38 @@ func (h *handler) getAllProductsHandler(w http.ResponseWriter, r *http.Request)
 		)
 	}
 
+	if queryItems.PageOpts.Mode == "cursor" {
+		products, nextCursor, prevCursor, err := h.service.getProductsPage(ctx, queryItems)
+		if err != nil {
+			if errors.Is(err, servererrors.ErrInvalidCursor) {
+				return servererrors.New(
+					http.StatusBadRequest,
+					servererrors.ErrInvalidCursor.Error(),
+					nil,
+				)
+			}
+			return err
+		}
+
+		return handlerutils.WriteSuccessJSON(
+			w,
+			http.StatusOK,
+			"all products retrieved",
+			GetProductsPageResponse{
+				RetrievedItemsCount: len(products),
+				NextCursor:          nextCursor,
+				PrevCursor:          prevCursor,
+				Products:            products,
+			},
+		)
+	}
+
 	products, totalCount, err := h.service.getAllProducts(ctx, queryItems)
 	if err != nil {
 		return err
 	}
 
-	totalPagesCount := totalCount / int(queryItems.PageOpts.Limit)
+	totalPagesCount := (totalCount + int(queryItems.PageOpts.Limit) - 1) / int(queryItems.PageOpts.Limit)
 	itemsLeftCount := (totalCount - int(queryItems.PageOpts.Page*queryItems.PageOpts.Limit))
 	pagesLeftCount := (itemsLeftCount + int(queryItems.PageOpts.Limit) - 1) / int(queryItems.PageOpts.Limit)
 
@@ -187,6 +436,13 @@ func (h *handler) getProductHandler(w http.ResponseWriter, r *http.Request) erro
 	}
 	product, err := h.service.getProduct(r.Context(), productID)
 	if err != nil {
+		if errors.Is(err, servererrors.ErrProductNotFound) {
+			return servererrors.New(
+				http.StatusNotFound,
+				servererrors.ErrProductNotFound.Error(),
+				nil,
+			)
+		}
 		return err
 	}
 
@@ -198,15 +454,276 @@ func (h *handler) getProductHandler(w http.ResponseWriter, r *http.Request) erro
 	)
 }
 
+func (h *handler) deleteProductHandler(w http.ResponseWriter, r *http.Request) error {
+	ctx, cancel := context.WithTimeout(
+		r.Context(),
+		(30 * time.Second),
+	)
+	defer cancel()
+
+	productID, err := uuid.Parse(chi.URLParam(r, "productID"))
+	if err != nil {
+		return servererrors.New(
+			http.StatusBadRequest,
+			servererrors.ErrInvalidID.Error(),
+			nil,
+		)
+	}
+
+	if err = h.service.deleteProduct(ctx, productID); err != nil {
+		if errors.Is(err, servererrors.ErrProductNotFound) {
+			return servererrors.New(
+				http.StatusNotFound,
+				servererrors.ErrProductNotFound.Error(),
+				nil,
+			)
+		}
+		return err
+	}
+
+	return handlerutils.WriteSuccessJSON(
+		w,
+		http.StatusOK,
+		"product moved to trash",
+		nil,
+	)
+}
+
+func (h *handler) getTrashHandler(w http.ResponseWriter, r *http.Request) error {
+	ctx, cancel := context.WithTimeout(
+		r.Context(),
+		(30 * time.Second),
+	)
+	defer cancel()
+
+	queries := r.URL.Query()
+
+	pageOpts := &PageOpts{
+		Page: stringToUint64(
+			1,
+			queries.Get("page"),
+		),
+		Limit: stringToUint64(
+			20,
+			queries.Get("limit"),
+		),
+	}
+
+	if pageOpts.Page == 0 || pageOpts.Limit == 0 {
+		return servererrors.New(
+			http.StatusUnprocessableEntity,
+			servererrors.ErrURLQueryParams.Error(),
+			nil,
+		)
+	}
+
+	products, totalCount, err := h.service.getTrash(ctx, pageOpts)
+	if err != nil {
+		return err
+	}
+
+	return handlerutils.WriteSuccessJSON(
+		w,
+		http.StatusOK,
+		"trashed products retrieved",
+		GetTrashResponse{
+			AllProductsCount: totalCount,
+			Products:         products,
+		},
+	)
+}
+
+func (h *handler) restoreProductHandler(w http.ResponseWriter, r *http.Request) error {
+	ctx, cancel := context.WithTimeout(
+		r.Context(),
+		(30 * time.Second),
+	)
+	defer cancel()
+
+	productID, err := uuid.Parse(chi.URLParam(r, "productID"))
+	if err != nil {
+		return servererrors.New(
+			http.StatusBadRequest,
+			servererrors.ErrInvalidID.Error(),
+			nil,
+		)
+	}
+
+	product, err := h.service.restoreProduct(ctx, productID)
+	if err != nil {
+		if errors.Is(err, servererrors.ErrProductNotFound) {
+			return servererrors.New(
+				http.StatusNotFound,
+				servererrors.ErrProductNotFound.Error(),
+				nil,
+			)
+		}
+		return err
+	}
+
+	return handlerutils.WriteSuccessJSON(
+		w,
+		http.StatusOK,
+		"product restored",
+		product,
+	)
+}
+
+func (h *handler) suggestHandler(w http.ResponseWriter, r *http.Request) error {
+	// suggestions are requested as the shopper types, so they are only
+	// useful if they are quick.
+	ctx, cancel := context.WithTimeout(
+		r.Context(),
+		(2 * time.Second),
+	)
+	defer cancel()
+
+	queries := r.URL.Query()
+
+	query := &SuggestRequestQuery{
+		Q: strings.TrimSpace(queries.Get("q")),
+		Limit: stringToUint64(
+			10,
+			queries.Get("limit"),
+		),
+	}
+
+	if err := validate.StructFields(query); err != nil {
+		return servererrors.New(
+			http.StatusUnprocessableEntity,
+			servererrors.ErrURLQueryParams.Error(),
+			err,
+		)
+	}
+
+	suggestions, err := h.service.suggest(ctx, query.Q, int(query.Limit))
+	if err != nil {
+		return err
+	}
+
+	return handlerutils.WriteSuccessJSON(
+		w,
+		http.StatusOK,
+		"suggestions retrieved",
+		suggestions,
+	)
+}
+
+func (h *handler) getSynonymsHandler(w http.ResponseWriter, r *http.Request) error {
+	synonyms, err := h.service.getSynonyms(r.Context())
+	if err != nil {
+		return err
+	}
+
+	return handlerutils.WriteSuccessJSON(
+		w,
+		http.StatusOK,
+		"synonyms retrieved",
+		synonyms,
+	)
+}
+
+func (h *handler) createSynonymHandler(w http.ResponseWriter, r *http.Request) error {
+	ctx, cancel := context.WithTimeout(
+		r.Context(),
+		(30 * time.Second),
+	)
+	defer cancel()
+
+	var payload *CreateSynonymRequest
+	defer r.Body.Close()
+
+	if err := handlerutils.ParseJSON(r, &payload); err != nil || payload == nil {
+		return servererrors.New(
+			http.StatusBadRequest,
+			servererrors.ErrInvalidRequestPayload.Error(),
+			nil,
+		)
+	}
+
+	payload.AdminID = middlewares.GetEntityIDFromContextKey(ctx)
+
+	if err := validate.StructFields(payload); err != nil {
+		return servererrors.New(
+			http.StatusUnprocessableEntity,
+			servererrors.ErrValidationFailed.Error(),
+			err,
+		)
+	}
+
+	synonym, err := h.service.createSynonym(ctx, payload)
+	if err != nil {
+		switch {
+		case errors.Is(err, servererrors.ErrValidationFailed):
+			return servererrors.New(
+				http.StatusUnprocessableEntity,
+				servererrors.ErrValidationFailed.Error(),
+				nil,
+			)
+
+		case errors.Is(err, servererrors.ErrSynonymAlreadyExists):
+			return servererrors.New(
+				http.StatusConflict,
+				servererrors.ErrSynonymAlreadyExists.Error(),
+				nil,
+			)
+
+		default:
+			return err
+		}
+	}
+
+	return handlerutils.WriteSuccessJSON(
+		w,
+		http.StatusCreated,
+		"synonym created",
+		synonym,
+	)
+}
+
+func (h *handler) deleteSynonymHandler(w http.ResponseWriter, r *http.Request) error {
+	synonymID, err := uuid.Parse(chi.URLParam(r, "synonymID"))
+	if err != nil {
+		return servererrors.New(
+			http.StatusBadRequest,
+			servererrors.ErrInvalidID.Error(),
+			nil,
+		)
+	}
+
+	if err = h.service.deleteSynonym(r.Context(), synonymID); err != nil {
+		if errors.Is(err, servererrors.ErrSynonymNotFound) {
+			return servererrors.New(
+				http.StatusNotFound,
+				servererrors.ErrSynonymNotFound.Error(),
+				nil,
+			)
+		}
+		return err
+	}
+
+	return handlerutils.WriteSuccessJSON(
+		w,
+		http.StatusOK,
+		"synonym deleted",
+		nil,
+	)
+}
 
 func getQueryItems(queriesParams url.Values) (*GetAllProductsRequestQuery, error) {
 	query := new(GetAllProductsRequestQuery)
 
 	query.FilterOpts.Category = queriesParams.Get("category")
 
+	query.FilterOpts.Search = strings.TrimSpace(queriesParams.Get("search"))
+
 	results := strings.Split(queriesParams.Get("sort"), ":")
 
+	// the best matches of a search come first, unless sorted otherwise.
 	query.SortOpts.SortBy = "created_at"
+	if query.FilterOpts.Search != "" {
+		query.SortOpts.SortBy = "relevance"
+	}
 	query.SortOpts.SortOpt = "desc"
 
 	if len(results) == 1 && results[0] != "" {
@@ -218,7 +735,10 @@ func getQueryItems(queriesParams url.Values) (*GetAllProductsRequestQuery, error
 		query.SortOpts.SortOpt = results[1]
 	}
 
-	query.FilterOpts.Search = queriesParams.Get("search")
+	// nothing to rank by without a search.
+	if query.SortOpts.SortBy == "relevance" && query.FilterOpts.Search == "" {
+		query.SortOpts.SortBy = "created_at"
+	}
 
 	query.PageOpts.Page = stringToUint64(
 		1,
@@ -230,6 +750,14 @@ func getQueryItems(queriesParams url.Values) (*GetAllProductsRequestQuery, error
 		queriesParams.Get("limit"),
 	)
 
+	query.PageOpts.Cursor = queriesParams.Get("cursor")
+
+	// products are listed by page, unless a cursor is asked for.
+	query.PageOpts.Mode = queriesParams.Get("mode")
+	if query.PageOpts.Cursor != "" {
+		query.PageOpts.Mode = "cursor"
+	}
+
 	query.FilterOpts.PriceMin = stringToFloat64(
 		0.00,
 		queriesParams.Get("priceMin"),
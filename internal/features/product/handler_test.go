@@ -0,0 +1,70 @@
+package product
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/eng-by-sjb/yellow-pines-e-commerce-backend/internal/validate"
+)
+
+func Test_getQueryItems_mode(t *testing.T) {
+	testCases := []struct {
+		name          string
+		query         string
+		expectedMode  string
+		expectedValid bool
+	}{
+		{
+			name:          "lists by page without a page",
+			query:         "limit=10",
+			expectedMode:  "",
+			expectedValid: true,
+		},
+		{
+			name:          "lists by page with a page",
+			query:         "page=2",
+			expectedMode:  "",
+			expectedValid: true,
+		},
+		{
+			name:          "lists by cursor if asked for",
+			query:         "mode=cursor",
+			expectedMode:  "cursor",
+			expectedValid: true,
+		},
+		{
+			name:          "lists by cursor with a cursor",
+			query:         "cursor=abc&page=2",
+			expectedMode:  "cursor",
+			expectedValid: true,
+		},
+		{
+			name:          "rejects an unknown mode",
+			query:         "mode=scroll",
+			expectedMode:  "scroll",
+			expectedValid: false,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			queries, err := url.ParseQuery(tc.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			queryItems, err := getQueryItems(queries)
+			if err != nil {
+				t.Fatalf("expected to get the query items, got: %v", err)
+			}
+
+			if queryItems.PageOpts.Mode != tc.expectedMode {
+				t.Fatalf("expected mode '%s', got: '%s'", tc.expectedMode, queryItems.PageOpts.Mode)
+			}
+
+			if err := validate.StructFields(queryItems); (err == nil) != tc.expectedValid {
+				t.Fatalf("expected the query items to be valid: %v, got: %v", tc.expectedValid, err)
+			}
+		})
+	}
+}
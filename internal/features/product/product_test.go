@@ -0,0 +1,343 @@
+package product
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/eng-by-sjb/yellow-pines-e-commerce-backend/internal/eventengine/event"
+	"github.com/eng-by-sjb/yellow-pines-e-commerce-backend/internal/servererrors"
+	"github.com/eng-by-sjb/yellow-pines-e-commerce-backend/internal/validate"
+	"github.com/google/uuid"
+)
+
+// fakeStore keeps products in memory and records what the service writes to
+// it. Methods of storer that it does not implement panic.
+type fakeStore struct {
+	storer
+
+	products map[uuid.UUID]*Product
+	trashed  map[uuid.UUID]bool
+
+	// purgeable are the trashed products that are old enough to be purged.
+	purgeable []uuid.UUID
+	// restoredMeanwhile is restored right before it would be purged.
+	restoredMeanwhile uuid.UUID
+	retentions        []time.Duration // the retentions products were listed or purged with.
+	// createdMeanwhile is created by another admin right before a product is
+	// created.
+	createdMeanwhile *Product
+
+	synonyms       []*Synonym
+	synonymsLookup []string // the words synonyms were last looked up for.
+
+	updatedFields map[string]any
+	events        []*event.Event
+}
+
+func newFakeStore(products ...*Product) *fakeStore {
+	s := &fakeStore{
+		products: make(map[uuid.UUID]*Product),
+		trashed:  make(map[uuid.UUID]bool),
+	}
+	for _, product := range products {
+		s.products[product.ProductID] = product
+	}
+
+	return s
+}
+
+func (s *fakeStore) findByName(ctx context.Context, name string) (*Product, error) {
+	for _, product := range s.products {
+		if product.Name == name {
+			return product, nil
+		}
+	}
+
+	return new(Product), nil
+}
+
+func (s *fakeStore) createOne(ctx context.Context, newProduct *CreateProductRequest, events ...*event.Event) error {
+	if s.createdMeanwhile != nil {
+		s.products[s.createdMeanwhile.ProductID] = s.createdMeanwhile
+	}
+
+	for _, product := range s.products {
+		if product.Name == newProduct.Name {
+			return servererrors.ErrProductAlreadyExists
+		}
+	}
+
+	s.products[newProduct.ProductID] = &Product{
+		ProductID: newProduct.ProductID,
+		AdminID:   newProduct.AdminID,
+		Name:      newProduct.Name,
+	}
+	s.events = append(s.events, events...)
+
+	return nil
+}
+
+func (s *fakeStore) updateOne(ctx context.Context, productID uuid.UUID, fields map[string]any, events ...*event.Event) (*Product, error) {
+	product, ok := s.products[productID]
+	if !ok {
+		return nil, servererrors.ErrProductNotFound
+	}
+
+	s.updatedFields = fields
+	s.events = append(s.events, events...)
+
+	return product, nil
+}
+
+func (s *fakeStore) softDeleteOne(ctx context.Context, productID uuid.UUID, events ...*event.Event) error {
+	if _, ok := s.products[productID]; !ok || s.trashed[productID] {
+		return servererrors.ErrProductNotFound
+	}
+
+	deletedAt := time.Now()
+	s.products[productID].DeletedAt = &deletedAt
+	s.trashed[productID] = true
+	s.events = append(s.events, events...)
+
+	return nil
+}
+
+func (s *fakeStore) restoreOne(ctx context.Context, productID uuid.UUID, events ...*event.Event) (*Product, error) {
+	if !s.trashed[productID] {
+		return nil, servererrors.ErrProductNotFound
+	}
+
+	delete(s.trashed, productID)
+	s.products[productID].DeletedAt = nil
+	s.events = append(s.events, events...)
+
+	return s.products[productID], nil
+}
+
+func (s *fakeStore) findTrashed(ctx context.Context, pageOpts *PageOpts, retention time.Duration) ([]*TrashedProductDTO, int, error) {
+	s.retentions = append(s.retentions, retention)
+
+	var products []*TrashedProductDTO
+	for productID := range s.trashed {
+		products = append(products, &TrashedProductDTO{
+			Product: *s.products[productID],
+			PurgeAt: s.products[productID].DeletedAt.Add(retention),
+		})
+	}
+
+	return products, len(products), nil
+}
+
+func (s *fakeStore) findTrashedBefore(ctx context.Context, retention time.Duration, limit int) ([]uuid.UUID, error) {
+	s.retentions = append(s.retentions, retention)
+
+	var productIDs []uuid.UUID
+	for _, productID := range s.purgeable {
+		if len(productIDs) == limit {
+			break
+		}
+		if s.trashed[productID] {
+			productIDs = append(productIDs, productID)
+		}
+	}
+
+	return productIDs, nil
+}
+
+func (s *fakeStore) purgeOne(ctx context.Context, productID uuid.UUID, retention time.Duration, events ...*event.Event) error {
+	s.retentions = append(s.retentions, retention)
+
+	if productID == s.restoredMeanwhile {
+		delete(s.trashed, productID)
+	}
+
+	if !s.trashed[productID] {
+		return servererrors.ErrProductNotFound
+	}
+
+	delete(s.trashed, productID)
+	delete(s.products, productID)
+	s.events = append(s.events, events...)
+
+	return nil
+}
+
+func (s *fakeStore) findSynonyms(ctx context.Context, words []string) ([]*Synonym, error) {
+	s.synonymsLookup = words
+
+	var found []*Synonym
+	for _, synonym := range s.synonyms {
+		if slices.Contains(words, synonym.Term) || slices.Contains(words, synonym.Synonym) {
+			found = append(found, synonym)
+		}
+	}
+
+	return found, nil
+}
+
+func (s *fakeStore) createSynonym(ctx context.Context, newSynonym *Synonym) error {
+	for _, synonym := range s.synonyms {
+		if synonym.Term == newSynonym.Term && synonym.Synonym == newSynonym.Synonym {
+			return servererrors.ErrSynonymAlreadyExists
+		}
+	}
+
+	s.synonyms = append(s.synonyms, newSynonym)
+	return nil
+}
+
+// eventNames returns the names of the events written to the store, in order.
+func (s *fakeStore) eventNames() []event.EventName {
+	names := make([]event.EventName, 0, len(s.events))
+	for _, written := range s.events {
+		names = append(names, written.Name)
+	}
+
+	return names
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
+
+func Test_updateProduct(t *testing.T) {
+	productID := uuid.New()
+	otherProductID := uuid.New()
+
+	testCases := []struct {
+		name             string
+		update           *UpdateProductRequest
+		expectedErr      error
+		expectedFields   map[string]any
+		expectedEvents   []event.EventName
+		expectedQuantity uint
+	}{
+		{
+			name: "updates the fields that are set",
+			update: &UpdateProductRequest{
+				Name:        ptr("  Pine Needle Tea  "),
+				Description: ptr(" A tea of pine needles. "),
+				ImageURL:    ptr(" https://example.com/tea.png "),
+				Price:       ptr(4.5),
+				Category:    ptr("drinks"),
+				IsActive:    ptr(false),
+			},
+			expectedFields: map[string]any{
+				"name":        "Pine Needle Tea",
+				"description": "A tea of pine needles.",
+				"image_url":   "https://example.com/tea.png",
+				"price":       4.5,
+				"category":    "drinks",
+				"is_active":   false,
+			},
+			expectedEvents: []event.EventName{event.ProductUpdatedEventName},
+		},
+		{
+			name: "keeps the name of the product itself",
+			update: &UpdateProductRequest{
+				Name: ptr("Pine Cone Jam"),
+			},
+			expectedFields: map[string]any{"name": "Pine Cone Jam"},
+			expectedEvents: []event.EventName{event.ProductUpdatedEventName},
+		},
+		{
+			name: "rejects the name of another product",
+			update: &UpdateProductRequest{
+				Name:  ptr(" Pine Resin Soap "),
+				Price: ptr(2.0),
+			},
+			expectedErr: servererrors.ErrProductAlreadyExists,
+		},
+		{
+			name:        "rejects an update without fields",
+			update:      &UpdateProductRequest{},
+			expectedErr: servererrors.ErrNoFieldsToUpdate,
+		},
+		{
+			name: "publishes a quantity of 0, i.e. sold out",
+			update: &UpdateProductRequest{
+				Quantity: ptr(uint(0)),
+			},
+			expectedFields:   map[string]any{},
+			expectedEvents:   []event.EventName{event.ProductUpdatedQuantityEventName},
+			expectedQuantity: 0,
+		},
+		{
+			name: "publishes the quantity apart from the other fields",
+			update: &UpdateProductRequest{
+				Price:    ptr(3.0),
+				Quantity: ptr(uint(12)),
+			},
+			expectedFields:   map[string]any{"price": 3.0},
+			expectedEvents:   []event.EventName{event.ProductUpdatedEventName, event.ProductUpdatedQuantityEventName},
+			expectedQuantity: 12,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			store := newFakeStore(
+				&Product{ProductID: productID, Name: "Pine Cone Jam"},
+				&Product{ProductID: otherProductID, Name: "Pine Resin Soap"},
+			)
+			s := &service{store: store}
+
+			tc.update.ProductID = productID
+			_, err := s.updateProduct(context.Background(), tc.update)
+			if !errors.Is(err, tc.expectedErr) {
+				t.Fatalf("expected error '%v', got: %v", tc.expectedErr, err)
+			}
+
+			if tc.expectedErr != nil {
+				if len(store.events) != 0 || store.updatedFields != nil {
+					t.Fatalf("expected the product not to be updated, got fields %v and events %v", store.updatedFields, store.eventNames())
+				}
+				return
+			}
+
+			if !reflect.DeepEqual(store.updatedFields, tc.expectedFields) {
+				t.Fatalf("expected fields %v, got: %v", tc.expectedFields, store.updatedFields)
+			}
+
+			if got := store.eventNames(); !reflect.DeepEqual(got, tc.expectedEvents) {
+				t.Fatalf("expected events %v, got: %v", tc.expectedEvents, got)
+			}
+
+			for _, written := range store.events {
+				switch payload := written.Payload.(type) {
+				case *event.ProductUpdatedEvent:
+					if payload.ProductID != productID {
+						t.Fatalf("expected the updated event of product '%s', got: '%s'", productID, payload.ProductID)
+					}
+
+				case *event.ProductQuantityUpdatedEvent:
+					if payload.ProductID != productID || payload.StockQuantity != tc.expectedQuantity {
+						t.Fatalf("expected quantity %d of product '%s', got: %d of '%s'", tc.expectedQuantity, productID, payload.StockQuantity, payload.ProductID)
+					}
+
+				default:
+					t.Fatalf("unexpected payload '%T'", written.Payload)
+				}
+
+				if written.Producer != producerName {
+					t.Fatalf("expected producer '%s', got: '%s'", producerName, written.Producer)
+				}
+			}
+		})
+	}
+}
+
+func Test_UpdateProductRequest_quantity(t *testing.T) {
+	update := UpdateProductRequest{
+		ProductID: uuid.New(),
+		Quantity:  ptr(uint(0)),
+	}
+
+	if err := validate.StructFields(update); err != nil {
+		t.Fatalf("expected a quantity of 0 to be valid, got: %v", err)
+	}
+}
@@ -0,0 +1,117 @@
+package product
+
+import (
+	"context"
+	"time"
+
+	"github.com/eng-by-sjb/yellow-pines-e-commerce-backend/internal/eventengine/event"
+	"github.com/eng-by-sjb/yellow-pines-e-commerce-backend/internal/eventengine/saga"
+	"github.com/google/uuid"
+)
+
+// provisioningSagaName is the saga that creates a product and then its
+// inventory, and deletes the product again if its inventory can not be
+// created. Its instances are keyed by product id.
+const provisioningSagaName = "product.provisioning"
+
+// provisioningWaitTimeout is how long creating a product waits for its
+// inventory before reporting it as still being provisioned.
+const provisioningWaitTimeout = 3 * time.Second
+
+type sagaOrchestrator interface {
+	Register(definition *saga.Definition) error
+	Start(ctx context.Context, sagaName string, key string, data any) (*saga.Instance, error)
+	Get(ctx context.Context, sagaName string, key string) (*saga.Instance, error)
+	Wait(ctx context.Context, sagaName string, key string) (*saga.Instance, error)
+}
+
+func (s *service) provisioningSaga() *saga.Definition {
+	productIDOf := func(payload *event.InventoryCreatedEvent) string {
+		return payload.ProductID.String()
+	}
+
+	failedProductIDOf := func(payload *event.InventoryCreationFailedEvent) string {
+		return payload.ProductID.String()
+	}
+
+	return &saga.Definition{
+		Name: provisioningSagaName,
+		Steps: []*saga.Step{
+			{
+				Name:       "create_product",
+				Action:     s.createProductStep,
+				Compensate: s.deleteProductStep,
+			},
+			{
+				// the inventory feature creates the inventory once it receives
+				// the product.created event.
+				Name:        "create_inventory",
+				CompletedBy: saga.On(event.InventoryCreatedEventName, productIDOf),
+				FailedBy:    saga.On(event.InventoryCreationFailedEventName, failedProductIDOf),
+				Timeout:     30 * time.Second,
+			},
+		},
+	}
+}
+
+// createProductStep creates the product the saga instance was started with,
+// and writes the product.created event to the outbox with it.
+func (s *service) createProductStep(ctx context.Context, instance *saga.Instance) error {
+	var newProduct CreateProductRequest
+	if err := instance.Decode(&newProduct); err != nil {
+		return err
+	}
+
+	newEvent := &event.ProductCreatedEvent{
+		ProductPayload: event.ProductPayload{
+			ProductID:     newProduct.ProductID,
+			StockQuantity: newProduct.Quantity,
+		},
+	}
+
+	return s.store.createOne(
+		ctx,
+		&newProduct,
+		event.New(
+			ctx,
+			producerName,
+			newEvent.GetEventName(),
+			newEvent,
+		),
+	)
+}
+
+func (s *service) deleteProductStep(ctx context.Context, instance *saga.Instance) error {
+	productID, err := uuid.Parse(instance.Key)
+	if err != nil {
+		return err
+	}
+
+	// a product that was never provisioned is not moved to the trash. Its
+	// product.created event may be relayed already, so the inventory feature
+	// is told the product is gone for good.
+	deletedEvent := &event.ProductDeletedEvent{
+		Name:        event.ProductDeletedEventName,
+		ProductID:   productID,
+		Permanently: true,
+	}
+
+	return s.store.deleteOne(
+		ctx,
+		productID,
+		event.New(ctx, producerName, deletedEvent.EventName(), deletedEvent),
+	)
+}
+
+func newProvisioningStatusDTO(instance *saga.Instance) *ProvisioningStatusDTO {
+	productID, _ := uuid.Parse(instance.Key)
+
+	return &ProvisioningStatusDTO{
+		ProductID:   productID,
+		Provisioned: instance.Status == saga.StatusCompleted,
+		Status:      string(instance.Status),
+		Step:        instance.StepName,
+		Error:       instance.Error,
+		UpdatedAt:   instance.UpdatedAt,
+	}
+}
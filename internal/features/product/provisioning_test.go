@@ -0,0 +1,91 @@
+package product
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/eng-by-sjb/yellow-pines-e-commerce-backend/internal/eventengine/eventenginetest"
+	"github.com/eng-by-sjb/yellow-pines-e-commerce-backend/internal/eventengine/saga"
+	"github.com/eng-by-sjb/yellow-pines-e-commerce-backend/internal/servererrors"
+	"github.com/google/uuid"
+)
+
+func Test_createProduct_nameTaken(t *testing.T) {
+	testCases := []struct {
+		name             string
+		existing         *Product
+		createdMeanwhile *Product
+		expectedSaga     bool
+	}{
+		{
+			name:     "rejects the name of an existing product",
+			existing: &Product{ProductID: uuid.New(), Name: "Pine Cone Jam"},
+		},
+		{
+			name:             "rejects a name taken while the product is provisioned",
+			createdMeanwhile: &Product{ProductID: uuid.New(), Name: "Pine Cone Jam"},
+			expectedSaga:     true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			doneCh := make(chan struct{})
+			internalSrvWG := sync.WaitGroup{}
+			t.Cleanup(func() {
+				close(doneCh)
+				internalSrvWG.Wait()
+			})
+
+			engine := eventenginetest.NewSynchronous(t)
+			orchestrator := saga.NewOrchestrator(
+				&saga.OrchestratorConfig{
+					DoneCh:        doneCh,
+					InternalSrvWG: &internalSrvWG,
+					EventEngine:   engine,
+				},
+			)
+
+			store := newFakeStore()
+			if tc.existing != nil {
+				store.products[tc.existing.ProductID] = tc.existing
+			}
+			store.createdMeanwhile = tc.createdMeanwhile
+
+			s, err := NewService(store, orchestrator, 0)
+			if err != nil {
+				t.Fatalf("expected to create the service, got: %v", err)
+			}
+
+			newProduct := &CreateProductRequest{
+				AdminID: uuid.New(),
+				Name:    " Pine Cone Jam ",
+			}
+			_, err = s.createProduct(context.Background(), newProduct)
+			if err != servererrors.ErrProductAlreadyExists {
+				t.Fatalf("expected error '%v', got: %v", servererrors.ErrProductAlreadyExists, err)
+			}
+
+			if len(store.events) != 0 || len(store.products) != 1 {
+				t.Fatalf("expected the product not to be created, got %d products and events %v", len(store.products), store.eventNames())
+			}
+
+			instance, err := orchestrator.Get(context.Background(), provisioningSagaName, newProduct.ProductID.String())
+			if !tc.expectedSaga {
+				if !errors.Is(err, saga.ErrInstanceNotFound) {
+					t.Fatalf("expected the product not to be provisioned, got: %v", err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("expected to get the saga instance, got: %v", err)
+			}
+			if instance.Status != saga.StatusCompensated {
+				t.Fatalf("expected status '%s', got: '%s'", saga.StatusCompensated, instance.Status)
+			}
+		})
+	}
+}
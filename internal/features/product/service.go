@@ -2,127 +2,304 @@ package product
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"slices"
 	"strings"
+	"time"
 
-	"github.com/eng-by-sjb/yellow-pines-e-commerce-backend/internal/eventengine"
 	"github.com/eng-by-sjb/yellow-pines-e-commerce-backend/internal/eventengine/event"
+	"github.com/eng-by-sjb/yellow-pines-e-commerce-backend/internal/eventengine/saga"
 
 	"github.com/eng-by-sjb/yellow-pines-e-commerce-backend/internal/servererrors"
 	"github.com/google/uuid"
 )
 
+// producerName is the producer of the events the product feature publishes.
+const producerName = "product"
+
 type storer interface {
-	createOne(ctx context.Context, product *CreateProductRequest) (uuid.UUID, error)
+	createOne(ctx context.Context, product *CreateProductRequest, events ...*event.Event) error
 	findAll(ctx context.Context, queryItems *GetAllProductsRequestQuery) ([]*ProductAndInventoryDTO, int, error)
+	findPage(ctx context.Context, queryItems *GetAllProductsRequestQuery, cursor *productCursor) ([]*ProductAndInventoryDTO, error)
 	findByID(ctx context.Context, pdID uuid.UUID) (*ProductAndInventoryDTO, error)
 	findByName(ctx context.Context, name string) (*Product, error)
-	deleteOne(ctx context.Context, pdID uuid.UUID) error
+	updateOne(ctx context.Context, productID uuid.UUID, fields map[string]any, events ...*event.Event) (*Product, error)
+	deleteOne(ctx context.Context, pdID uuid.UUID, events ...*event.Event) error
+	softDeleteOne(ctx context.Context, productID uuid.UUID, events ...*event.Event) error
+	restoreOne(ctx context.Context, productID uuid.UUID, events ...*event.Event) (*Product, error)
+	findTrashed(ctx context.Context, pageOpts *PageOpts, retention time.Duration) ([]*TrashedProductDTO, int, error)
+	findTrashedBefore(ctx context.Context, retention time.Duration, limit int) ([]uuid.UUID, error)
+	purgeOne(ctx context.Context, productID uuid.UUID, retention time.Duration, events ...*event.Event) error
+	suggest(ctx context.Context, terms []string, threshold float64, limit int) ([]*SuggestionDTO, error)
+	findSynonyms(ctx context.Context, words []string) ([]*Synonym, error)
+	findAllSynonyms(ctx context.Context) ([]*Synonym, error)
+	createSynonym(ctx context.Context, synonym *Synonym) error
+	deleteSynonym(ctx context.Context, synonymID uuid.UUID) error
 }
 
 type service struct {
-	store storer
-	// inventoryService inventoryServicer // todo: remove this and replace with event engine. or keep it.
-	eventEngine eventengine.Publisher
+	store          storer
+	sagas          sagaOrchestrator
+	trashRetention time.Duration // how long a deleted product stays in the trash before it is purged.
 }
 
-func NewService(productStore storer, eventEngine eventengine.Publisher) *service {
-	return &service{
-		store:       productStore,
-		eventEngine: eventEngine,
+// NewService returns the product service and registers its sagas with sagas.
+// Deleted products stay in the trash for trashRetention, or
+// DefaultTrashRetention if it is 0.
+func NewService(productStore storer, sagas sagaOrchestrator, trashRetention time.Duration) (*service, error) {
+	if trashRetention <= 0 {
+		trashRetention = DefaultTrashRetention
+	}
+
+	s := &service{
+		store:          productStore,
+		sagas:          sagas,
+		trashRetention: trashRetention,
 	}
+
+	if err := sagas.Register(s.provisioningSaga()); err != nil {
+		return nil, fmt.Errorf("failed to register the '%s' saga: %w", provisioningSagaName, err)
+	}
+
+	return s, nil
 }
 
-func (s *service) createProduct(ctx context.Context, newProduct *CreateProductRequest) error {
+// createProduct provisions newProduct with the product provisioning saga, and
+// reports whether the product was fully provisioned, i.e. has its inventory,
+// within provisioningWaitTimeout.
+func (s *service) createProduct(ctx context.Context, newProduct *CreateProductRequest) (*ProvisioningStatusDTO, error) {
 	newProduct.Name = strings.TrimSpace(newProduct.Name)
 	newProduct.Description = strings.TrimSpace(newProduct.Description)
 	newProduct.ImageURL = strings.TrimSpace(newProduct.ImageURL)
 
 	product, err := s.store.findByName(ctx, newProduct.Name)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	if product.ProductID != uuid.Nil {
-		return servererrors.ErrProductAlreadyExists
+		return nil, servererrors.ErrProductAlreadyExists
 	}
 
-	pdID, err := s.store.createOne(
+	// the product id is generated here rather than by the db so the
+	// product.created event can be written to the outbox in the same
+	// transaction as the product itself, and the saga can be keyed by it.
+	newProduct.ProductID = uuid.New()
+
+	_, err = s.sagas.Start(
 		ctx,
+		provisioningSagaName,
+		newProduct.ProductID.String(),
 		newProduct,
 	)
+	if errors.Is(err, servererrors.ErrProductAlreadyExists) {
+		return nil, servererrors.ErrProductAlreadyExists
+	}
 	if err != nil {
-		return err
+		return nil, err
+	}
+
+	waitCtx, cancel := context.WithTimeout(ctx, provisioningWaitTimeout)
+	defer cancel()
+
+	instance, err := s.sagas.Wait(
+		waitCtx,
+		provisioningSagaName,
+		newProduct.ProductID.String(),
+	)
+	if err != nil {
+		return nil, err
 	}
 
-	// if err := s.inventoryService.CreateInventory(
-	// 	ctx,
-	// 	pdID,
-	// 	newProduct.Quantity,
-	// ); err != nil {
-	// 	fErr := err
-	// 	if err := s.store.deleteOne(ctx, pdID); err != nil {
-	// 		return fmt.Errorf(
-	// 			"error deleting product after inventory creation failed. inventory: %w, product: %w", // todo: revisit or keep it.
-	// 			fErr,
-	// 			err,
-	// 		)
-	// 	}
-	// }
-
-	newEvent := &event.ProductCreatedEvent{
-		ProductPayload: event.ProductPayload{
-			ProductID:     pdID,
-			StockQuantity: newProduct.Quantity,
-		},
-	}
-
-	err = s.eventEngine.Publish(
-		&event.Event{
-			Name:    newEvent.GetEventName(),
-			Payload: newEvent,
-		},
+	return newProvisioningStatusDTO(instance), nil
+}
+
+func (s *service) getProvisioningStatus(ctx context.Context, productID uuid.UUID) (*ProvisioningStatusDTO, error) {
+	instance, err := s.sagas.Get(
+		ctx,
+		provisioningSagaName,
+		productID.String(),
 	)
 	if err != nil {
-		firstErr := err
-
-		if err := s.store.deleteOne(ctx, pdID); err != nil {
-			return fmt.Errorf(
-				"error deleting newly created product after publishing event failed. eventEnginePublish: %w, product: %w",
-				firstErr,
-				err,
-			)
+		if errors.Is(err, saga.ErrInstanceNotFound) {
+			return nil, servererrors.ErrProductNotFound
 		}
-
-		return fmt.Errorf(
-			"error publishing event after product creation. eventEnginePublish: %w",
-			firstErr,
-		)
+		return nil, err
 	}
 
-	return nil
+	return newProvisioningStatusDTO(instance), nil
 }
 
 func (s *service) getAllProducts(ctx context.Context, queryItems *GetAllProductsRequestQuery) ([]*ProductAndInventoryDTO, int, error) {
+	if err := s.expandSearch(ctx, queryItems); err != nil {
+		return nil, 0, err
+	}
+
 	return s.store.findAll(ctx, queryItems)
 }
 
+// getProductsPage returns the products after or before the cursor of the
+// PageOpts of queryItems, or the first ones if it has none, together with the
+// cursors of the pages next to them. A cursor is empty if there is no such
+// page.
+func (s *service) getProductsPage(
+	ctx context.Context,
+	queryItems *GetAllProductsRequestQuery,
+) (products []*ProductAndInventoryDTO, nextCursor string, prevCursor string, err error) {
+	cursor := &productCursor{Direction: cursorNext}
+	if queryItems.PageOpts.Cursor != "" {
+		cursor, err = decodeCursor(queryItems.PageOpts.Cursor, queryItems)
+		if err != nil {
+			return nil, "", "", err
+		}
+	}
+
+	if err := s.expandSearch(ctx, queryItems); err != nil {
+		return nil, "", "", err
+	}
+
+	products, err = s.store.findPage(ctx, queryItems, cursor)
+	if err != nil {
+		return nil, "", "", err
+	}
+
+	hasMore := len(products) > int(queryItems.PageOpts.Limit)
+	if hasMore {
+		products = products[:queryItems.PageOpts.Limit]
+	}
+
+	if len(products) == 0 {
+		return products, "", "", nil
+	}
+
+	// the products before a cursor are found closest to it first.
+	if cursor.Direction == cursorPrev {
+		slices.Reverse(products)
+	}
+
+	first, last := products[0], products[len(products)-1]
+	switch cursor.Direction {
+	case cursorPrev:
+		// came back from the page after.
+		nextCursor = newCursor(queryItems, last, cursorNext).encode()
+		if hasMore {
+			prevCursor = newCursor(queryItems, first, cursorPrev).encode()
+		}
+
+	default:
+		if hasMore {
+			nextCursor = newCursor(queryItems, last, cursorNext).encode()
+		}
+		// the first page has no page before.
+		if cursor.ProductID != uuid.Nil {
+			prevCursor = newCursor(queryItems, first, cursorPrev).encode()
+		}
+	}
+
+	return products, nextCursor, prevCursor, nil
+}
+
+// expandSearch sets the synonyms of the search of queryItems, if any.
+func (s *service) expandSearch(ctx context.Context, queryItems *GetAllProductsRequestQuery) error {
+	if queryItems.FilterOpts.Search == "" {
+		return nil
+	}
+
+	searchSynonyms, err := s.searchSynonyms(ctx, queryItems.FilterOpts.Search)
+	if err != nil {
+		return err
+	}
+	queryItems.FilterOpts.SearchSynonyms = searchSynonyms
+
+	return nil
+}
+
 func (s *service) getProduct(ctx context.Context, productID uuid.UUID) (*ProductAndInventoryDTO, error) {
 	return s.store.findByID(ctx, productID)
 }
 
-func (s *service) deleteProduct(ctx context.Context, productID uuid.UUID) error {
-	err := s.store.deleteOne(
-		ctx,
-		productID,
-	)
+// updateProduct updates the fields of update that are set, and publishes a
+// product.updated event through the outbox. A new quantity is not stored with
+// the product but published in a product.updated.quantity event, which the
+// inventory feature updates the stock of the product with.
+func (s *service) updateProduct(ctx context.Context, update *UpdateProductRequest) (*Product, error) {
+	fields := make(map[string]any)
 
-	if err != nil {
-		return fmt.Errorf(
-			"err happened: %w",
-			err,
+	if update.Name != nil {
+		name := strings.TrimSpace(*update.Name)
+
+		product, err := s.store.findByName(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+
+		if product.ProductID != uuid.Nil && product.ProductID != update.ProductID {
+			return nil, servererrors.ErrProductAlreadyExists
+		}
+
+		fields["name"] = name
+	}
+
+	if update.Description != nil {
+		fields["description"] = strings.TrimSpace(*update.Description)
+	}
+
+	if update.ImageURL != nil {
+		fields["image_url"] = strings.TrimSpace(*update.ImageURL)
+	}
+
+	if update.Price != nil {
+		fields["price"] = *update.Price
+	}
+
+	if update.Category != nil {
+		fields["category"] = *update.Category
+	}
+
+	if update.IsActive != nil {
+		fields["is_active"] = *update.IsActive
+	}
+
+	if len(fields) == 0 && update.Quantity == nil {
+		return nil, servererrors.ErrNoFieldsToUpdate
+	}
+
+	var events []*event.Event
+
+	if len(fields) > 0 {
+		updatedEvent := &event.ProductUpdatedEvent{
+			Name: event.ProductUpdatedEventName,
+			ProductPayload: event.ProductPayload{
+				ProductID: update.ProductID,
+			},
+		}
+
+		events = append(
+			events,
+			event.New(ctx, producerName, updatedEvent.EventName(), updatedEvent),
 		)
 	}
 
-	return nil
+	if update.Quantity != nil {
+		quantityUpdatedEvent := &event.ProductQuantityUpdatedEvent{
+			Name: event.ProductUpdatedQuantityEventName,
+			ProductPayload: event.ProductPayload{
+				ProductID:     update.ProductID,
+				StockQuantity: *update.Quantity,
+			},
+		}
+
+		events = append(
+			events,
+			event.New(ctx, producerName, quantityUpdatedEvent.EventName(), quantityUpdatedEvent),
+		)
+	}
+
+	return s.store.updateOne(
+		ctx,
+		update.ProductID,
+		fields,
+		events...,
+	)
 }
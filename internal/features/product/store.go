@@ -5,9 +5,16 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"sort"
+	"strconv"
 	"strings"
+	"time"
 
+	"github.com/eng-by-sjb/yellow-pines-e-commerce-backend/internal/eventengine/event"
+	"github.com/eng-by-sjb/yellow-pines-e-commerce-backend/internal/eventengine/outbox"
+	"github.com/eng-by-sjb/yellow-pines-e-commerce-backend/internal/servererrors"
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 )
 
 type store struct {
@@ -20,29 +27,60 @@ func NewStore(db *sql.DB) *store {
 	}
 }
 
-func (s *store) createOne(ctx context.Context, product *CreateProductRequest) (uuid.UUID, error) {
-	Query := `INSERT INTO products(admin_id, name, description, image_url, price, category) VALUES($1, $2, $3, $4, $5, $6) RETURNING product_id`
+// createOne inserts product and writes events to the outbox in a single
+// transaction, so either both are stored or neither is. It returns
+// [servererrors.ErrProductAlreadyExists] if a product has the name already.
+func (s *store) createOne(ctx context.Context, product *CreateProductRequest, events ...*event.Event) error {
+	query := `INSERT INTO products(product_id, admin_id, name, description, image_url, price, category) VALUES($1, $2, $3, $4, $5, $6, $7)`
 
-	var productID uuid.UUID
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf(
+			"failed to begin transaction in product store: %w",
+			err,
+		)
+	}
+	defer tx.Rollback()
 
-	err := s.db.QueryRowContext(
+	_, err = tx.ExecContext(
 		ctx,
-		Query,
+		query,
+		product.ProductID,
 		product.AdminID,
 		product.Name,
 		product.Description,
 		product.ImageURL,
 		product.Price,
 		product.Category,
-	).Scan(&productID)
+	)
 	if err != nil {
-		return uuid.Nil, fmt.Errorf(
+		// another admin may have created a product of the same name since
+		// it was looked up.
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code.Name() == "unique_violation" {
+			return servererrors.ErrProductAlreadyExists
+		}
+
+		return fmt.Errorf(
 			"failed to insert new product in product store: %w",
 			err,
 		)
 	}
 
-	return productID, nil
+	for _, newEvent := range events {
+		if err := outbox.Insert(ctx, tx, newEvent); err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf(
+			"failed to commit new product in product store: %w",
+			err,
+		)
+	}
+
+	return nil
 }
 
 func (s *store) findAll(
@@ -51,6 +89,7 @@ func (s *store) findAll(
 ) (products []*ProductAndInventoryDTO, count int, err error) {
 	query, countQuery, queryParams := generateQueryAndParams(
 		queryItems,
+		nil,
 	)
 
 	err = s.db.QueryRowContext(
@@ -67,9 +106,41 @@ func (s *store) findAll(
 		)
 	}
 
+	products, err = s.queryProducts(ctx, queryItems, query, queryParams...)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return products, count, nil
+}
+
+// findPage returns up to one more than the limit of queryItems of the products
+// after cursor, or before it if its direction is [cursorPrev], closest to it
+// first. The one more tells whether there are more products in that
+// direction.
+func (s *store) findPage(
+	ctx context.Context,
+	queryItems *GetAllProductsRequestQuery,
+	cursor *productCursor,
+) ([]*ProductAndInventoryDTO, error) {
+	query, _, queryParams := generateQueryAndParams(
+		queryItems,
+		cursor,
+	)
+
+	return s.queryProducts(ctx, queryItems, query, queryParams...)
+}
+
+// queryProducts runs a query from generateQueryAndParams.
+func (s *store) queryProducts(
+	ctx context.Context,
+	queryItems *GetAllProductsRequestQuery,
+	query string,
+	queryParams ...any,
+) (products []*ProductAndInventoryDTO, err error) {
 	rows, err := s.db.QueryContext(ctx, query, queryParams...)
 	if err != nil {
-		return nil, 0, fmt.Errorf(
+		return nil, fmt.Errorf(
 			"failed to get all products from product store: %w",
 			err,
 		)
@@ -78,6 +149,7 @@ func (s *store) findAll(
 
 	for rows.Next() {
 		var product ProductAndInventoryDTO
+		var highlights SearchHighlightsDTO
 		err := rows.Scan(
 			&product.ProductID,
 			&product.Name,
@@ -89,17 +161,31 @@ func (s *store) findAll(
 			&product.CreatedAt,
 			&product.UpdatedAt,
 			&product.StockQuantity,
+			&product.Relevance,
+			&highlights.Name,
+			&highlights.Description,
 		)
 		if err != nil {
-			return nil, 0, fmt.Errorf(
+			return nil, fmt.Errorf(
 				"failed to scan product from product store: %w",
 				err,
 			)
 		}
+
+		if queryItems.FilterOpts.Search != "" {
+			product.Highlights = &highlights
+		}
 		products = append(products, &product)
 	}
 
-	return products, count, nil
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf(
+			"failed to get all products from product store: %w",
+			err,
+		)
+	}
+
+	return products, nil
 }
 
 func (s *store) findByID(ctx context.Context, productID uuid.UUID) (*ProductAndInventoryDTO, error) {
@@ -107,7 +193,7 @@ func (s *store) findByID(ctx context.Context, productID uuid.UUID) (*ProductAndI
 	p.product_id, p.name, p.description, p.image_url, p.price, p.category,
 	p.is_active, p.created_at, p.updated_at, i.stock_quantity
 	FROM products p 
-	INNER JOIN inventory i ON p.product_id = i.product_id WHERE p.product_id = $1`
+	INNER JOIN inventory i ON p.product_id = i.product_id WHERE p.product_id = $1 AND p.deleted_at IS NULL`
 	// query := `SELECT * FROM products WHERE product_id = $1`
 
 	row := s.db.QueryRowContext(ctx, query, productID)
@@ -125,6 +211,10 @@ func (s *store) findByID(ctx context.Context, productID uuid.UUID) (*ProductAndI
 		&product.StockQuantity,
 	)
 	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return &product, servererrors.ErrProductNotFound
+		}
+
 		return &product, fmt.Errorf(
 			"failed to scan product from product store: %w",
 			err,
@@ -135,7 +225,9 @@ func (s *store) findByID(ctx context.Context, productID uuid.UUID) (*ProductAndI
 }
 
 func (s *store) findByName(ctx context.Context, name string) (*Product, error) {
-	query := `SELECT * FROM products WHERE name = $1`
+	// a product in the trash keeps its name, so it can be restored.
+	query := `SELECT product_id, admin_id, name, description, image_url, price, category, is_active, created_at, updated_at
+	FROM products WHERE name = $1`
 	rows, err := s.db.QueryContext(ctx, query, name)
 	if err != nil {
 		return nil, err
@@ -160,12 +252,223 @@ func (s *store) findByName(ctx context.Context, name string) (*Product, error) {
 	return product, nil
 }
 
-func (s *store) deleteOne(ctx context.Context, pdID uuid.UUID) error {
+// deleteOne deletes the product with pdID and writes events to the outbox, in
+// a single transaction. A product that does not exist is not an error, so the
+// events are written either way.
+func (s *store) deleteOne(ctx context.Context, pdID uuid.UUID, events ...*event.Event) error {
 	query := `DELETE FROM products WHERE product_id = $1`
-	_, err := s.db.ExecContext(ctx, query, pdID)
+
+	return s.withOutbox(ctx, func(tx *sql.Tx) error {
+		if _, err := tx.ExecContext(ctx, query, pdID); err != nil {
+			return fmt.Errorf(
+				"failed to delete product from product store: %w",
+				err,
+			)
+		}
+		return nil
+	}, events...)
+}
+
+// softDeleteOne moves the product with productID to the trash and writes
+// events to the outbox, in a single transaction. It returns
+// [servererrors.ErrProductNotFound] if there is no such product outside of
+// the trash.
+func (s *store) softDeleteOne(ctx context.Context, productID uuid.UUID, events ...*event.Event) error {
+	query := `UPDATE products SET deleted_at = NOW(), updated_at = NOW() WHERE product_id = $1 AND deleted_at IS NULL`
+
+	return s.withOutbox(ctx, func(tx *sql.Tx) error {
+		return execOne(ctx, tx, "failed to move product to the trash in product store", query, productID)
+	}, events...)
+}
+
+// restoreOne moves the product with productID out of the trash and writes
+// events to the outbox, in a single transaction. It returns the restored
+// product, or [servererrors.ErrProductNotFound] if it is not in the trash.
+func (s *store) restoreOne(ctx context.Context, productID uuid.UUID, events ...*event.Event) (*Product, error) {
+	query := `UPDATE products SET deleted_at = NULL, updated_at = NOW() WHERE product_id = $1 AND deleted_at IS NOT NULL
+	RETURNING product_id, admin_id, name, description, image_url, price, category, is_active, created_at, updated_at`
+
+	product := new(Product)
+	err := s.withOutbox(ctx, func(tx *sql.Tx) error {
+		err := tx.QueryRowContext(ctx, query, productID).Scan(
+			&product.ProductID,
+			&product.AdminID,
+			&product.Name,
+			&product.Description,
+			&product.ImageURL,
+			&product.Price,
+			&product.Category,
+			&product.IsActive,
+			&product.CreatedAt,
+			&product.UpdatedAt,
+		)
+		if errors.Is(err, sql.ErrNoRows) {
+			return servererrors.ErrProductNotFound
+		}
+		if err != nil {
+			return fmt.Errorf(
+				"failed to restore product in product store: %w",
+				err,
+			)
+		}
+
+		return nil
+	}, events...)
+	if err != nil {
+		return nil, err
+	}
+
+	return product, nil
+}
+
+// findTrashed returns a page of the products in the trash, the most recently
+// deleted first, together with how many products are in the trash. They are
+// purged once they have been in the trash for retention.
+func (s *store) findTrashed(ctx context.Context, pageOpts *PageOpts, retention time.Duration) ([]*TrashedProductDTO, int, error) {
+	var count int
+	err := s.db.QueryRowContext(
+		ctx,
+		`SELECT COUNT(*) FROM products WHERE deleted_at IS NOT NULL`,
+	).Scan(&count)
 	if err != nil {
+		return nil, 0, fmt.Errorf(
+			"failed to get trashed products count from product store: %w",
+			err,
+		)
+	}
+
+	// purge_at is computed like the cutoff of findTrashedBefore, so a product
+	// is purged when it says.
+	rows, err := s.db.QueryContext(
+		ctx,
+		`SELECT product_id, admin_id, name, description, image_url, price, category, is_active, created_at, updated_at, deleted_at,
+		deleted_at + $3::interval AS purge_at
+		FROM products WHERE deleted_at IS NOT NULL
+		ORDER BY deleted_at DESC, product_id
+		LIMIT $1 OFFSET $2`,
+		pageOpts.Limit,
+		(pageOpts.Page-1)*pageOpts.Limit,
+		interval(retention),
+	)
+	if err != nil {
+		return nil, 0, fmt.Errorf(
+			"failed to get trashed products from product store: %w",
+			err,
+		)
+	}
+	defer rows.Close()
+
+	var products []*TrashedProductDTO
+	for rows.Next() {
+		product := new(TrashedProductDTO)
+		err := rows.Scan(
+			&product.ProductID,
+			&product.AdminID,
+			&product.Name,
+			&product.Description,
+			&product.ImageURL,
+			&product.Price,
+			&product.Category,
+			&product.IsActive,
+			&product.CreatedAt,
+			&product.UpdatedAt,
+			&product.DeletedAt,
+			&product.PurgeAt,
+		)
+		if err != nil {
+			return nil, 0, fmt.Errorf(
+				"failed to scan trashed product from product store: %w",
+				err,
+			)
+		}
+		products = append(products, product)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, 0, fmt.Errorf(
+			"failed to get trashed products from product store: %w",
+			err,
+		)
+	}
+
+	return products, count, nil
+}
+
+// findTrashedBefore returns the ids of up to limit products that have been in
+// the trash for longer than retention, the oldest first.
+func (s *store) findTrashedBefore(ctx context.Context, retention time.Duration, limit int) ([]uuid.UUID, error) {
+	// the cutoff is computed by the db, whose clock deleted_at was set with.
+	rows, err := s.db.QueryContext(
+		ctx,
+		`SELECT product_id FROM products WHERE deleted_at < NOW() - $1::interval ORDER BY deleted_at LIMIT $2`,
+		interval(retention),
+		limit,
+	)
+	if err != nil {
+		return nil, fmt.Errorf(
+			"failed to get products to purge from product store: %w",
+			err,
+		)
+	}
+	defer rows.Close()
+
+	var productIDs []uuid.UUID
+	for rows.Next() {
+		var productID uuid.UUID
+		if err := rows.Scan(&productID); err != nil {
+			return nil, fmt.Errorf(
+				"failed to scan product to purge from product store: %w",
+				err,
+			)
+		}
+		productIDs = append(productIDs, productID)
+	}
+
+	return productIDs, rows.Err()
+}
+
+// purgeOne deletes the product with productID for good if it has been in the
+// trash for longer than retention, and writes events to the outbox, in a
+// single transaction. It returns [servererrors.ErrProductNotFound] otherwise,
+// e.g. when the product was restored meanwhile.
+func (s *store) purgeOne(ctx context.Context, productID uuid.UUID, retention time.Duration, events ...*event.Event) error {
+	query := `DELETE FROM products WHERE product_id = $1 AND deleted_at < NOW() - $2::interval`
+
+	return s.withOutbox(ctx, func(tx *sql.Tx) error {
+		return execOne(ctx, tx, "failed to purge product from product store", query, productID, interval(retention))
+	}, events...)
+}
+
+// interval returns d as a postgres interval.
+func interval(d time.Duration) string {
+	return fmt.Sprintf("%d microseconds", d.Microseconds())
+}
+
+// withOutbox calls write and writes events to the outbox in a single
+// transaction, which is only committed if write succeeded.
+func (s *store) withOutbox(ctx context.Context, write func(tx *sql.Tx) error, events ...*event.Event) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf(
+			"failed to begin transaction in product store: %w",
+			err,
+		)
+	}
+	defer tx.Rollback()
+
+	if err := write(tx); err != nil {
+		return err
+	}
+
+	for _, newEvent := range events {
+		if err := outbox.Insert(ctx, tx, newEvent); err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
 		return fmt.Errorf(
-			"failed to delete product from product store: %w",
+			"failed to commit transaction in product store: %w",
 			err,
 		)
 	}
@@ -173,8 +476,108 @@ func (s *store) deleteOne(ctx context.Context, pdID uuid.UUID) error {
 	return nil
 }
 
-func (s *store) updateOne(ctx context.Context, productID uuid.UUID, fields map[string]any) error {
-	panic("unimplemented")
+// execOne executes query, which must change exactly one product. It returns
+// [servererrors.ErrProductNotFound] if it changed none.
+func execOne(ctx context.Context, tx *sql.Tx, errMsg string, query string, args ...any) error {
+	result, err := tx.ExecContext(ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("%s: %w", errMsg, err)
+	}
+
+	changed, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", errMsg, err)
+	}
+
+	if changed == 0 {
+		return servererrors.ErrProductNotFound
+	}
+
+	return nil
+}
+
+// updateOne sets the columns of fields of the product with productID, bumps
+// its updated_at and writes events to the outbox, in a single transaction.
+// It returns the updated product, or [servererrors.ErrProductNotFound].
+//
+// The keys of fields are column names, which are not escaped, so they must
+// never come from the request.
+func (s *store) updateOne(
+	ctx context.Context,
+	productID uuid.UUID,
+	fields map[string]any,
+	events ...*event.Event,
+) (*Product, error) {
+	columns := make([]string, 0, len(fields))
+	for column := range fields {
+		columns = append(columns, column)
+	}
+	sort.Strings(columns)
+
+	setClauses := make([]string, 0, len(columns)+1)
+	queryParams := []any{productID}
+	for _, column := range columns {
+		queryParams = append(queryParams, fields[column])
+		setClauses = append(
+			setClauses,
+			fmt.Sprintf("%s = $%d", column, len(queryParams)),
+		)
+	}
+	setClauses = append(setClauses, "updated_at = NOW()")
+
+	query := fmt.Sprintf(
+		`UPDATE products SET %s WHERE product_id = $1 AND deleted_at IS NULL
+		RETURNING product_id, admin_id, name, description, image_url, price, category, is_active, created_at, updated_at`,
+		strings.Join(setClauses, ", "),
+	)
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf(
+			"failed to begin transaction in product store: %w",
+			err,
+		)
+	}
+	defer tx.Rollback()
+
+	product := new(Product)
+	err = tx.QueryRowContext(ctx, query, queryParams...).Scan(
+		&product.ProductID,
+		&product.AdminID,
+		&product.Name,
+		&product.Description,
+		&product.ImageURL,
+		&product.Price,
+		&product.Category,
+		&product.IsActive,
+		&product.CreatedAt,
+		&product.UpdatedAt,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, servererrors.ErrProductNotFound
+		}
+
+		return nil, fmt.Errorf(
+			"failed to update product in product store: %w",
+			err,
+		)
+	}
+
+	for _, newEvent := range events {
+		if err := outbox.Insert(ctx, tx, newEvent); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf(
+			"failed to commit updated product in product store: %w",
+			err,
+		)
+	}
+
+	return product, nil
 }
 
 func scanRowsIntoProduct(rows *sql.Rows, product *Product) error {
@@ -192,39 +595,281 @@ func scanRowsIntoProduct(rows *sql.Rows, product *Product) error {
 	)
 }
 
-func generateQueryAndParams(queryItems *GetAllProductsRequestQuery) (string, string, []any) {
-	// Base SQL query
+// suggest returns up to limit product names and categories that at least one
+// of terms is similar to by threshold, the most similar first.
+func (s *store) suggest(ctx context.Context, terms []string, threshold float64, limit int) ([]*SuggestionDTO, error) {
+	// "<%" uses the trigram indexes, unlike comparing word_similarity to the
+	// threshold, so the threshold is set for this transaction only instead.
+	query := `WITH terms AS (SELECT unnest($1::text[]) AS term),
+	matches AS (
+		SELECT p.name AS suggestion, 'product' AS kind, word_similarity(t.term, p.name) AS score
+		FROM products p, terms t
+		WHERE p.deleted_at IS NULL AND p.is_active AND t.term <% p.name
+		UNION ALL
+		SELECT p.category, 'category', word_similarity(t.term, p.category)
+		FROM products p, terms t
+		WHERE p.deleted_at IS NULL AND p.is_active AND t.term <% p.category
+	)
+	SELECT suggestion, kind, MAX(score) AS score
+	FROM matches
+	GROUP BY suggestion, kind
+	ORDER BY score DESC, suggestion
+	LIMIT $2`
+
+	tx, err := s.db.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		return nil, fmt.Errorf(
+			"failed to begin transaction in product store: %w",
+			err,
+		)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(
+		ctx,
+		`SELECT set_config('pg_trgm.word_similarity_threshold', $1, true)`,
+		strconv.FormatFloat(threshold, 'f', -1, 64),
+	)
+	if err != nil {
+		return nil, fmt.Errorf(
+			"failed to set similarity threshold in product store: %w",
+			err,
+		)
+	}
+
+	rows, err := tx.QueryContext(ctx, query, pq.Array(terms), limit)
+	if err != nil {
+		return nil, fmt.Errorf(
+			"failed to get suggestions from product store: %w",
+			err,
+		)
+	}
+	defer rows.Close()
+
+	suggestions := []*SuggestionDTO{}
+	for rows.Next() {
+		suggestion := new(SuggestionDTO)
+		if err := rows.Scan(&suggestion.Suggestion, &suggestion.Kind, &suggestion.Score); err != nil {
+			return nil, fmt.Errorf(
+				"failed to scan suggestion from product store: %w",
+				err,
+			)
+		}
+		suggestions = append(suggestions, suggestion)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf(
+			"failed to get suggestions from product store: %w",
+			err,
+		)
+	}
+
+	return suggestions, nil
+}
+
+// findSynonyms returns the synonyms that either word of a pair is one of
+// words of.
+func (s *store) findSynonyms(ctx context.Context, words []string) ([]*Synonym, error) {
+	query := `SELECT synonym_id, term, synonym, admin_id, created_at
+	FROM product_synonyms WHERE term = ANY($1) OR synonym = ANY($1)`
+
+	return s.querySynonyms(ctx, query, pq.Array(words))
+}
+
+func (s *store) findAllSynonyms(ctx context.Context) ([]*Synonym, error) {
+	query := `SELECT synonym_id, term, synonym, admin_id, created_at
+	FROM product_synonyms ORDER BY term, synonym`
+
+	return s.querySynonyms(ctx, query)
+}
+
+func (s *store) querySynonyms(ctx context.Context, query string, args ...any) ([]*Synonym, error) {
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf(
+			"failed to get synonyms from product store: %w",
+			err,
+		)
+	}
+	defer rows.Close()
+
+	synonyms := []*Synonym{}
+	for rows.Next() {
+		synonym := new(Synonym)
+		err := rows.Scan(
+			&synonym.SynonymID,
+			&synonym.Term,
+			&synonym.Synonym,
+			&synonym.AdminID,
+			&synonym.CreatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf(
+				"failed to scan synonym from product store: %w",
+				err,
+			)
+		}
+		synonyms = append(synonyms, synonym)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf(
+			"failed to get synonyms from product store: %w",
+			err,
+		)
+	}
+
+	return synonyms, nil
+}
+
+// createSynonym inserts synonym, or returns
+// [servererrors.ErrSynonymAlreadyExists] if the pair exists already.
+func (s *store) createSynonym(ctx context.Context, synonym *Synonym) error {
+	query := `INSERT INTO product_synonyms(synonym_id, term, synonym, admin_id)
+	VALUES($1, $2, $3, $4)
+	ON CONFLICT (term, synonym) DO NOTHING
+	RETURNING created_at`
+
+	err := s.db.QueryRowContext(
+		ctx,
+		query,
+		synonym.SynonymID,
+		synonym.Term,
+		synonym.Synonym,
+		synonym.AdminID,
+	).Scan(&synonym.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return servererrors.ErrSynonymAlreadyExists
+	}
+	if err != nil {
+		return fmt.Errorf(
+			"failed to insert synonym in product store: %w",
+			err,
+		)
+	}
+
+	return nil
+}
+
+// deleteSynonym deletes the synonym with synonymID, or returns
+// [servererrors.ErrSynonymNotFound] if there is none.
+func (s *store) deleteSynonym(ctx context.Context, synonymID uuid.UUID) error {
+	query := `DELETE FROM product_synonyms WHERE synonym_id = $1`
+
+	result, err := s.db.ExecContext(ctx, query, synonymID)
+	if err != nil {
+		return fmt.Errorf(
+			"failed to delete synonym from product store: %w",
+			err,
+		)
+	}
+
+	if deleted, err := result.RowsAffected(); err == nil && deleted == 0 {
+		return servererrors.ErrSynonymNotFound
+	}
+
+	return nil
+}
+
+// highlightOpts are the ts_headline options the matches of a search are
+// highlighted with in the name and description of the products found.
+const highlightOpts = "StartSel=<mark>, StopSel=</mark>"
+
+// htmlEscapes are the SQL literals of the characters escapeHTML escapes and
+// of what they are escaped with, the same as html.EscapeString does. '&'
+// comes first, so the escapes themselves are not escaped.
+var htmlEscapes = [][2]string{
+	{"'&'", "'&amp;'"},
+	{"'<'", "'&lt;'"},
+	{"'>'", "'&gt;'"},
+	{`'"'`, "'&#34;'"},
+	{"''''", "'&#39;'"},
+}
+
+// escapeHTML returns the SQL expression of column with its HTML escaped. The
+// highlights are made of the escaped name and description, so the only HTML
+// in them are the tags of highlightOpts, not whatever an admin typed.
+func escapeHTML(column string) string {
+	escaped := column
+	for _, escape := range htmlEscapes {
+		escaped = fmt.Sprintf("replace(%s, %s, %s)", escaped, escape[0], escape[1])
+	}
+
+	return escaped
+}
+
+// sortColumns are the columns products can be sorted by, by the SortBy of
+// SortOpts. Sorting by relevance is sorting by the rank of the search.
+var sortColumns = map[string]string{
+	"name":       "p.name",
+	"price":      "p.price",
+	"category":   "p.category",
+	"created_at": "p.created_at",
+}
+
+// generateQueryAndParams returns the query of the products of queryItems, the
+// query of how many there are and the params of both, the count query taking
+// all but the last two.
+//
+// Without a cursor the query lists the page of PageOpts. With a cursor it
+// lists the products after or before it instead, see findPage.
+func generateQueryAndParams(queryItems *GetAllProductsRequestQuery, cursor *productCursor) (string, string, []any) {
+	// Base SQL query. Without a search, every product is equally relevant and
+	// has nothing highlighted.
+	searchFields := "0 AS relevance, '' AS name_highlight, '' AS description_highlight"
 	defaultQuery := `SELECT 
 	p.product_id, p.name, p.description, p.image_url, p.price, p.category,
-	p.is_active, p.created_at, p.updated_at, i.stock_quantity
+	p.is_active, p.created_at, p.updated_at, i.stock_quantity, %s
 	FROM products p 
 	INNER JOIN inventory i ON p.product_id = i.product_id`
 	defaultCountQuery := "SELECT COUNT(*) FROM products p INNER JOIN inventory i ON p.product_id = i.product_id"
 
-	whereClauses := []string{}
+	// products in the trash are only listed by findTrashed.
+	whereClauses := []string{"p.deleted_at IS NULL"}
 	queryParams := []any{}
-	sortClause := ""
+	sortColumn := sortColumns["created_at"]
 	// selectFields := "*" // Default to all fields
 
 	if queryItems.FilterOpts.Search != "" {
+		// websearch_to_tsquery accepts what users type into a search box,
+		// e.g. `"pine cone" -green`, and never fails to parse it. A product
+		// matches if it matches the search or any of its synonyms.
+		tsQueries := make([]string, 0, 1+len(queryItems.FilterOpts.SearchSynonyms))
+		for _, search := range append([]string{queryItems.FilterOpts.Search}, queryItems.FilterOpts.SearchSynonyms...) {
+			tsQueries = append(
+				tsQueries,
+				fmt.Sprintf(
+					"websearch_to_tsquery('english', $%d)",
+					len(queryParams)+1,
+				),
+			)
+
+			queryParams = append(queryParams, search)
+		}
+		tsQuery := fmt.Sprintf("(%s)", strings.Join(tsQueries, " || "))
+
+		if queryItems.SortOpts.SortBy == "relevance" {
+			sortColumn = fmt.Sprintf("ts_rank(p.search_vector, %s)", tsQuery)
+		}
+
 		whereClauses = append(
 			whereClauses,
 			fmt.Sprintf(
-				"(name ILIKE $%d OR description ILIKE $%d)",
-				len(queryParams)+1, len(queryParams)+2,
+				"p.search_vector @@ %s",
+				tsQuery,
 			),
 		)
 
-		queryParams = append(
-			queryParams,
-			fmt.Sprintf(
-				"%s%%",
-				queryItems.FilterOpts.Search,
-			),
-			fmt.Sprintf(
-				"%s%%",
-				queryItems.FilterOpts.Search,
-			))
+		searchFields = fmt.Sprintf(
+			`ts_rank(p.search_vector, %[1]s) AS relevance,
+	ts_headline('english', %[3]s, %[1]s, '%[2]s, HighlightAll=true') AS name_highlight,
+	ts_headline('english', %[4]s, %[1]s, '%[2]s, MaxFragments=2, MaxWords=20, MinWords=5') AS description_highlight`,
+			tsQuery,
+			highlightOpts,
+			escapeHTML("p.name"),
+			escapeHTML("p.description"),
+		)
 	}
 
 	if queryItems.FilterOpts.Category != "" {
@@ -259,17 +904,35 @@ func generateQueryAndParams(queryItems *GetAllProductsRequestQuery) (string, str
 		queryParams = append(queryParams, queryItems.FilterOpts.PriceMax)
 	}
 
-	if queryItems.SortOpts.SortBy != "" {
-		// **Important Security Note:** Be very careful with dynamic ORDER BY clauses in raw SQL.
-		//  Ensure `queryItems.SortOpts.SortBy` is validated against a whitelist of allowed columns
-		//  to prevent SQL injection vulnerabilities. For simplicity in this example, we're assuming it's validated.
-		sortClause = fmt.Sprintf(
-			"ORDER BY %s %s",
-			queryItems.SortOpts.SortBy,
-			strings.ToUpper(queryItems.SortOpts.SortOpt),
-		)
+	if column, ok := sortColumns[queryItems.SortOpts.SortBy]; ok {
+		sortColumn = column
+	}
+
+	sortOpt := "DESC"
+	if strings.EqualFold(queryItems.SortOpts.SortOpt, "asc") {
+		sortOpt = "ASC"
+	}
+
+	// the products before a cursor are listed closest to it first.
+	if cursor != nil && cursor.Direction == cursorPrev {
+		if sortOpt == "ASC" {
+			sortOpt = "DESC"
+		} else {
+			sortOpt = "ASC"
+		}
 	}
 
+	// product_id breaks ties, so products with the same sort value are always
+	// listed in the same order.
+	sortClause := fmt.Sprintf(
+		"ORDER BY %s %s, p.product_id %s",
+		sortColumn,
+		sortOpt,
+		sortOpt,
+	)
+
+	defaultQuery = fmt.Sprintf(defaultQuery, searchFields)
+
 	// --- Construct queries ---
 	if len(whereClauses) > 0 {
 		whereStr := strings.Join(whereClauses, " AND ")
@@ -285,8 +948,33 @@ func generateQueryAndParams(queryItems *GetAllProductsRequestQuery) (string, str
 		)
 	}
 
-	if sortClause != "" {
-		defaultQuery += fmt.Sprintf(" %s", sortClause)
+	// the cursor only limits the listed products, not how many there are.
+	if cursor != nil && cursor.ProductID != uuid.Nil {
+		operator := "<"
+		if sortOpt == "ASC" {
+			operator = ">"
+		}
+
+		defaultQuery += fmt.Sprintf(
+			" AND (%s, p.product_id) %s ($%d, $%d)",
+			sortColumn,
+			operator,
+			len(queryParams)+1,
+			len(queryParams)+2,
+		)
+		queryParams = append(queryParams, cursor.Value, cursor.ProductID)
+	}
+
+	defaultQuery += fmt.Sprintf(" %s", sortClause)
+
+	if cursor != nil {
+		defaultQuery += fmt.Sprintf(
+			" LIMIT $%d",
+			len(queryParams)+1,
+		)
+		queryParams = append(queryParams, queryItems.PageOpts.Limit+1)
+
+		return defaultQuery, defaultCountQuery, queryParams
 	}
 
 	// --- Pagination LIMIT and OFFSET ---
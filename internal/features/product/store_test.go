@@ -0,0 +1,177 @@
+package product
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+// normalizeSpace collapses the whitespace of query, which is indented for
+// reading only.
+func normalizeSpace(query string) string {
+	return strings.Join(strings.Fields(query), " ")
+}
+
+func Test_generateQueryAndParams_search(t *testing.T) {
+	const tsQuery = "(websearch_to_tsquery('english', $1) || websearch_to_tsquery('english', $2))"
+
+	queryItems := &GetAllProductsRequestQuery{
+		FilterOpts: FilterOpts{
+			Search:         "pine cone",
+			SearchSynonyms: []string{"pine strobilus"},
+			Category:       "decor",
+		},
+		SortOpts: SortOpts{SortBy: "relevance", SortOpt: "desc"},
+		PageOpts: PageOpts{Page: 2, Limit: 10},
+	}
+
+	query, countQuery, params := generateQueryAndParams(queryItems, nil)
+	query, countQuery = normalizeSpace(query), normalizeSpace(countQuery)
+
+	expectedParams := []any{"pine cone", "pine strobilus", "decor", uint64(10), uint64(10)}
+	if !reflect.DeepEqual(params, expectedParams) {
+		t.Fatalf("expected params %v, got: %v", expectedParams, params)
+	}
+
+	for _, expected := range []string{
+		"WHERE p.deleted_at IS NULL AND p.search_vector @@ " + tsQuery + " AND category = $3",
+		"ts_rank(p.search_vector, " + tsQuery + ") AS relevance",
+		"ORDER BY ts_rank(p.search_vector, " + tsQuery + ") DESC, p.product_id DESC",
+		"LIMIT $4 OFFSET $5",
+	} {
+		if !strings.Contains(query, expected) {
+			t.Fatalf("expected query to contain '%s', got: %s", expected, query)
+		}
+	}
+
+	// the highlights are made of the escaped name and description.
+	for _, expected := range []string{
+		"ts_headline('english', " + escapeHTML("p.name") + ", " + tsQuery + ", '" + highlightOpts + ", HighlightAll=true') AS name_highlight",
+		"ts_headline('english', " + escapeHTML("p.description") + ", " + tsQuery + ", '" + highlightOpts + ", MaxFragments=2, MaxWords=20, MinWords=5') AS description_highlight",
+	} {
+		if !strings.Contains(query, expected) {
+			t.Fatalf("expected query to contain '%s', got: %s", expected, query)
+		}
+	}
+	if strings.Contains(query, "ts_headline('english', p.") {
+		t.Fatalf("expected no highlight of an unescaped column, got: %s", query)
+	}
+
+	// the count query takes all params but the limit and offset.
+	expectedCountQuery := "SELECT COUNT(*) FROM products p INNER JOIN inventory i ON p.product_id = i.product_id " +
+		"WHERE p.deleted_at IS NULL AND p.search_vector @@ " + tsQuery + " AND category = $3"
+	if countQuery != expectedCountQuery {
+		t.Fatalf("expected count query '%s', got: '%s'", expectedCountQuery, countQuery)
+	}
+}
+
+func Test_generateQueryAndParams_withoutSearch(t *testing.T) {
+	queryItems := &GetAllProductsRequestQuery{
+		SortOpts: SortOpts{SortBy: "relevance", SortOpt: "asc"},
+		PageOpts: PageOpts{Page: 1, Limit: 10},
+	}
+
+	query, _, params := generateQueryAndParams(queryItems, nil)
+	query = normalizeSpace(query)
+
+	for _, expected := range []string{
+		"0 AS relevance, '' AS name_highlight, '' AS description_highlight",
+		"WHERE p.deleted_at IS NULL ORDER BY p.created_at ASC, p.product_id ASC LIMIT $1 OFFSET $2",
+	} {
+		if !strings.Contains(query, expected) {
+			t.Fatalf("expected query to contain '%s', got: %s", expected, query)
+		}
+	}
+
+	for _, unexpected := range []string{"ts_rank", "ts_headline", "websearch_to_tsquery"} {
+		if strings.Contains(query, unexpected) {
+			t.Fatalf("expected query without a search not to contain '%s', got: %s", unexpected, query)
+		}
+	}
+
+	if expectedParams := []any{uint64(10), uint64(0)}; !reflect.DeepEqual(params, expectedParams) {
+		t.Fatalf("expected params %v, got: %v", expectedParams, params)
+	}
+}
+
+func Test_escapeHTML(t *testing.T) {
+	expected := `replace(replace(replace(replace(replace(p.name, '&', '&amp;'), '<', '&lt;'), '>', '&gt;'), '"', '&#34;'), '''', '&#39;')`
+	if got := escapeHTML("p.name"); got != expected {
+		t.Fatalf("expected '%s', got: '%s'", expected, got)
+	}
+}
+
+func Test_generateQueryAndParams_cursor(t *testing.T) {
+	productID := uuid.New()
+
+	testCases := []struct {
+		name           string
+		sortOpt        string
+		cursor         *productCursor
+		expectedClause string
+		expectedParams []any
+	}{
+		{
+			name:           "lists the first page",
+			sortOpt:        "desc",
+			cursor:         &productCursor{Direction: cursorNext},
+			expectedClause: "WHERE p.deleted_at IS NULL AND category = $1 ORDER BY p.price DESC, p.product_id DESC LIMIT $2",
+			expectedParams: []any{"decor", uint64(11)},
+		},
+		{
+			name:           "lists the products after a cursor, sorted descending",
+			sortOpt:        "desc",
+			cursor:         &productCursor{Value: "4.25", ProductID: productID, Direction: cursorNext},
+			expectedClause: "WHERE p.deleted_at IS NULL AND category = $1 AND (p.price, p.product_id) < ($2, $3) ORDER BY p.price DESC, p.product_id DESC LIMIT $4",
+			expectedParams: []any{"decor", "4.25", productID, uint64(11)},
+		},
+		{
+			name:           "lists the products before a cursor, sorted descending",
+			sortOpt:        "desc",
+			cursor:         &productCursor{Value: "4.25", ProductID: productID, Direction: cursorPrev},
+			expectedClause: "WHERE p.deleted_at IS NULL AND category = $1 AND (p.price, p.product_id) > ($2, $3) ORDER BY p.price ASC, p.product_id ASC LIMIT $4",
+			expectedParams: []any{"decor", "4.25", productID, uint64(11)},
+		},
+		{
+			name:           "lists the products after a cursor, sorted ascending",
+			sortOpt:        "asc",
+			cursor:         &productCursor{Value: "4.25", ProductID: productID, Direction: cursorNext},
+			expectedClause: "WHERE p.deleted_at IS NULL AND category = $1 AND (p.price, p.product_id) > ($2, $3) ORDER BY p.price ASC, p.product_id ASC LIMIT $4",
+			expectedParams: []any{"decor", "4.25", productID, uint64(11)},
+		},
+		{
+			name:           "lists the products before a cursor, sorted ascending",
+			sortOpt:        "asc",
+			cursor:         &productCursor{Value: "4.25", ProductID: productID, Direction: cursorPrev},
+			expectedClause: "WHERE p.deleted_at IS NULL AND category = $1 AND (p.price, p.product_id) < ($2, $3) ORDER BY p.price DESC, p.product_id DESC LIMIT $4",
+			expectedParams: []any{"decor", "4.25", productID, uint64(11)},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			queryItems := &GetAllProductsRequestQuery{
+				FilterOpts: FilterOpts{Category: "decor"},
+				SortOpts:   SortOpts{SortBy: "price", SortOpt: tc.sortOpt},
+				PageOpts:   PageOpts{Page: 3, Limit: 10},
+			}
+
+			query, _, params := generateQueryAndParams(queryItems, tc.cursor)
+			query = normalizeSpace(query)
+
+			// one more than the limit tells whether there are more products.
+			if !strings.HasSuffix(query, tc.expectedClause) {
+				t.Fatalf("expected query to end with '%s', got: %s", tc.expectedClause, query)
+			}
+			if strings.Contains(query, "OFFSET") {
+				t.Fatalf("expected no offset when listing by cursor, got: %s", query)
+			}
+
+			if !reflect.DeepEqual(params, tc.expectedParams) {
+				t.Fatalf("expected params %v, got: %v", tc.expectedParams, params)
+			}
+		})
+	}
+}
@@ -0,0 +1,138 @@
+package product
+
+import (
+	"context"
+	"strings"
+
+	"github.com/eng-by-sjb/yellow-pines-e-commerce-backend/internal/servererrors"
+	"github.com/google/uuid"
+)
+
+// suggestThreshold is how similar, from 0 to 1, a product name or category
+// must be to what a shopper typed to be suggested. It is low enough for
+// misspellings like "pinne tabel" to still suggest "Pine Table".
+const suggestThreshold = 0.3
+
+// maxSearchSynonyms is how many alternatives of a search with words replaced
+// by their synonyms are searched for at most.
+const maxSearchSynonyms = 5
+
+// suggest returns up to limit product names and categories similar to q, or
+// to q with words replaced by their synonyms.
+func (s *service) suggest(ctx context.Context, q string, limit int) ([]*SuggestionDTO, error) {
+	q = strings.Join(strings.Fields(strings.ToLower(q)), " ")
+
+	alternatives, err := s.searchSynonyms(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.store.suggest(
+		ctx,
+		append([]string{q}, alternatives...),
+		suggestThreshold,
+		limit,
+	)
+}
+
+// searchSynonyms returns the alternatives of search with one of its words, or
+// search as a whole, replaced by one of its synonyms.
+func (s *service) searchSynonyms(ctx context.Context, search string) ([]string, error) {
+	search = strings.ToLower(search)
+
+	words := strings.Fields(search)
+	if len(words) == 0 {
+		return nil, nil
+	}
+
+	synonyms, err := s.store.findSynonyms(ctx, append(words, strings.Join(words, " ")))
+	if err != nil {
+		return nil, err
+	}
+
+	return expandSynonyms(words, synonyms), nil
+}
+
+// expandSynonyms returns words joined with one word, or all of them, replaced
+// by one of its synonyms, up to maxSearchSynonyms distinct alternatives.
+func expandSynonyms(words []string, synonyms []*Synonym) []string {
+	if len(synonyms) == 0 {
+		return nil
+	}
+
+	// a synonym applies both ways.
+	synonymsOf := make(map[string][]string)
+	for _, synonym := range synonyms {
+		synonymsOf[synonym.Term] = append(synonymsOf[synonym.Term], synonym.Synonym)
+		synonymsOf[synonym.Synonym] = append(synonymsOf[synonym.Synonym], synonym.Term)
+	}
+
+	var alternatives []string
+	// the search itself is no alternative of it.
+	seen := map[string]bool{strings.Join(words, " "): true}
+	add := func(alternative string) bool {
+		if !seen[alternative] {
+			seen[alternative] = true
+			alternatives = append(alternatives, alternative)
+		}
+		return len(alternatives) < maxSearchSynonyms
+	}
+
+	if len(words) > 1 {
+		for _, synonym := range synonymsOf[strings.Join(words, " ")] {
+			if !add(synonym) {
+				return alternatives
+			}
+		}
+	}
+
+	for i, word := range words {
+		for _, synonym := range synonymsOf[word] {
+			alternative := make([]string, len(words))
+			copy(alternative, words)
+			alternative[i] = synonym
+
+			if !add(strings.Join(alternative, " ")) {
+				return alternatives
+			}
+		}
+	}
+
+	return alternatives
+}
+
+func (s *service) getSynonyms(ctx context.Context) ([]*Synonym, error) {
+	return s.store.findAllSynonyms(ctx)
+}
+
+// createSynonym adds a synonym, stored with the lexically smaller word as its
+// term as it applies both ways.
+func (s *service) createSynonym(ctx context.Context, newSynonym *CreateSynonymRequest) (*Synonym, error) {
+	term := strings.Join(strings.Fields(strings.ToLower(newSynonym.Term)), " ")
+	synonymWord := strings.Join(strings.Fields(strings.ToLower(newSynonym.Synonym)), " ")
+
+	if term == synonymWord {
+		return nil, servererrors.ErrValidationFailed
+	}
+
+	if synonymWord < term {
+		term, synonymWord = synonymWord, term
+	}
+
+	synonym := &Synonym{
+		SynonymID: uuid.New(),
+		Term:      term,
+		Synonym:   synonymWord,
+		AdminID:   newSynonym.AdminID,
+	}
+
+	if err := s.store.createSynonym(ctx, synonym); err != nil {
+		return nil, err
+	}
+
+	return synonym, nil
+}
+
+func (s *service) deleteSynonym(ctx context.Context, synonymID uuid.UUID) error {
+	return s.store.deleteSynonym(ctx, synonymID)
+}
@@ -0,0 +1,218 @@
+package product
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/eng-by-sjb/yellow-pines-e-commerce-backend/internal/servererrors"
+	"github.com/google/uuid"
+)
+
+func Test_expandSynonyms(t *testing.T) {
+	testCases := []struct {
+		name     string
+		search   string
+		synonyms []*Synonym
+		expected []string
+	}{
+		{
+			name:     "has no alternatives without synonyms",
+			search:   "pine table",
+			expected: nil,
+		},
+		{
+			name:     "replaces a word by its synonym",
+			search:   "pine table",
+			synonyms: []*Synonym{{Term: "desk", Synonym: "table"}},
+			expected: []string{"pine desk"},
+		},
+		{
+			name:     "replaces a word by its term, as synonyms apply both ways",
+			search:   "pine desk",
+			synonyms: []*Synonym{{Term: "desk", Synonym: "table"}},
+			expected: []string{"pine table"},
+		},
+		{
+			name:   "replaces one word at a time",
+			search: "pine table",
+			synonyms: []*Synonym{
+				{Term: "fir", Synonym: "pine"},
+				{Term: "desk", Synonym: "table"},
+			},
+			expected: []string{"fir table", "pine desk"},
+		},
+		{
+			name:   "replaces a multi-word search as a whole first",
+			search: "pine cone",
+			synonyms: []*Synonym{
+				{Term: "fir", Synonym: "pine"},
+				{Term: "pine cone", Synonym: "strobilus"},
+			},
+			expected: []string{"strobilus", "fir cone"},
+		},
+		{
+			name:     "does not replace a multi-word synonym word by word",
+			search:   "cone",
+			synonyms: []*Synonym{{Term: "pine cone", Synonym: "strobilus"}},
+			expected: nil,
+		},
+		{
+			name:   "lists an alternative once",
+			search: "pine table",
+			synonyms: []*Synonym{
+				{Term: "fir", Synonym: "pine"},
+				{Term: "fir table", Synonym: "pine table"},
+			},
+			expected: []string{"fir table"},
+		},
+		{
+			name:   "replaces each of repeated words",
+			search: "pine pine",
+			synonyms: []*Synonym{
+				{Term: "fir", Synonym: "pine"},
+			},
+			expected: []string{"fir pine", "pine fir"},
+		},
+		{
+			name:   "lists up to maxSearchSynonyms alternatives",
+			search: "table",
+			synonyms: []*Synonym{
+				{Term: "bench", Synonym: "table"},
+				{Term: "board", Synonym: "table"},
+				{Term: "counter", Synonym: "table"},
+				{Term: "desk", Synonym: "table"},
+				{Term: "stand", Synonym: "table"},
+				{Term: "table", Synonym: "workbench"},
+			},
+			expected: []string{"bench", "board", "counter", "desk", "stand"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := expandSynonyms(strings.Fields(tc.search), tc.synonyms)
+			if !reflect.DeepEqual(got, tc.expected) {
+				t.Fatalf("expected alternatives %q, got: %q", tc.expected, got)
+			}
+		})
+	}
+}
+
+func Test_searchSynonyms(t *testing.T) {
+	store := newFakeStore()
+	store.synonyms = []*Synonym{{Term: "fir", Synonym: "pine"}}
+	s := &service{store: store}
+
+	got, err := s.searchSynonyms(context.Background(), "  Pine   TABLE ")
+	if err != nil {
+		t.Fatalf("expected to find the synonyms, got: %v", err)
+	}
+
+	// synonyms are looked up for every word and the search as a whole, in
+	// lower case.
+	if expected := []string{"pine", "table", "pine table"}; !reflect.DeepEqual(store.synonymsLookup, expected) {
+		t.Fatalf("expected synonyms to be looked up for %q, got: %q", expected, store.synonymsLookup)
+	}
+
+	if expected := []string{"fir table"}; !reflect.DeepEqual(got, expected) {
+		t.Fatalf("expected alternatives %q, got: %q", expected, got)
+	}
+}
+
+func Test_createSynonym(t *testing.T) {
+	testCases := []struct {
+		name            string
+		term            string
+		synonym         string
+		expectedErr     error
+		expectedTerm    string
+		expectedSynonym string
+	}{
+		{
+			name:            "keeps the lexically smaller word as term",
+			term:            "desk",
+			synonym:         "table",
+			expectedTerm:    "desk",
+			expectedSynonym: "table",
+		},
+		{
+			name:            "swaps the words if the synonym is the smaller one",
+			term:            "table",
+			synonym:         "desk",
+			expectedTerm:    "desk",
+			expectedSynonym: "table",
+		},
+		{
+			name:            "lower cases the words",
+			term:            "Pine",
+			synonym:         "FIR",
+			expectedTerm:    "fir",
+			expectedSynonym: "pine",
+		},
+		{
+			name:            "collapses the spaces of multi-word input",
+			term:            "  pine   cone ",
+			synonym:         "Strobilus",
+			expectedTerm:    "pine cone",
+			expectedSynonym: "strobilus",
+		},
+		{
+			name:        "rejects words that only differ in case and spaces",
+			term:        "Pine  Cone",
+			synonym:     " pine cone",
+			expectedErr: servererrors.ErrValidationFailed,
+		},
+		{
+			name:        "rejects a synonym that exists, whichever way around",
+			term:        "pine",
+			synonym:     "Oak",
+			expectedErr: servererrors.ErrSynonymAlreadyExists,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			store := newFakeStore()
+			store.synonyms = []*Synonym{{SynonymID: uuid.New(), Term: "oak", Synonym: "pine"}}
+			s := &service{store: store}
+
+			adminID := uuid.New()
+			synonym, err := s.createSynonym(
+				context.Background(),
+				&CreateSynonymRequest{
+					AdminID: adminID,
+					Term:    tc.term,
+					Synonym: tc.synonym,
+				},
+			)
+			if !errors.Is(err, tc.expectedErr) {
+				t.Fatalf("expected error '%v', got: %v", tc.expectedErr, err)
+			}
+
+			if tc.expectedErr != nil {
+				if len(store.synonyms) != 1 {
+					t.Fatalf("expected no synonym to be created, got: %d synonyms", len(store.synonyms))
+				}
+				return
+			}
+
+			if synonym.Term != tc.expectedTerm || synonym.Synonym != tc.expectedSynonym {
+				t.Fatalf(
+					"expected term '%s' and synonym '%s', got: '%s' and '%s'",
+					tc.expectedTerm, tc.expectedSynonym, synonym.Term, synonym.Synonym,
+				)
+			}
+
+			if synonym.SynonymID == uuid.Nil || synonym.AdminID != adminID {
+				t.Fatalf("expected the synonym to have an id and the admin who created it, got: %+v", synonym)
+			}
+
+			if len(store.synonyms) != 2 || store.synonyms[1] != synonym {
+				t.Fatal("expected the synonym to be stored")
+			}
+		})
+	}
+}
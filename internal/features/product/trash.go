@@ -0,0 +1,165 @@
+package product
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/eng-by-sjb/yellow-pines-e-commerce-backend/internal/eventengine/event"
+	"github.com/eng-by-sjb/yellow-pines-e-commerce-backend/internal/servererrors"
+	"github.com/google/uuid"
+)
+
+// DefaultTrashRetention is how long a deleted product stays in the trash, from
+// where it can be restored, before it is purged for good.
+const DefaultTrashRetention = 30 * 24 * time.Hour
+
+// purgeBatchSize is how many products are purged from the trash at once.
+const purgeBatchSize = 50
+
+// deleteProduct moves the product with productID to the trash, where it is
+// hidden from customers, and publishes a product.deleted event through the
+// outbox.
+func (s *service) deleteProduct(ctx context.Context, productID uuid.UUID) error {
+	deletedEvent := &event.ProductDeletedEvent{
+		Name:      event.ProductDeletedEventName,
+		ProductID: productID,
+	}
+
+	return s.store.softDeleteOne(
+		ctx,
+		productID,
+		event.New(ctx, producerName, deletedEvent.EventName(), deletedEvent),
+	)
+}
+
+// restoreProduct moves the product with productID out of the trash, and
+// publishes a product.updated event through the outbox.
+func (s *service) restoreProduct(ctx context.Context, productID uuid.UUID) (*Product, error) {
+	updatedEvent := &event.ProductUpdatedEvent{
+		Name: event.ProductUpdatedEventName,
+		ProductPayload: event.ProductPayload{
+			ProductID: productID,
+		},
+	}
+
+	return s.store.restoreOne(
+		ctx,
+		productID,
+		event.New(ctx, producerName, updatedEvent.EventName(), updatedEvent),
+	)
+}
+
+func (s *service) getTrash(ctx context.Context, pageOpts *PageOpts) ([]*TrashedProductDTO, int, error) {
+	return s.store.findTrashed(ctx, pageOpts, s.trashRetention)
+}
+
+// purgeTrash deletes the products that have been in the trash for longer than
+// the trash retention for good, and publishes a product.deleted event for
+// each of them through the outbox. It returns how many products it purged.
+func (s *service) purgeTrash(ctx context.Context) (int, error) {
+	var purged int
+	for {
+		productIDs, err := s.store.findTrashedBefore(ctx, s.trashRetention, purgeBatchSize)
+		if err != nil {
+			return purged, err
+		}
+
+		for _, productID := range productIDs {
+			deletedEvent := &event.ProductDeletedEvent{
+				Name:        event.ProductDeletedEventName,
+				ProductID:   productID,
+				Permanently: true,
+			}
+
+			err := s.store.purgeOne(
+				ctx,
+				productID,
+				s.trashRetention,
+				event.New(ctx, producerName, deletedEvent.EventName(), deletedEvent),
+			)
+			switch {
+			case errors.Is(err, servererrors.ErrProductNotFound):
+				// restored or purged by another instance meanwhile.
+				continue
+
+			case err != nil:
+				return purged, err
+			}
+
+			purged++
+		}
+
+		if len(productIDs) < purgeBatchSize {
+			return purged, nil
+		}
+	}
+}
+
+type trashPurger interface {
+	purgeTrash(ctx context.Context) (int, error)
+}
+
+type TrashPurgerConfig struct {
+	DoneCh        <-chan struct{}
+	InternalSrvWG *sync.WaitGroup
+	Service       trashPurger
+	Interval      time.Duration // How often the trash is purged. Defaults to an hour.
+}
+
+// NewTrashPurger starts a go routine that purges the products that have been
+// in the trash for longer than the trash retention of the product service,
+// see [DefaultTrashRetention], once at start and then every Interval, until
+// DoneCh is closed.
+func NewTrashPurger(cfg *TrashPurgerConfig) {
+	if cfg == nil {
+		log.Fatalln("'TrashPurgerConfig' can not be nil")
+	}
+
+	if cfg.DoneCh == nil || cfg.InternalSrvWG == nil || cfg.Service == nil {
+		log.Fatalln("either 'DoneCh', 'InternalSrvWG' or 'Service' is nil in trash purger")
+	}
+
+	if cfg.Interval <= 0 {
+		cfg.Interval = time.Hour
+	}
+
+	cfg.InternalSrvWG.Add(1)
+	go func() {
+		defer cfg.InternalSrvWG.Done()
+
+		ticker := time.NewTicker(cfg.Interval)
+		defer ticker.Stop()
+
+		for {
+			purge(cfg.Service)
+
+			select {
+			case <-cfg.DoneCh:
+				log.Println("trash purger is shutting down")
+				return
+
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+func purge(service trashPurger) {
+	ctx, cancel := context.WithTimeout(
+		context.Background(),
+		(time.Minute),
+	)
+	defer cancel()
+
+	purged, err := service.purgeTrash(ctx)
+	if err != nil {
+		log.Printf("failed to purge the products trash: %v\n", err)
+	}
+
+	if purged > 0 {
+		log.Printf("purged %d product(s) from the trash\n", purged)
+	}
+}
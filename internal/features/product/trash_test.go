@@ -0,0 +1,202 @@
+package product
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/eng-by-sjb/yellow-pines-e-commerce-backend/internal/eventengine/event"
+	"github.com/eng-by-sjb/yellow-pines-e-commerce-backend/internal/servererrors"
+	"github.com/google/uuid"
+)
+
+func Test_deleteProduct(t *testing.T) {
+	productID := uuid.New()
+	store := newFakeStore(&Product{ProductID: productID})
+	s := &service{store: store, trashRetention: DefaultTrashRetention}
+
+	if err := s.deleteProduct(context.Background(), productID); err != nil {
+		t.Fatalf("expected to delete the product, got: %v", err)
+	}
+
+	if !store.trashed[productID] {
+		t.Fatal("expected the product to be moved to the trash")
+	}
+	if _, ok := store.products[productID]; !ok {
+		t.Fatal("expected the product to be kept, so it can be restored")
+	}
+
+	if len(store.events) != 1 {
+		t.Fatalf("expected a product.deleted event, got: %v", store.eventNames())
+	}
+	deleted, ok := store.events[0].Payload.(*event.ProductDeletedEvent)
+	if !ok || deleted.ProductID != productID || deleted.Permanently {
+		t.Fatalf("expected product.deleted of product '%s' not to be permanent, got: %+v", productID, store.events[0].Payload)
+	}
+
+	// the product is not in the listing anymore, so it can not be deleted again.
+	if err := s.deleteProduct(context.Background(), productID); !errors.Is(err, servererrors.ErrProductNotFound) {
+		t.Fatalf("expected deleting a trashed product to fail with '%v', got: %v", servererrors.ErrProductNotFound, err)
+	}
+
+	trash, count, err := s.getTrash(context.Background(), &PageOpts{Page: 1, Limit: 10})
+	if err != nil {
+		t.Fatalf("expected to get the trash, got: %v", err)
+	}
+	if count != 1 || trash[0].ProductID != productID {
+		t.Fatalf("expected the product in the trash, got: %d products", count)
+	}
+	// the store computes when the product is purged from the retention.
+	if expected := []time.Duration{DefaultTrashRetention}; !reflect.DeepEqual(store.retentions, expected) {
+		t.Fatalf("expected the trash to be listed with retentions %v, got: %v", expected, store.retentions)
+	}
+	if purgeAt := trash[0].DeletedAt.Add(DefaultTrashRetention); !trash[0].PurgeAt.Equal(purgeAt) {
+		t.Fatalf("expected the product to be purged at %v, got: %v", purgeAt, trash[0].PurgeAt)
+	}
+}
+
+func Test_restoreProduct(t *testing.T) {
+	productID := uuid.New()
+	store := newFakeStore(&Product{ProductID: productID})
+	s := &service{store: store, trashRetention: DefaultTrashRetention}
+
+	if _, err := s.restoreProduct(context.Background(), productID); !errors.Is(err, servererrors.ErrProductNotFound) {
+		t.Fatalf("expected restoring a product outside of the trash to fail with '%v', got: %v", servererrors.ErrProductNotFound, err)
+	}
+
+	if err := s.deleteProduct(context.Background(), productID); err != nil {
+		t.Fatalf("expected to delete the product, got: %v", err)
+	}
+
+	restored, err := s.restoreProduct(context.Background(), productID)
+	if err != nil {
+		t.Fatalf("expected to restore the product, got: %v", err)
+	}
+	if restored.ProductID != productID || restored.DeletedAt != nil || store.trashed[productID] {
+		t.Fatalf("expected product '%s' to be out of the trash, got: %+v", productID, restored)
+	}
+
+	expectedEvents := []event.EventName{event.ProductDeletedEventName, event.ProductUpdatedEventName}
+	if got := store.eventNames(); !reflect.DeepEqual(got, expectedEvents) {
+		t.Fatalf("expected events %v, got: %v", expectedEvents, got)
+	}
+}
+
+func Test_purgeTrash(t *testing.T) {
+	const retention = 7 * 24 * time.Hour
+
+	store := newFakeStore()
+	s := &service{store: store, trashRetention: retention}
+
+	// more than a batch, so the trash is purged in batches.
+	var purgeable []uuid.UUID
+	for i := 0; i < purgeBatchSize+2; i++ {
+		productID := uuid.New()
+		store.products[productID] = &Product{ProductID: productID}
+		store.trashed[productID] = true
+		purgeable = append(purgeable, productID)
+	}
+	store.purgeable = purgeable
+	store.restoredMeanwhile = purgeable[1]
+
+	// trashed, but not for long enough.
+	recentlyTrashed := uuid.New()
+	store.products[recentlyTrashed] = &Product{ProductID: recentlyTrashed}
+	store.trashed[recentlyTrashed] = true
+
+	purged, err := s.purgeTrash(context.Background())
+	if err != nil {
+		t.Fatalf("expected to purge the trash, got: %v", err)
+	}
+
+	if purged != len(purgeable)-1 {
+		t.Fatalf("expected %d products to be purged, got: %d", len(purgeable)-1, purged)
+	}
+
+	for _, productID := range purgeable {
+		if _, ok := store.products[productID]; ok && productID != store.restoredMeanwhile {
+			t.Fatalf("expected product '%s' to be purged", productID)
+		}
+	}
+	if _, ok := store.products[store.restoredMeanwhile]; !ok {
+		t.Fatal("expected the product restored meanwhile to be kept")
+	}
+	if !store.trashed[recentlyTrashed] {
+		t.Fatal("expected the recently trashed product to stay in the trash")
+	}
+
+	for _, written := range store.events {
+		deleted, ok := written.Payload.(*event.ProductDeletedEvent)
+		if !ok || !deleted.Permanently {
+			t.Fatalf("expected permanent product.deleted events, got: %+v", written.Payload)
+		}
+	}
+	if len(store.events) != purged {
+		t.Fatalf("expected a product.deleted event per purged product, got: %d", len(store.events))
+	}
+
+	// the cutoff is left to the store, which is given the retention only.
+	for _, got := range store.retentions {
+		if got != retention {
+			t.Fatalf("expected the store to be given the retention %v, got: %v", retention, got)
+		}
+	}
+}
+
+// fakeTrashPurger counts how often the trash is purged.
+type fakeTrashPurger struct {
+	purgedCh chan struct{}
+}
+
+func (p *fakeTrashPurger) purgeTrash(ctx context.Context) (int, error) {
+	p.purgedCh <- struct{}{}
+	return 0, nil
+}
+
+func Test_NewTrashPurger(t *testing.T) {
+	doneCh := make(chan struct{})
+	internalSrvWG := new(sync.WaitGroup)
+	purger := &fakeTrashPurger{purgedCh: make(chan struct{})}
+
+	NewTrashPurger(
+		&TrashPurgerConfig{
+			DoneCh:        doneCh,
+			InternalSrvWG: internalSrvWG,
+			Service:       purger,
+			Interval:      time.Millisecond,
+		},
+	)
+
+	// once at start, then every interval.
+	for i := 0; i < 3; i++ {
+		select {
+		case <-purger.purgedCh:
+		case <-time.After(2 * time.Second):
+			t.Fatalf("expected the trash to be purged %d times, got: %d", 3, i)
+		}
+	}
+
+	close(doneCh)
+
+	stoppedCh := make(chan struct{})
+	go func() {
+		internalSrvWG.Wait()
+		close(stoppedCh)
+	}()
+
+	for {
+		select {
+		case <-stoppedCh:
+			return
+
+		case <-purger.purgedCh:
+			// a purge that started before doneCh was closed.
+
+		case <-time.After(2 * time.Second):
+			t.Fatal("expected the trash purger to stop once done")
+		}
+	}
+}
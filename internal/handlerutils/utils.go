@@ -58,6 +58,13 @@ func MakeHandler(handler APIHandler) http.HandlerFunc {
 						serverError.Error(),
 						serverError.Errors,
 					)
+				case http.StatusNotFound:
+					WriteErrorJSON(
+						w,
+						serverError.StatusCode,
+						serverError.Error(),
+						serverError.Errors,
+					)
 				}
 			} else {
 				WriteErrorJSON(
@@ -0,0 +1,34 @@
+package middlewares
+
+import (
+	"net/http"
+
+	"github.com/eng-by-sjb/yellow-pines-e-commerce-backend/internal/eventengine/event"
+	chimiddleware "github.com/go-chi/chi/middleware"
+)
+
+// CorrelationIDHeader is the header a client can send to correlate its request
+// with the events it causes. It is echoed back in the response.
+const CorrelationIDHeader = "X-Correlation-ID"
+
+// CorrelationID puts the correlation id of the request into its context, so
+// events published while handling the request are correlated with it. It is
+// the CorrelationIDHeader sent by the client or else the request id set by
+// chi's RequestID middleware, which has to run before this one.
+func CorrelationID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		correlationID := r.Header.Get(CorrelationIDHeader)
+		if correlationID == "" {
+			correlationID = chimiddleware.GetReqID(r.Context())
+		}
+
+		if correlationID != "" {
+			w.Header().Set(CorrelationIDHeader, correlationID)
+			r = r.WithContext(
+				event.ContextWithCorrelationID(r.Context(), correlationID),
+			)
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
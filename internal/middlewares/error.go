@@ -55,6 +55,13 @@ func (mw *middleware) ErrorHandler(h handlerutils.APIHandler) http.HandlerFunc {
 						serverError.Error(),
 						serverError.Errors,
 					)
+				case http.StatusNotFound:
+					handlerutils.WriteErrorJSON(
+						w,
+						serverError.StatusCode,
+						serverError.Error(),
+						serverError.Errors,
+					)
 				}
 			} else {
 				handlerutils.WriteErrorJSON(
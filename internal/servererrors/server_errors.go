@@ -27,6 +27,12 @@ var (
 	ErrProductAlreadyExists  = errors.New("product already exists")
 	ErrURLQueryParams        = errors.New("one or more invalid value(s) in url query parameter(s)")
 	ErrProductNotFound       = errors.New("product not found")
+	ErrDeadLetterNotFound    = errors.New("dead letter not found")
+	ErrInvalidID             = errors.New("invalid id in url path")
+	ErrNoFieldsToUpdate      = errors.New("no fields to update")
+	ErrSynonymAlreadyExists  = errors.New("synonym already exists")
+	ErrSynonymNotFound       = errors.New("synonym not found")
+	ErrInvalidCursor         = errors.New("invalid cursor")
 )
 
 type ServerError struct {